@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"internal/audit"
+	"net/http"
+	"strconv"
+)
+
+// getAuditLogHandler lists audit events newest first, filtered by any of
+// actor_id, action, target, outcome, since and until.
+func (cfg *apiConfig) getAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Action:  query.Get("action"),
+		Target:  query.Get("target"),
+		Outcome: audit.Outcome(query.Get("outcome")),
+	}
+	if value := query.Get("actor_id"); value != "" {
+		actorID, err := strconv.Atoi(value)
+		if err != nil || actorID <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid actor_id")
+			return
+		}
+		filter.ActorID = actorID
+	}
+	switch filter.Outcome {
+	case "", audit.OutcomeSuccess, audit.OutcomeFailure, audit.OutcomeDenied:
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid outcome, expected one of success, failure or denied")
+		return
+	}
+	var err error
+	filter.Since, err = parseTimeParam(r, "since")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid since, expected an RFC 3339 time")
+		return
+	}
+	filter.Until, err = parseTimeParam(r, "until")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid until, expected an RFC 3339 time")
+		return
+	}
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	page, err := cfg.auditLog.Query(filter, query.Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, audit.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get audit log")
+		}
+		return
+	}
+	respondWithPage(w, r, page.Events, page.NextCursor)
+}
+
+// verifyAuditLogHandler checks the audit log's hash chain. A broken chain
+// is still a 200, with valid set to false and where it broke.
+func (cfg *apiConfig) verifyAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	verification, err := cfg.auditLog.Verify()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify audit log")
+		return
+	}
+	respondWithJson(w, http.StatusOK, verification)
+}
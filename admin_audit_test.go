@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"internal/audit"
+	"internal/auth"
+	"internal/database"
+	"net/http"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	cfg := newTestConfig(t)
+	adminToken := newTestUser(t, cfg, "admin@example.com", database.RoleAdmin)
+	password, err := auth.HashPassword("password")
+	if err != nil {
+		t.Fatalf("Couldn't hash password: %v", err)
+	}
+	if _, err := cfg.database.CreateUser("user@example.com", password); err != nil {
+		t.Fatalf("Couldn't create user: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	server.send("POST", "/api/login", "", `{"email":"user@example.com","password":"wrong"}`)
+	server.send("POST", "/api/login", "", `{"email":"user@example.com","password":"password"}`)
+
+	resp := server.send("GET", "/admin/audit?action=auth.login&outcome=failure", adminToken, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the audit log, got %d", resp.StatusCode)
+	}
+	page := struct {
+		Data []audit.Event `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("Couldn't decode audit log: %v", err)
+	}
+	if len(page.Data) != 1 {
+		t.Fatalf("Expected the failed login, got %+v", page.Data)
+	}
+	event := page.Data[0]
+	if event.ActorID != 2 || event.Target != "user:2" || event.IP != "127.0.0.1" || event.UserAgent != "chirpy-test" {
+		t.Errorf("Expected the failed login with its actor, IP and user agent, got %+v", event)
+	}
+
+	if resp := server.send("GET", "/admin/audit?outcome=maybe", adminToken, ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an unknown outcome to return 400, got %d", resp.StatusCode)
+	}
+
+	resp = server.send("GET", "/admin/audit/verify", adminToken, "")
+	verification := audit.Verification{}
+	if err := json.NewDecoder(resp.Body).Decode(&verification); err != nil {
+		t.Fatalf("Couldn't decode verification: %v", err)
+	}
+	if !verification.Valid || verification.Events != 2 {
+		t.Errorf("Expected a valid chain of 2 events, got %+v", verification)
+	}
+}
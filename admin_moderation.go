@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"internal/audit"
+	"internal/database"
+	"internal/moderation"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func respondWithModerationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, moderation.ErrFilterNotFound):
+		respondWithError(w, http.StatusNotFound, "Couldn't find filter")
+	case errors.Is(err, moderation.ErrInvalidFilter):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update filters")
+	}
+}
+
+func (cfg *apiConfig) getModerationFiltersHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJson(w, http.StatusOK, cfg.moderator.Filters())
+}
+
+func (cfg *apiConfig) getModerationFilterHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := cfg.moderator.Filter(chi.URLParam(r, "name"))
+	if err != nil {
+		respondWithModerationError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, filter)
+}
+
+// putModerationFilterHandler replaces a filter, or adds it to the end of the
+// chain. The name in the path wins over any name in the body.
+func (cfg *apiConfig) putModerationFilterHandler(w http.ResponseWriter, r *http.Request) {
+	params := moderation.FilterConfig{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	params.Name = chi.URLParam(r, "name")
+
+	added, err := cfg.moderator.PutFilter(params)
+	if err != nil {
+		respondWithModerationError(w, err)
+		return
+	}
+	if added {
+		respondWithJson(w, http.StatusCreated, params)
+		return
+	}
+	respondWithJson(w, http.StatusOK, params)
+}
+
+func (cfg *apiConfig) deleteModerationFilterHandler(w http.ResponseWriter, r *http.Request) {
+	err := cfg.moderator.DeleteFilter(chi.URLParam(r, "name"))
+	if err != nil {
+		respondWithModerationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) addModerationWordsHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Words []string `json:"words"`
+	}
+	params := parameters{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	filter, err := cfg.moderator.AddWords(chi.URLParam(r, "name"), params.Words)
+	if err != nil {
+		respondWithModerationError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, filter)
+}
+
+func (cfg *apiConfig) removeModerationWordHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := cfg.moderator.RemoveWords(chi.URLParam(r, "name"), []string{chi.URLParam(r, "word")})
+	if err != nil {
+		respondWithModerationError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, filter)
+}
+
+// previewModerationHandler shows what the current filters would do to a
+// body without creating anything.
+func (cfg *apiConfig) previewModerationHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	params := parameters{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	respondWithJson(w, http.StatusOK, cfg.moderator.Moderate(params.Body))
+}
+
+func (cfg *apiConfig) getHeldChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	page, err := cfg.database.GetHeldChirps(r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get held chirps")
+		return
+	}
+	respondWithPage(w, r, page.Chirps, page.NextCursor)
+}
+
+// approveHeldChirpHandler publishes a held chirp as if it had just been
+// created.
+func (cfg *apiConfig) approveHeldChirpHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+	chirp, err := cfg.database.ReleaseChirp(id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find held chirp")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't release chirp")
+		return
+	}
+	cfg.publishChirpCreated(chirp)
+	respondWithJson(w, http.StatusOK, chirp)
+}
+
+// rejectHeldChirpHandler deletes a held chirp. It was never published, so
+// there is no deletion to stream.
+func (cfg *apiConfig) rejectHeldChirpHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+	chirp, err := cfg.database.GetChirp(id)
+	if err != nil || chirp.Hold == nil {
+		if err == nil || errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find held chirp")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp")
+		return
+	}
+	err = cfg.database.DeleteChirp(id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp")
+		return
+	}
+	cfg.recordAudit(r, audit.Event{
+		Action:  auditDeleteChirp,
+		Target:  chirpTarget(id),
+		Outcome: audit.OutcomeSuccess,
+		Details: map[string]string{"reason": "rejected held chirp"},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"internal/audit"
+	"internal/database"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func respondWithReportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrNotExist):
+		respondWithError(w, http.StatusNotFound, "Couldn't find report")
+	case errors.Is(err, database.ErrReportClaimed):
+		respondWithError(w, http.StatusConflict, "Report is claimed by another moderator")
+	case errors.Is(err, database.ErrReportResolved):
+		respondWithError(w, http.StatusConflict, "Report is already resolved")
+	default:
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update report")
+	}
+}
+
+// getReportQueueHandler lists reports oldest first. Without a status it
+// lists every report that still needs a moderator.
+func (cfg *apiConfig) getReportQueueHandler(w http.ResponseWriter, r *http.Request) {
+	status := database.ReportStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", database.ReportOpen, database.ReportClaimed, database.ReportResolved:
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid status, expected one of open, claimed or resolved")
+		return
+	}
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	page, err := cfg.database.GetReports(status, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get reports")
+		}
+		return
+	}
+	respondWithPage(w, r, page.Reports, page.NextCursor)
+}
+
+func (cfg *apiConfig) getQueuedReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := reportIDParam(w, r)
+	if !ok {
+		return
+	}
+	report, err := cfg.database.GetReport(id)
+	if err != nil {
+		respondWithReportError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, report)
+}
+
+func (cfg *apiConfig) claimReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := reportIDParam(w, r)
+	if !ok {
+		return
+	}
+	report, err := cfg.database.ClaimReport(id, userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithReportError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, report)
+}
+
+func (cfg *apiConfig) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action         database.ModerationAction `json:"action"`
+		Note           string                    `json:"note"`
+		SuspendedUntil *time.Time                `json:"suspended_until"`
+	}
+	id, ok := reportIDParam(w, r)
+	if !ok {
+		return
+	}
+	params := parameters{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	if !params.Action.Resolves() {
+		respondWithError(w, http.StatusBadRequest, "Invalid action, expected one of dismiss, hide_chirp, delete_chirp, warn, suspend or ban")
+		return
+	}
+
+	// Deleted chirps are gone once the report resolves, so look the chirp
+	// up first to tell streaming clients about it. Suspending or banning
+	// staff needs the same permission as it does outside of a report.
+	var deleted database.Chirp
+	var status database.UserStatus
+	switch params.Action {
+	case database.ModerationSuspend:
+		status = database.UserSuspended
+	case database.ModerationBan:
+		status = database.UserBanned
+	}
+	if params.Action == database.ModerationDeleteChirp || status != "" {
+		report, err := cfg.database.GetReport(id)
+		if err != nil {
+			respondWithReportError(w, err)
+			return
+		}
+		if status != "" {
+			target, err := cfg.database.GetUser(report.UserID)
+			if err != nil && !errors.Is(err, database.ErrNotExist) {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+				return
+			}
+			if !cfg.authorizeStatusChange(w, r, target, status) {
+				return
+			}
+		}
+		if params.Action == database.ModerationDeleteChirp {
+			deleted, err = cfg.database.GetChirp(report.ChirpID)
+			if err != nil && !errors.Is(err, database.ErrNotExist) {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp")
+				return
+			}
+		}
+	}
+
+	report, err := cfg.database.ResolveReport(id, userIDFromContext(r.Context()), database.ReportResolution{
+		Action:         params.Action,
+		Note:           params.Note,
+		SuspendedUntil: params.SuspendedUntil,
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidReport) {
+			switch params.Action {
+			case database.ModerationSuspend:
+				respondWithError(w, http.StatusBadRequest, "Suspensions need a suspended_until in the future")
+			default:
+				respondWithError(w, http.StatusBadRequest, "Only chirp reports can hide or delete a chirp")
+			}
+			return
+		}
+		respondWithReportError(w, err)
+		return
+	}
+	if status != "" {
+		cfg.recordAudit(r, audit.Event{
+			Action:  auditChangeStatus,
+			Target:  userTarget(report.UserID),
+			Outcome: audit.OutcomeSuccess,
+			Details: map[string]string{"status": string(status), "reason": params.Note, "report_id": strconv.Itoa(report.ID)},
+		})
+	}
+	if deleted.ID != 0 && !deleted.Deleted {
+		cfg.recordAudit(r, audit.Event{
+			Action:  auditDeleteChirp,
+			Target:  chirpTarget(deleted.ID),
+			Outcome: audit.OutcomeSuccess,
+			Details: map[string]string{"report_id": strconv.Itoa(report.ID)},
+		})
+		cfg.publishChirpDeleted(deleted)
+	}
+	respondWithJson(w, http.StatusOK, report)
+}
+
+// getModerationLogHandler lists moderator actions newest first, optionally
+// only those against one user.
+func (cfg *apiConfig) getModerationLogHandler(w http.ResponseWriter, r *http.Request) {
+	userID := 0
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		var err error
+		userID, err = strconv.Atoi(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid user id")
+			return
+		}
+	}
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	page, err := cfg.database.GetModerationLog(userID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get moderation log")
+		}
+		return
+	}
+	respondWithPage(w, r, page.Entries, page.NextCursor)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"internal/audit"
+	"internal/database"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResolveReportAgainstStaff(t *testing.T) {
+	cfg := newTestConfig(t)
+	moderatorToken := newTestUser(t, cfg, "moderator@example.com", database.RoleModerator)
+	newTestUser(t, cfg, "admin@example.com", database.RoleAdmin)
+	newTestUser(t, cfg, "user@example.com")
+	reporter := newTestUser(t, cfg, "reporter@example.com")
+	server := newTestServer(t, cfg)
+
+	for _, userID := range []int{2, 3} {
+		body := fmt.Sprintf(`{"user_id":%d,"reason":"spam"}`, userID)
+		if resp := server.send("POST", "/api/reports", reporter, body); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Couldn't report user %d, got %d", userID, resp.StatusCode)
+		}
+	}
+
+	until := time.Now().Add(time.Hour).Format(time.RFC3339)
+	for _, body := range []string{`{"action":"ban"}`, `{"action":"suspend","suspended_until":"` + until + `"}`} {
+		if resp := server.send("POST", "/admin/reports/1/resolve", moderatorToken, body); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected a moderator resolving %s against an admin to get 403, got %d", body, resp.StatusCode)
+		}
+	}
+	admin, err := cfg.database.GetUser(2)
+	if err != nil {
+		t.Fatalf("Couldn't get admin: %v", err)
+	}
+	if status := admin.StatusAt(time.Now()); status != database.UserActive {
+		t.Errorf("Expected the admin to stay active, got %s", status)
+	}
+	report, err := cfg.database.GetReport(1)
+	if err != nil {
+		t.Fatalf("Couldn't get report: %v", err)
+	}
+	if report.Status == database.ReportResolved {
+		t.Errorf("Expected the report to stay unresolved, got %+v", report)
+	}
+	page, err := cfg.auditLog.Query(audit.Filter{Action: auditChangeStatus, Outcome: audit.OutcomeDenied}, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't query audit log: %v", err)
+	}
+	if len(page.Events) != 2 || page.Events[0].ActorID != 1 || page.Events[0].Target != "user:2" {
+		t.Errorf("Expected both refusals to be audited, got %+v", page.Events)
+	}
+
+	if resp := server.send("POST", "/admin/reports/1/resolve", moderatorToken, `{"action":"warn"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected a moderator to warn an admin, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/admin/reports/2/resolve", moderatorToken, `{"action":"ban"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected a moderator to ban a user, got %d", resp.StatusCode)
+	}
+	page, err = cfg.auditLog.Query(audit.Filter{Action: auditChangeStatus, Outcome: audit.OutcomeSuccess}, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't query audit log: %v", err)
+	}
+	if len(page.Events) != 1 || page.Events[0].Target != "user:3" || page.Events[0].Details["status"] != string(database.UserBanned) || page.Events[0].Details["report_id"] != "2" {
+		t.Errorf("Expected the ban to be audited, got %+v", page.Events)
+	}
+}
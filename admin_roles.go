@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"internal/audit"
+	"internal/database"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type rolesResponse struct {
+	UserID int             `json:"user_id"`
+	Roles  []database.Role `json:"roles"`
+}
+
+func newRolesResponse(user database.User) rolesResponse {
+	roles := user.Roles
+	if roles == nil {
+		roles = []database.Role{}
+	}
+	return rolesResponse{UserID: user.ID, Roles: roles}
+}
+
+func (cfg *apiConfig) getRolesHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.resolveUser(chi.URLParam(r, "user"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "User not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		}
+		return
+	}
+	respondWithJson(w, http.StatusOK, newRolesResponse(user))
+}
+
+func (cfg *apiConfig) grantRoleHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateRoleHandler(w, r, cfg.database.GrantRole)
+}
+
+// revokeRoleHandler takes a role away. Admins can't revoke their own admin
+// role, so there is always someone left who can grant it.
+func (cfg *apiConfig) revokeRoleHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateRoleHandler(w, r, cfg.database.RevokeRole)
+}
+
+func (cfg *apiConfig) updateRoleHandler(w http.ResponseWriter, r *http.Request, apply func(userID int, role database.Role) (database.User, error)) {
+	role := database.Role(chi.URLParam(r, "role"))
+	if !role.Valid() {
+		respondWithError(w, http.StatusBadRequest, "Invalid role, expected one of admin or moderator")
+		return
+	}
+	target, err := cfg.resolveUser(chi.URLParam(r, "user"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "User not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		}
+		return
+	}
+	if r.Method == http.MethodDelete && role == database.RoleAdmin && target.ID == userIDFromContext(r.Context()) {
+		respondWithError(w, http.StatusBadRequest, "You can't revoke your own admin role")
+		return
+	}
+
+	user, err := apply(target.ID, role)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update roles")
+		return
+	}
+	action := auditGrantRole
+	if r.Method == http.MethodDelete {
+		action = auditRevokeRole
+	}
+	cfg.recordAudit(r, audit.Event{
+		Action:  action,
+		Target:  userTarget(user.ID),
+		Outcome: audit.OutcomeSuccess,
+		Details: map[string]string{"role": string(role)},
+	})
+	respondWithJson(w, http.StatusOK, newRolesResponse(user))
+}
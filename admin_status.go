@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"internal/audit"
+	"internal/database"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type statusResponse struct {
+	UserID         int                           `json:"user_id"`
+	Status         database.UserStatus           `json:"status"`
+	Reason         string                        `json:"reason,omitempty"`
+	SuspendedUntil *time.Time                    `json:"suspended_until,omitempty"`
+	History        []database.ModerationLogEntry `json:"history"`
+}
+
+func (cfg *apiConfig) respondWithStatus(w http.ResponseWriter, user database.User) {
+	history, err := cfg.database.GetStatusHistory(user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get status history")
+		return
+	}
+	response := statusResponse{
+		UserID:  user.ID,
+		Status:  user.StatusAt(time.Now()),
+		History: history,
+	}
+	if response.Status != database.UserActive {
+		response.Reason = user.StatusReason
+		response.SuspendedUntil = user.SuspendedUntil
+	}
+	respondWithJson(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) getUserStatusHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.resolveUser(chi.URLParam(r, "user"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "User not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		}
+		return
+	}
+	cfg.respondWithStatus(w, user)
+}
+
+// updateUserStatusHandler suspends, bans or reinstates a user outside of a
+// report.
+func (cfg *apiConfig) updateUserStatusHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Status         database.UserStatus `json:"status"`
+		Reason         string              `json:"reason"`
+		SuspendedUntil *time.Time          `json:"suspended_until"`
+	}
+	params := parameters{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	if !params.Status.Valid() {
+		respondWithError(w, http.StatusBadRequest, "Invalid status, expected one of active, suspended or banned")
+		return
+	}
+
+	target, err := cfg.resolveUser(chi.URLParam(r, "user"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "User not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		}
+		return
+	}
+	moderatorID := userIDFromContext(r.Context())
+	if target.ID == moderatorID {
+		respondWithError(w, http.StatusBadRequest, "You can't change your own status")
+		return
+	}
+	if !cfg.authorizeStatusChange(w, r, target, params.Status) {
+		return
+	}
+
+	user, err := cfg.database.SetUserStatus(target.ID, moderatorID, params.Status, params.Reason, params.SuspendedUntil)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidStatus) {
+			respondWithError(w, http.StatusBadRequest, "Suspensions need a suspended_until in the future")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update status")
+		}
+		return
+	}
+	cfg.recordAudit(r, audit.Event{
+		Action:  auditChangeStatus,
+		Target:  userTarget(user.ID),
+		Outcome: audit.OutcomeSuccess,
+		Details: map[string]string{"status": string(params.Status), "reason": params.Reason},
+	})
+	cfg.respondWithStatus(w, user)
+}
+
+// authorizeStatusChange reports whether the caller may change target's
+// status. Only those who can manage roles may change the status of users
+// with roles, so moderators can't lock out admins. Refusals are audited
+// and answered with a 403.
+func (cfg *apiConfig) authorizeStatusChange(w http.ResponseWriter, r *http.Request, target database.User, status database.UserStatus) bool {
+	if len(target.Roles) == 0 {
+		return true
+	}
+	moderator, err := cfg.database.GetUser(userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		return false
+	}
+	if !can(moderator, permissionManageRoles) {
+		cfg.recordAudit(r, audit.Event{
+			Action:  auditChangeStatus,
+			Target:  userTarget(target.ID),
+			Outcome: audit.OutcomeDenied,
+			Details: map[string]string{"status": string(status), "reason": "target has roles"},
+		})
+		respondWithError(w, http.StatusForbidden, "You don't have permission to change the status of staff")
+		return false
+	}
+	return true
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"internal/audit"
 	"internal/database"
 	"net/http"
 	"strings"
@@ -29,6 +30,11 @@ func (cfg *apiConfig) polkaWebhookHandler(w http.ResponseWriter, r *http.Request
 	authHeader := r.Header.Get("Authorization")
 	apiKey := strings.Replace(authHeader, "ApiKey ", "", 1)
 	if apiKey != cfg.polkaApiKey {
+		cfg.recordAudit(r, audit.Event{
+			Action:  auditPolkaUpgrade,
+			Outcome: audit.OutcomeDenied,
+			Details: map[string]string{"reason": "invalid api key"},
+		})
 		respondWithError(w, http.StatusUnauthorized, "Invalid api key")
 		return
 	}
@@ -46,6 +52,16 @@ func (cfg *apiConfig) polkaWebhookHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	err = cfg.database.UpgradeUser(params.Data.UserID)
+	event := audit.Event{
+		Action:  auditPolkaUpgrade,
+		Target:  userTarget(params.Data.UserID),
+		Outcome: audit.OutcomeSuccess,
+	}
+	if err != nil {
+		event.Outcome = audit.OutcomeFailure
+		event.Details = map[string]string{"reason": err.Error()}
+	}
+	cfg.recordAudit(r, event)
 	if err != nil {
 		if errors.Is(err, database.ErrNotExist) {
 			respondWithError(w, http.StatusNotFound, "User not found")
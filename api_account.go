@@ -0,0 +1,178 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"internal/audit"
+	"internal/auth"
+	"internal/database"
+	"log"
+	"net/http"
+	"time"
+)
+
+const accountPurgeInterval = time.Hour
+
+// exportUserHandler sends the caller a zip archive of their profile,
+// chirps, drafts, likes, poll votes, bookmarks, lists and follows, one JSON
+// file each.
+func (cfg *apiConfig) exportUserHandler(w http.ResponseWriter, r *http.Request) {
+	export, err := cfg.database.ExportUser(userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't export user")
+		return
+	}
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.User},
+		{"chirps.json", struct {
+			Chirps    []database.Chirp                 `json:"chirps"`
+			Revisions map[int][]database.ChirpRevision `json:"revisions"`
+		}{export.Chirps, export.Revisions}},
+		{"drafts.json", export.Drafts},
+		{"likes.json", export.Likes},
+		{"poll_votes.json", export.PollVotes},
+		{"bookmarks.json", export.Bookmarks},
+		{"lists.json", export.Lists},
+		{"follows.json", struct {
+			Following []database.UserEntry `json:"following"`
+			Followers []database.UserEntry `json:"followers"`
+		}{export.Following, export.Followers}},
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%d.zip"`, export.User.ID))
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			log.Printf("Couldn't export user %d: %v", export.User.ID, err)
+			return
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(file.data)
+		if err != nil {
+			log.Printf("Couldn't export user %d: %v", export.User.ID, err)
+			return
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		log.Printf("Couldn't export user %d: %v", export.User.ID, err)
+		return
+	}
+	cfg.recordAudit(r, audit.Event{
+		Action:  auditExportAccount,
+		Target:  userTarget(export.User.ID),
+		Outcome: audit.OutcomeSuccess,
+	})
+}
+
+// deleteUserHandler schedules the caller's account for deletion after the
+// password is entered again. Every token is revoked at once, and the
+// account is purged after the cooling-off period unless the user signs in
+// again before then.
+func (cfg *apiConfig) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+	type response struct {
+		ID                  int                     `json:"id"`
+		DeletionScheduledAt time.Time               `json:"deletion_scheduled_at"`
+		Policy              database.DeletionPolicy `json:"policy"`
+	}
+
+	params := parameters{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	user, err := cfg.database.GetUser(userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		return
+	}
+	err = auth.CheckPasswordHash(params.Password, user.Password)
+	if err != nil {
+		cfg.recordAudit(r, audit.Event{
+			Action:  auditDeleteAccount,
+			Target:  userTarget(user.ID),
+			Outcome: audit.OutcomeFailure,
+			Details: map[string]string{"reason": "wrong password"},
+		})
+		respondWithError(w, http.StatusUnauthorized, "Password is not correct")
+		return
+	}
+
+	user, err = cfg.database.RequestDeletion(user.ID, cfg.deletionCoolingOff)
+	if err != nil {
+		if errors.Is(err, database.ErrDeletionPending) {
+			respondWithError(w, http.StatusConflict, "Account is already pending deletion")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't delete user")
+		}
+		return
+	}
+	cfg.recordAudit(r, audit.Event{
+		Action:  auditDeleteAccount,
+		Target:  userTarget(user.ID),
+		Outcome: audit.OutcomeSuccess,
+		Details: map[string]string{"purge_after": user.DeletionScheduledAt.Format(time.RFC3339)},
+	})
+	respondWithJson(w, http.StatusAccepted, response{
+		ID:                  user.ID,
+		DeletionScheduledAt: *user.DeletionScheduledAt,
+		Policy:              cfg.deletionPolicy,
+	})
+}
+
+// runAccountPurge purges accounts whose cooling-off period has passed,
+// once at start and then every accountPurgeInterval until ctx is done.
+func (cfg *apiConfig) runAccountPurge(ctx context.Context) {
+	ticker := time.NewTicker(accountPurgeInterval)
+	defer ticker.Stop()
+	for {
+		cfg.purgeAccounts(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) purgeAccounts(now time.Time) {
+	result, err := cfg.database.PurgeDeletedUsers(now, cfg.deletionPolicy)
+	if err != nil {
+		log.Printf("Couldn't purge deleted accounts: %v", err)
+		return
+	}
+	for _, id := range result.UserIDs {
+		cfg.recordAudit(nil, audit.Event{
+			Action:  auditPurgeAccount,
+			Target:  userTarget(id),
+			Outcome: audit.OutcomeSuccess,
+			Details: map[string]string{"policy": string(cfg.deletionPolicy)},
+		})
+	}
+	for _, chirp := range result.DeletedChirps {
+		cfg.publishChirpDeleted(chirp)
+	}
+	for _, key := range result.OrphanedKeys {
+		err := cfg.blobStore.Delete(key)
+		if err != nil {
+			log.Printf("Couldn't delete blob %s: %v", key, err)
+		}
+	}
+	if len(result.UserIDs) > 0 {
+		log.Printf("Purged %d deleted accounts", len(result.UserIDs))
+	}
+}
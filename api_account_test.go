@@ -0,0 +1,119 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"internal/auth"
+	"internal/database"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestExportUser(t *testing.T) {
+	cfg := newTestConfig(t)
+	token := newTestUser(t, cfg, "user@example.com")
+	if _, err := cfg.database.CreateChirp(database.Chirp{Body: "hello", AuthorId: 1}); err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	resp := server.send("GET", "/api/users/me/export", token, "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" {
+		t.Fatalf("Expected a zip archive, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Couldn't read response: %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("Couldn't open archive: %v", err)
+	}
+
+	files := make(map[string][]byte)
+	for _, file := range archive.File {
+		f, err := file.Open()
+		if err != nil {
+			t.Fatalf("Couldn't open %s: %v", file.Name, err)
+		}
+		files[file.Name], err = io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("Couldn't read %s: %v", file.Name, err)
+		}
+	}
+	for _, name := range []string{"profile.json", "chirps.json", "drafts.json", "likes.json", "poll_votes.json", "bookmarks.json", "lists.json", "follows.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected %s in the archive", name)
+		}
+	}
+	profile := database.User{}
+	if err := json.Unmarshal(files["profile.json"], &profile); err != nil {
+		t.Fatalf("Couldn't decode profile: %v", err)
+	}
+	if profile.Email != "user@example.com" || profile.Password != "" {
+		t.Errorf("Expected the profile without the password, got %+v", profile)
+	}
+	chirps := struct {
+		Chirps []database.Chirp `json:"chirps"`
+	}{}
+	if err := json.Unmarshal(files["chirps.json"], &chirps); err != nil {
+		t.Fatalf("Couldn't decode chirps: %v", err)
+	}
+	if len(chirps.Chirps) != 1 || chirps.Chirps[0].Body != "hello" {
+		t.Errorf("Expected the user's chirp, got %+v", chirps.Chirps)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	cfg := newTestConfig(t)
+	password, err := auth.HashPassword("password")
+	if err != nil {
+		t.Fatalf("Couldn't hash password: %v", err)
+	}
+	user, err := cfg.database.CreateUser("user@example.com", password)
+	if err != nil {
+		t.Fatalf("Couldn't create user: %v", err)
+	}
+	accessToken, refreshToken, err := auth.GenerateJWTTokens(user.ID, nil, cfg.jwtSecret)
+	if err != nil {
+		t.Fatalf("Couldn't generate tokens: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	if resp := server.send("DELETE", "/api/users/me", accessToken, `{"password":"wrong"}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong password to return 401, got %d", resp.StatusCode)
+	}
+	if resp := server.send("DELETE", "/api/users/me", accessToken, `{"password":"password"}`); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected deletion to be accepted, got %d", resp.StatusCode)
+	}
+	if resp := server.send("GET", "/api/notifications", accessToken, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected the access token to be revoked, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/api/refresh", refreshToken, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected the refresh token to be revoked, got %d", resp.StatusCode)
+	}
+	if resp := server.send("GET", "/api/users/1", "", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the profile to be hidden, got %d", resp.StatusCode)
+	}
+
+	resp := server.send("POST", "/api/login", "", `{"email":"user@example.com","password":"password"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected signing in to cancel the deletion, got %d", resp.StatusCode)
+	}
+	login := struct {
+		Token string `json:"token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		t.Fatalf("Couldn't decode login: %v", err)
+	}
+	if resp := server.send("GET", "/api/notifications", login.Token, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the new token to work, got %d", resp.StatusCode)
+	}
+	user, err = cfg.database.GetUser(user.ID)
+	if err != nil || user.PendingDeletion() {
+		t.Errorf("Expected the deletion to be cancelled, got %+v %v", user, err)
+	}
+}
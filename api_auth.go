@@ -1,9 +1,12 @@
 package main
 
 import (
+	"internal/audit"
 	"internal/auth"
+	"internal/database"
 	"log"
 	"net/http"
+	"time"
 )
 
 func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
@@ -13,11 +16,12 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 	}
 	type response struct {
 		// database.User
-		ID           int    `json:"id"`
-		Email        string `json:"email"`
-		IsChirpyRed  bool   `json:"is_chirpy_red"`
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
+		ID           int             `json:"id"`
+		Email        string          `json:"email"`
+		IsChirpyRed  bool            `json:"is_chirpy_red"`
+		Roles        []database.Role `json:"roles,omitempty"`
+		Token        string          `json:"token"`
+		RefreshToken string          `json:"refresh_token"`
 	}
 
 	params := parameters{}
@@ -29,27 +33,56 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 
 	user, err := cfg.database.GetUserByEmail(params.Email)
 	if err != nil {
+		cfg.recordAudit(r, audit.Event{
+			Action:  auditLogin,
+			Outcome: audit.OutcomeFailure,
+			Details: map[string]string{"email": params.Email, "reason": "unknown email"},
+		})
 		respondWithError(w, http.StatusBadRequest, "User does not exist")
 		return
 	}
+	event := audit.Event{ActorID: user.ID, Action: auditLogin, Target: userTarget(user.ID)}
 
 	err = auth.CheckPasswordHash(params.Password, user.Password)
 	if err != nil {
+		event.Outcome = audit.OutcomeFailure
+		event.Details = map[string]string{"reason": "wrong password"}
+		cfg.recordAudit(r, event)
 		respondWithError(w, http.StatusUnauthorized, "Password is not correct")
 		return
 	}
+	err = checkAccountStatus(user)
+	if err != nil {
+		event.Outcome = audit.OutcomeDenied
+		event.Details = map[string]string{"reason": string(user.StatusAt(time.Now()))}
+		cfg.recordAudit(r, event)
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	// Signing in during the cooling-off period keeps the account.
+	if user.PendingDeletion() {
+		user, err = cfg.database.CancelDeletion(user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't cancel account deletion")
+			return
+		}
+		event.Details = map[string]string{"deletion": "cancelled"}
+	}
 
-	accessToken, refreshToken, err := auth.GenerateJWTTokens(user.ID, cfg.jwtSecret)
+	accessToken, refreshToken, err := auth.GenerateJWTTokens(user.ID, roleNames(user.Roles), cfg.jwtSecret)
 	if err != nil {
 		log.Print(err)
 		respondWithError(w, http.StatusInternalServerError, "Couldn't sign JWT Token")
 		return
 	}
+	event.Outcome = audit.OutcomeSuccess
+	cfg.recordAudit(r, event)
 
 	respondWithJson(w, http.StatusOK, response{
 		ID:           user.ID,
 		Email:        user.Email,
 		IsChirpyRed:  user.IsChirpyRed,
+		Roles:        user.Roles,
 		Token:        accessToken,
 		RefreshToken: refreshToken,
 	})
@@ -61,30 +94,22 @@ func (cfg *apiConfig) refreshJWTHandler(w http.ResponseWriter, r *http.Request)
 		Token string `json:"token"`
 	}
 
-	authHeader := r.Header.Get("Authorization")
-	token, err := auth.ValidateJWTToken(authHeader, cfg.jwtSecret)
+	user, token, err := cfg.authenticateToken(r.Header.Get("Authorization"), "chirpy-refresh")
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid JWT token")
-		return
-	}
-	issuer, err := token.Claims.GetIssuer()
-	if err != nil || issuer == "chirpy-access" {
-		respondWithError(w, http.StatusInternalServerError, "Invalid JWT token type")
+		respondWithAuthError(w, err)
 		return
 	}
-
 	isRevoked, err := cfg.database.GetTokenIsRevoked(token.Raw)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't check if token is revoked")
 		return
 	}
-
 	if isRevoked {
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	newAccessToken, err := auth.GenerateAccessTokenFromRefresh(token, cfg.jwtSecret)
+	newAccessToken, err := auth.GenerateAccessTokenFromRefresh(token, roleNames(user.Roles), cfg.jwtSecret)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't generate refreshed access token")
 		return
@@ -96,23 +121,24 @@ func (cfg *apiConfig) refreshJWTHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (cfg *apiConfig) revokeJWTHandler(w http.ResponseWriter, r *http.Request) {
-	authHeader := r.Header.Get("Authorization")
-	token, err := auth.ValidateJWTToken(authHeader, cfg.jwtSecret)
+	token, err := cfg.parseToken(r.Header.Get("Authorization"), "chirpy-refresh")
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Invalid JWT token")
 		return
 	}
-	issuer, err := token.Claims.GetIssuer()
-	if err != nil || issuer == "chirpy-access" {
-		respondWithError(w, http.StatusInternalServerError, "Invalid JWT token type")
-		return
-	}
 
 	err = cfg.database.AddRevokedToken(token.Raw)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke token")
 		return
 	}
+	userID, _ := auth.GetUserFromTokenClaims(token)
+	cfg.recordAudit(r, audit.Event{
+		ActorID: userID,
+		Action:  auditRevokeToken,
+		Target:  userTarget(userID),
+		Outcome: audit.OutcomeSuccess,
+	})
 
 	w.WriteHeader(200)
 }
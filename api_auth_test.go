@@ -0,0 +1,69 @@
+package main
+
+import (
+	"internal/auth"
+	"internal/database"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRestrictedUsersCantAuthenticate(t *testing.T) {
+	cfg := newTestConfig(t)
+	password, err := auth.HashPassword("password")
+	if err != nil {
+		t.Fatalf("Couldn't hash password: %v", err)
+	}
+	user, err := cfg.database.CreateUser("user@example.com", password)
+	if err != nil {
+		t.Fatalf("Couldn't create user: %v", err)
+	}
+	accessToken, refreshToken, err := auth.GenerateJWTTokens(user.ID, nil, cfg.jwtSecret)
+	if err != nil {
+		t.Fatalf("Couldn't generate tokens: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	login := `{"email":"user@example.com","password":"password"}`
+	check := func(want int) {
+		t.Helper()
+		if got := server.send("POST", "/api/login", "", login).StatusCode; got != want {
+			t.Errorf("Expected login to return %d, got %d", want, got)
+		}
+		if got := server.send("POST", "/api/refresh", refreshToken, "").StatusCode; got != want {
+			t.Errorf("Expected refresh to return %d, got %d", want, got)
+		}
+		if got := server.send("GET", "/api/notifications", accessToken, "").StatusCode; got != want {
+			t.Errorf("Expected an authenticated route to return %d, got %d", want, got)
+		}
+	}
+
+	check(http.StatusOK)
+	chirp, err := cfg.database.CreateChirp(database.Chirp{Body: "hello", AuthorId: user.ID})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+
+	until := time.Now().UTC().Add(time.Hour)
+	if _, err := cfg.database.SetUserStatus(user.ID, 0, database.UserSuspended, "cool off", &until); err != nil {
+		t.Fatalf("Couldn't suspend user: %v", err)
+	}
+	check(http.StatusForbidden)
+	if got := server.send("POST", "/api/chirps", accessToken, `{"body":"still here"}`).StatusCode; got != http.StatusForbidden {
+		t.Errorf("Expected creating a chirp to return 403, got %d", got)
+	}
+	if got := server.send("GET", "/api/chirps/"+strconv.Itoa(chirp.ID), "", "").StatusCode; got != http.StatusNotFound {
+		t.Errorf("Expected the suspended user's chirp to be hidden, got %d", got)
+	}
+
+	if _, err := cfg.database.SetUserStatus(user.ID, 0, database.UserBanned, "spam", nil); err != nil {
+		t.Fatalf("Couldn't ban user: %v", err)
+	}
+	check(http.StatusForbidden)
+
+	if _, err := cfg.database.SetUserStatus(user.ID, 0, database.UserActive, "appeal", nil); err != nil {
+		t.Fatalf("Couldn't reinstate user: %v", err)
+	}
+	check(http.StatusOK)
+}
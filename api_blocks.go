@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"internal/database"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type blockResponse struct {
+	UserID   int  `json:"user_id"`
+	Blocking bool `json:"blocking"`
+}
+
+type muteResponse struct {
+	UserID int  `json:"user_id"`
+	Muting bool `json:"muting"`
+}
+
+func (cfg *apiConfig) blockHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateRelationshipHandler(w, r, "block", cfg.database.Block, func(userID int) interface{} {
+		return blockResponse{UserID: userID, Blocking: true}
+	})
+}
+
+func (cfg *apiConfig) unblockHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateRelationshipHandler(w, r, "block", cfg.database.Unblock, func(userID int) interface{} {
+		return blockResponse{UserID: userID, Blocking: false}
+	})
+}
+
+func (cfg *apiConfig) muteHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateRelationshipHandler(w, r, "mute", cfg.database.Mute, func(userID int) interface{} {
+		return muteResponse{UserID: userID, Muting: true}
+	})
+}
+
+func (cfg *apiConfig) unmuteHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateRelationshipHandler(w, r, "mute", cfg.database.Unmute, func(userID int) interface{} {
+		return muteResponse{UserID: userID, Muting: false}
+	})
+}
+
+func (cfg *apiConfig) updateRelationshipHandler(w http.ResponseWriter, r *http.Request, action string, apply func(userID, targetID int) error, respond func(targetID int) interface{}) {
+	userId := userIDFromContext(r.Context())
+
+	target, err := cfg.resolveUser(chi.URLParam(r, "user"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "User not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		}
+		return
+	}
+	if target.ID == userId {
+		respondWithError(w, http.StatusBadRequest, "You can't "+action+" yourself")
+		return
+	}
+
+	err = apply(userId, target.ID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "User not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update "+action)
+		}
+		return
+	}
+	respondWithJson(w, http.StatusOK, respond(target.ID))
+}
+
+func (cfg *apiConfig) getBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.relationshipListHandler(w, r, cfg.database.GetBlocks, "Couldn't get blocked users")
+}
+
+func (cfg *apiConfig) getMutesHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.relationshipListHandler(w, r, cfg.database.GetMutes, "Couldn't get muted users")
+}
+
+func (cfg *apiConfig) relationshipListHandler(w http.ResponseWriter, r *http.Request, list func(userID int, cursor string, limit int) (database.UserPage, error), failure string) {
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	page, err := list(userIDFromContext(r.Context()), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, failure)
+		}
+		return
+	}
+	entries, err := cfg.presentUserEntries(page.Entries)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, failure)
+		return
+	}
+	respondWithPage(w, r, entries, page.NextCursor)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"internal/database"
+	"net/http"
+	"testing"
+)
+
+func TestBlockRules(t *testing.T) {
+	cfg := newTestConfig(t)
+	blockerToken := newTestUser(t, cfg, "blocker@example.com")
+	blockedToken := newTestUser(t, cfg, "blocked@example.com")
+	newTestUser(t, cfg, "bystander@example.com")
+	for id, username := range map[int]string{1: "blocker", 3: "bystander"} {
+		if _, err := cfg.database.UpdateProfile(id, database.Profile{Username: username}); err != nil {
+			t.Fatalf("Couldn't update profile: %v", err)
+		}
+	}
+	if _, err := cfg.database.CreateChirp(database.Chirp{Body: "hello", AuthorId: 1}); err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	if resp := server.send("POST", "/api/users/1/block", blockerToken, ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected blocking yourself to return 400, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/api/users/2/block", blockerToken, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected blocking to succeed, got %d", resp.StatusCode)
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"reply to blocker", "POST", "/api/chirps", blockedToken, `{"body":"hi","in_reply_to":1}`, http.StatusNotFound},
+		{"message blocker", "POST", "/api/conversations", blockedToken, `{"participant_ids":[1]}`, http.StatusForbidden},
+		{"message blocked user", "POST", "/api/conversations", blockerToken, `{"participant_ids":[2]}`, http.StatusForbidden},
+		{"group with blocker", "POST", "/api/conversations", blockedToken, `{"participant_ids":[1,3]}`, http.StatusForbidden},
+		{"follow blocker", "POST", "/api/users/1/follow", blockedToken, "", http.StatusNotFound},
+		{"follow blocked user", "POST", "/api/users/2/follow", blockerToken, "", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if resp := server.send(c.method, c.path, c.token, c.body); resp.StatusCode != c.status {
+				t.Errorf("Expected %d, got %d", c.status, resp.StatusCode)
+			}
+		})
+	}
+
+	if resp := server.send("POST", "/api/chirps", blockedToken, `{"body":"hi @blocker and @bystander"}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected mentioning a blocker to still post, got %d", resp.StatusCode)
+	}
+	chirp, err := cfg.database.GetChirp(2)
+	if err != nil {
+		t.Fatalf("Couldn't get chirp: %v", err)
+	}
+	mentioned := []int{}
+	for _, entity := range chirp.Entities {
+		if entity.Type == database.EntityMention {
+			mentioned = append(mentioned, entity.UserID)
+		}
+	}
+	if len(mentioned) != 1 || mentioned[0] != 3 {
+		t.Errorf("Expected only the bystander's mention to be linked, got %v", mentioned)
+	}
+	for userID, want := range map[int]int{1: 0, 3: 1} {
+		page, err := cfg.database.GetNotifications(userID, "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't get notifications: %v", err)
+		}
+		if len(page.Groups) != want {
+			t.Errorf("Expected user %d to get %d notifications, got %+v", userID, want, page.Groups)
+		}
+	}
+
+	if resp := server.send("DELETE", "/api/users/2/block", blockerToken, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected unblocking to succeed, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/api/chirps", blockedToken, `{"body":"hi","in_reply_to":1}`); resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected replying after an unblock to succeed, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/api/conversations", blockedToken, `{"participant_ids":[1]}`); resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected messaging after an unblock to succeed, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"internal/database"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (cfg *apiConfig) addBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.bookmarkHandler(w, r, cfg.database.AddBookmark)
+}
+
+func (cfg *apiConfig) removeBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.bookmarkHandler(w, r, cfg.database.RemoveBookmark)
+}
+
+func (cfg *apiConfig) bookmarkHandler(w http.ResponseWriter, r *http.Request, apply func(userID, chirpID int) error) {
+	id, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+	err = apply(userIDFromContext(r.Context()), id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update bookmark")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getBookmarksHandler lists the caller's bookmarked chirps, most recently
+// bookmarked first.
+func (cfg *apiConfig) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	userId := userIDFromContext(r.Context())
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	page, err := cfg.database.GetBookmarks(userId, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get bookmarks")
+		}
+		return
+	}
+
+	responseChirps, err := cfg.presentChirps(page.Chirps, userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get bookmarks")
+		return
+	}
+	respondWithPage(w, r, responseChirps, page.NextCursor)
+}
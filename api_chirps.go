@@ -2,64 +2,179 @@ package main
 
 import (
 	"errors"
-	"internal/auth"
+	"fmt"
+	"internal/audit"
 	"internal/database"
+	"internal/moderation"
 	"net/http"
-	"sort"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
-func (cfg *apiConfig) createChirpsHandler(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Body string `json:"body"`
+const maxChirpLength = 140
+
+var errChirpTooLong = errors.New("chirp is too long")
+
+// moderateChirpBody checks a chirp body's length and runs it through the
+// moderation pipeline, returning the body to store and the hold to place on
+// the chirp, if any. Rejected bodies return an error with the reason.
+func (cfg *apiConfig) moderateChirpBody(body string) (string, *database.ChirpHold, error) {
+	if len(body) > maxChirpLength {
+		return "", nil, errChirpTooLong
+	}
+	decision := cfg.moderator.Moderate(body)
+	switch decision.Action {
+	case moderation.ActionReject:
+		return "", nil, fmt.Errorf("chirp was rejected: %s", decision.Reason)
+	case moderation.ActionHold:
+		return decision.Body, &database.ChirpHold{Reason: decision.Reason}, nil
+	}
+	return decision.Body, nil, nil
+}
+
+// chirpInput is what an author writes, whether the chirp is published
+// now, scheduled or kept as a draft.
+type chirpInput struct {
+	Body       string              `json:"body"`
+	InReplyTo  int                 `json:"in_reply_to"`
+	QuoteOf    int                 `json:"quote_of"`
+	MediaIDs   []int               `json:"media_ids"`
+	Visibility database.Visibility `json:"visibility"`
+	Poll       *pollInput          `json:"poll"`
+}
+
+// invalidChirpError is a chirp its author has to change before it can be
+// published. The message is meant for the author.
+type invalidChirpError struct {
+	err error
+}
+
+func (e invalidChirpError) Error() string {
+	return e.err.Error()
+}
+
+func (e invalidChirpError) Unwrap() error {
+	return e.err
+}
+
+// respondWithInvalidChirp tells the author why their chirp can't be
+// published.
+func respondWithInvalidChirp(w http.ResponseWriter, err error) {
+	respondWithError(w, http.StatusBadRequest, "Invalid chirp: "+err.Error())
+}
+
+// checkChirpInput validates everything about input but its moderation, so
+// drafts can be checked without being moderated.
+func (cfg *apiConfig) checkChirpInput(authorID int, input chirpInput) error {
+	if len(input.Body) > maxChirpLength {
+		return invalidChirpError{errChirpTooLong}
 	}
+	if input.Visibility != "" && !input.Visibility.Valid() {
+		return invalidChirpError{errors.New("invalid visibility, expected one of public, followers, unlisted or mentioned")}
+	}
+	err := cfg.validateAttachments(input.MediaIDs, authorID)
+	if err != nil {
+		return invalidChirpError{err}
+	}
+	err = checkPoll(input.Poll, time.Now())
+	if err != nil {
+		return invalidChirpError{err}
+	}
+	return nil
+}
 
-	authHeader := r.Header.Get("Authorization")
-	token, err := auth.ValidateJWTToken(authHeader, cfg.jwtSecret)
+// prepareChirp checks and moderates input, returning the chirp to create.
+// Every chirp goes through here before it is published, whether directly,
+// from a draft or by the scheduler.
+func (cfg *apiConfig) prepareChirp(authorID int, input chirpInput) (database.Chirp, error) {
+	cleanedBody, hold, err := cfg.moderateChirpBody(input.Body)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid JWT token")
-		return
+		return database.Chirp{}, invalidChirpError{err}
 	}
-	userId, err := auth.GetUserFromTokenClaims(token)
+	err = cfg.checkChirpInput(authorID, input)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error while decoding JWT token")
-		return
+		return database.Chirp{}, err
 	}
+	return database.Chirp{
+		Body:       cleanedBody,
+		AuthorId:   authorID,
+		InReplyTo:  input.InReplyTo,
+		QuoteOf:    input.QuoteOf,
+		MediaIDs:   input.MediaIDs,
+		Visibility: input.Visibility,
+		Poll:       input.Poll.poll(),
+		Hold:       hold,
+	}, nil
+}
+
+// missingChirpMessage explains an ErrNotExist from creating a chirp, which
+// is about either its parent or the chirp it quotes.
+func missingChirpMessage(err error) string {
+	if errors.Is(err, database.ErrQuotedChirpNotExist) {
+		return "couldn't find the chirp being quoted"
+	}
+	return "couldn't find the chirp being replied to"
+}
+
+// createChirpsHandler publishes a chirp, or schedules it when publish_at
+// is given.
+func (cfg *apiConfig) createChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		chirpInput
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	userId := userIDFromContext(r.Context())
 
 	params := parameters{}
-	params, err = decodeJsonBody(r.Body, params)
+	params, err := decodeJsonBody(r.Body, params)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
 		return
 	}
 
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
+	prepared, err := cfg.prepareChirp(userId, params.chirpInput)
+	if err != nil {
+		respondWithInvalidChirp(w, err)
+		return
+	}
+	if params.PublishAt != nil {
+		cfg.scheduleChirp(w, userId, params.chirpInput, *params.PublishAt)
 		return
 	}
 
-	cleanedBody := cleanBody(params.Body)
-
-	chirp, err := cfg.database.CreateChirp(cleanedBody, userId)
+	chirp, err := cfg.database.CreateChirp(prepared)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp")
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Invalid chirp: "+missingChirpMessage(err))
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp")
+		}
 		return
 	}
 
-	respondWithJson(w, http.StatusCreated, chirp)
+	response, err := cfg.presentChirp(chirp, userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp")
+		return
+	}
+	if chirp.Hold != nil {
+		respondWithJson(w, http.StatusAccepted, response)
+		return
+	}
+	cfg.publishChirpCreated(chirp)
+	respondWithJson(w, http.StatusCreated, response)
 }
 
 func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
-	chirps, err := cfg.database.GetChirps()
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps")
-		return
+	query := database.ChirpQuery{
+		ViewerID: userIDFromContext(r.Context()),
+		Sort:     database.ChirpSort(r.URL.Query().Get("sort")),
+		Cursor:   r.URL.Query().Get("cursor"),
 	}
 
-	responseChirps := chirps
 	authorParam := r.URL.Query().Get("author_id")
 	if authorParam != "" {
 		authorId, err := strconv.Atoi(authorParam)
@@ -67,28 +182,51 @@ func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
 			respondWithError(w, http.StatusBadRequest, "Invalid author id")
 			return
 		}
-		responseChirps = make([]database.Chirp, 0)
-		for _, chirp := range chirps {
-			if chirp.AuthorId == authorId {
-				responseChirps = append(responseChirps, chirp)
-			}
-		}
+		query.AuthorIDs = []int{authorId}
 	}
 
-	sortDirection := "asc"
-	sortParam := r.URL.Query().Get("sort")
-	if sortParam != "" {
-		sortDirection = sortParam
+	var err error
+	query.Since, err = parseTimeParam(r, "since")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid since, expected an RFC 3339 time")
+		return
+	}
+	query.Until, err = parseTimeParam(r, "until")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid until, expected an RFC 3339 time")
+		return
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && query.Since.After(query.Until) {
+		respondWithError(w, http.StatusBadRequest, "Invalid time range, since must not be after until")
+		return
+	}
+	query.Limit, err = parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
 	}
 
-	sort.Slice(responseChirps, func(i, j int) bool {
-		a, b := responseChirps[i], responseChirps[j]
-		if sortDirection == "asc" {
-			return a.ID < b.ID
+	page, err := cfg.database.QueryChirps(query)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrInvalidSort):
+			respondWithError(w, http.StatusBadRequest, "Invalid sort, expected one of asc, desc, created_at or -created_at")
+		case errors.Is(err, database.ErrInvalidCursor):
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps")
 		}
-		return a.ID > b.ID
-	})
+		return
+	}
 
+	responseChirps, err := cfg.presentChirps(page.Chirps, userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps")
+		return
+	}
+	// This route predates pagination and clients expect a bare array, so the
+	// next page is only advertised in the Link header.
+	setNextLink(w, r, page.NextCursor)
 	respondWithJson(w, http.StatusOK, responseChirps)
 }
 
@@ -99,7 +237,7 @@ func (cfg *apiConfig) getSingleChirpHandler(w http.ResponseWriter, r *http.Reque
 		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
 		return
 	}
-	chirps, err := cfg.database.GetChirp(id)
+	chirp, err := cfg.database.GetChirpForViewer(id, userIDFromContext(r.Context()))
 	if err != nil {
 		if errors.Is(err, database.ErrNotExist) {
 			respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
@@ -109,21 +247,16 @@ func (cfg *apiConfig) getSingleChirpHandler(w http.ResponseWriter, r *http.Reque
 			return
 		}
 	}
-	respondWithJson(w, http.StatusOK, chirps)
-}
-
-func (cfg *apiConfig) deleteSingleChirpHandler(w http.ResponseWriter, r *http.Request) {
-	authHeader := r.Header.Get("Authorization")
-	token, err := auth.ValidateJWTToken(authHeader, cfg.jwtSecret)
+	response, err := cfg.presentChirp(chirp, userIDFromContext(r.Context()))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid JWT token")
-		return
-	}
-	userId, err := auth.GetUserFromTokenClaims(token)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error while decoding JWT token")
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp")
 		return
 	}
+	respondWithJson(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) deleteSingleChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userId := userIDFromContext(r.Context())
 
 	chirpID := chi.URLParam(r, "chirpID")
 	id, err := strconv.Atoi(chirpID)
@@ -152,6 +285,102 @@ func (cfg *apiConfig) deleteSingleChirpHandler(w http.ResponseWriter, r *http.Re
 		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp")
 		return
 	}
+	cfg.recordAudit(r, audit.Event{
+		Action:  auditDeleteChirp,
+		Target:  chirpTarget(id),
+		Outcome: audit.OutcomeSuccess,
+	})
+	cfg.publishChirpDeleted(chirp)
 
 	respondWithJson(w, http.StatusOK, chirp)
 }
+
+func (cfg *apiConfig) updateChirpHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	userId := userIDFromContext(r.Context())
+
+	chirpID := chi.URLParam(r, "chirpID")
+	id, err := strconv.Atoi(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+	chirp, err := cfg.database.GetChirp(id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp")
+		}
+		return
+	}
+
+	if chirp.AuthorId != userId {
+		respondWithError(w, http.StatusForbidden, "You are not allowed to edit chirps from other users")
+		return
+	}
+
+	user, err := cfg.database.GetUser(userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		return
+	}
+	if !cfg.chirpEditPlans[userPlan(user)] {
+		respondWithError(w, http.StatusForbidden, "Your plan does not allow editing chirps")
+		return
+	}
+	// A chirp without a creation time is of unknown age, so the window
+	// doesn't apply to it.
+	if cfg.chirpEditWindow > 0 && !chirp.CreatedAt.IsZero() && time.Since(chirp.CreatedAt) > cfg.chirpEditWindow {
+		respondWithError(w, http.StatusForbidden, "The edit window for this chirp has closed")
+		return
+	}
+
+	params := parameters{}
+	params, err = decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	cleanedBody, hold, err := cfg.moderateChirpBody(params.Body)
+	if err != nil {
+		respondWithInvalidChirp(w, err)
+		return
+	}
+
+	chirp, err = cfg.database.UpdateChirp(id, cleanedBody, hold)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp")
+		return
+	}
+
+	response, err := cfg.presentChirp(chirp, userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp")
+		return
+	}
+	respondWithJson(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) getChirpRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID := chi.URLParam(r, "chirpID")
+	id, err := strconv.Atoi(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+	revisions, err := cfg.database.GetChirpRevisions(id, userIDFromContext(r.Context()))
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp revisions")
+		}
+		return
+	}
+	respondWithJson(w, http.StatusOK, revisions)
+}
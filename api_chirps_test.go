@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"internal/database"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModerateChirpBody(t *testing.T) {
+	cfg := newTestConfig(t)
+	cases := []struct{ body, cleaned string }{
+		{
+			body:    "Today is such a kerfuffle day",
+			cleaned: "Today is such a **** day",
+		},
+		{
+			body:    "I like turtles",
+			cleaned: "I like turtles",
+		},
+		{
+			body:    "Everybody keeps telling me to say sharbert and fornax today, but I won't",
+			cleaned: "Everybody keeps telling me to say **** and **** today, but I won't",
+		},
+	}
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			cleanedBody, hold, err := cfg.moderateChirpBody(c.body)
+			if err != nil || hold != nil {
+				t.Fatalf("Expected the chirp to be published, got %+v, %v", hold, err)
+			}
+			if cleanedBody != c.cleaned {
+				t.Errorf("Expected %q, got %q", c.cleaned, cleanedBody)
+			}
+		})
+	}
+	if _, _, err := cfg.moderateChirpBody(strings.Repeat("a", maxChirpLength+1)); err != errChirpTooLong {
+		t.Errorf("Expected errChirpTooLong, got %v", err)
+	}
+}
+
+func TestEditChirp(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.chirpEditWindow = time.Hour
+	cfg.chirpEditPlans = map[string]bool{planRed: true}
+	token := newTestUser(t, cfg, "author@example.com")
+	otherToken := newTestUser(t, cfg, "other@example.com")
+	if _, err := cfg.database.CreateChirp(database.Chirp{Body: "first draft", AuthorId: 1}); err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	if resp := server.send("PUT", "/api/chirps/1", token, `{"body":"second draft"}`); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected editing on the free plan to return 403, got %d", resp.StatusCode)
+	}
+	for _, id := range []int{1, 2} {
+		if err := cfg.database.UpgradeUser(id); err != nil {
+			t.Fatalf("Couldn't upgrade user: %v", err)
+		}
+	}
+	if resp := server.send("PUT", "/api/chirps/1", otherToken, `{"body":"not yours"}`); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected editing another user's chirp to return 403, got %d", resp.StatusCode)
+	}
+	if resp := server.send("PUT", "/api/chirps/2", token, `{"body":"missing"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected editing a missing chirp to return 404, got %d", resp.StatusCode)
+	}
+	resp := server.send("PUT", "/api/chirps/1", token, `{"body":"`+strings.Repeat("a", maxChirpLength+1)+`"}`)
+	errorResponse := struct {
+		Error string `json:"error"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
+		t.Fatalf("Couldn't decode error: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadRequest || errorResponse.Error != "Invalid chirp: chirp is too long" {
+		t.Errorf("Expected a long edit to return 400 Invalid chirp: chirp is too long, got %d %q", resp.StatusCode, errorResponse.Error)
+	}
+
+	for _, body := range []string{"second draft", "final draft"} {
+		if resp := server.send("PUT", "/api/chirps/1", token, `{"body":"`+body+`"}`); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected the chirp to be edited, got %d", resp.StatusCode)
+		}
+	}
+	chirp, err := cfg.database.GetChirp(1)
+	if err != nil || chirp.Body != "final draft" || chirp.EditedAt == nil {
+		t.Errorf("Expected the chirp to be edited, got %+v, %v", chirp, err)
+	}
+	resp = server.send("GET", "/api/chirps/1/revisions", "", "")
+	revisions := []database.ChirpRevision{}
+	if err := json.NewDecoder(resp.Body).Decode(&revisions); err != nil {
+		t.Fatalf("Couldn't decode revisions: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Body != "first draft" || revisions[1].Body != "second draft" {
+		t.Errorf("Expected the replaced bodies oldest first, got %+v", revisions)
+	}
+
+	cfg.chirpEditWindow = time.Nanosecond
+	if resp := server.send("PUT", "/api/chirps/1", token, `{"body":"too late"}`); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected editing after the window to return 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestGetChirpsTimeRange(t *testing.T) {
+	cfg := newTestConfig(t)
+	newTestUser(t, cfg, "author@example.com")
+	first, err := cfg.database.CreateChirp(database.Chirp{Body: "first", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := cfg.database.CreateChirp(database.Chirp{Body: "second", AuthorId: 1}); err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	get := func(query string) *http.Response {
+		t.Helper()
+		return server.send("GET", "/api/chirps?"+query, "", "")
+	}
+
+	cases := []struct {
+		name  string
+		query string
+	}{
+		{"invalid since", "since=yesterday"},
+		{"invalid until", "until=2024-13-01T00:00:00Z"},
+		{"inverted range", "since=2024-02-01T00:00:00Z&until=2024-01-01T00:00:00Z"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if resp := get(c.query); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d", resp.StatusCode)
+			}
+		})
+	}
+
+	until := first.CreatedAt.Add(time.Millisecond).Format(time.RFC3339Nano)
+	resp := get("until=" + until)
+	chirps := []chirpResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&chirps); err != nil {
+		t.Fatalf("Couldn't decode chirps: %v", err)
+	}
+	if len(chirps) != 1 || chirps[0].ID != first.ID {
+		t.Errorf("Expected only the first chirp before until, got %+v", chirps)
+	}
+
+	resp = get("limit=1")
+	chirps = []chirpResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&chirps); err != nil {
+		t.Fatalf("Couldn't decode chirps: %v", err)
+	}
+	if len(chirps) != 1 || !strings.Contains(resp.Header.Get("Link"), `rel="next"`) {
+		t.Errorf("Expected one chirp and a next link, got %+v and %q", chirps, resp.Header.Get("Link"))
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"internal/database"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxMessageLength = 1000
+
+type readReceiptResponse struct {
+	UserID    int       `json:"user_id"`
+	MessageID int       `json:"message_id"`
+	ReadAt    time.Time `json:"read_at"`
+}
+
+type conversationResponse struct {
+	ID           int                   `json:"id"`
+	CreatorID    int                   `json:"creator_id"`
+	Participants []userSummary         `json:"participants"`
+	LastMessage  *database.Message     `json:"last_message"`
+	UnreadCount  int                   `json:"unread_count"`
+	ReadReceipts []readReceiptResponse `json:"read_receipts"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+}
+
+// presentConversations loads the participants of every conversation with a
+// single lookup.
+func (cfg *apiConfig) presentConversations(summaries []database.ConversationSummary) ([]conversationResponse, error) {
+	userIDs := make([]int, 0)
+	for _, summary := range summaries {
+		userIDs = append(userIDs, summary.ParticipantIDs...)
+	}
+	users, err := cfg.database.GetUsers(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]conversationResponse, 0, len(summaries))
+	for _, summary := range summaries {
+		participants := make([]userSummary, 0, len(summary.ParticipantIDs))
+		for _, userID := range summary.ParticipantIDs {
+			if user, ok := users[userID]; ok {
+				participants = append(participants, newUserSummary(user))
+			}
+		}
+		receipts := make([]readReceiptResponse, 0, len(summary.ReadReceipts))
+		for userID, receipt := range summary.ReadReceipts {
+			receipts = append(receipts, readReceiptResponse{
+				UserID:    userID,
+				MessageID: receipt.MessageID,
+				ReadAt:    receipt.ReadAt,
+			})
+		}
+		sort.Slice(receipts, func(i, j int) bool {
+			return receipts[i].UserID < receipts[j].UserID
+		})
+		responses = append(responses, conversationResponse{
+			ID:           summary.ID,
+			CreatorID:    summary.CreatorID,
+			Participants: participants,
+			LastMessage:  summary.LastMessage,
+			UnreadCount:  summary.UnreadCount,
+			ReadReceipts: receipts,
+			CreatedAt:    summary.CreatedAt,
+			UpdatedAt:    summary.UpdatedAt,
+		})
+	}
+	return responses, nil
+}
+
+func (cfg *apiConfig) presentConversation(summary database.ConversationSummary) (conversationResponse, error) {
+	responses, err := cfg.presentConversations([]database.ConversationSummary{summary})
+	if err != nil {
+		return conversationResponse{}, err
+	}
+	return responses[0], nil
+}
+
+// createConversationHandler starts a conversation between the caller and
+// participant_ids. Starting a one-to-one conversation that already exists
+// returns it with 200 instead of 201.
+func (cfg *apiConfig) createConversationHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ParticipantIDs []int `json:"participant_ids"`
+	}
+
+	userId := userIDFromContext(r.Context())
+	params, err := decodeJsonBody(r.Body, parameters{})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	conversation, created, err := cfg.database.CreateConversation(userId, params.ParticipantIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrInvalidParticipants):
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Conversations need between 2 and %d participants", database.MaxConversationParticipants))
+		case errors.Is(err, database.ErrNotExist):
+			respondWithError(w, http.StatusNotFound, "User not found")
+		case errors.Is(err, database.ErrBlocked):
+			respondWithError(w, http.StatusForbidden, "You can't message this user")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create conversation")
+		}
+		return
+	}
+
+	summary, err := cfg.database.GetConversation(conversation.ID, userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation")
+		return
+	}
+	response, err := cfg.presentConversation(summary)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation")
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	respondWithJson(w, status, response)
+}
+
+func (cfg *apiConfig) getConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		pageResponse
+		UnreadCount int `json:"unread_count"`
+	}
+
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	page, err := cfg.database.GetConversations(userIDFromContext(r.Context()), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get conversations")
+		}
+		return
+	}
+	conversations, err := cfg.presentConversations(page.Conversations)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversations")
+		return
+	}
+
+	setNextLink(w, r, page.NextCursor)
+	respondWithJson(w, http.StatusOK, response{
+		pageResponse: pageResponse{Data: conversations, NextCursor: page.NextCursor},
+		UnreadCount:  page.UnreadCount,
+	})
+}
+
+// conversationIDParam reads the {conversationID} route parameter, responding
+// with an error if it is invalid.
+func conversationIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation id")
+		return 0, false
+	}
+	return id, true
+}
+
+func (cfg *apiConfig) getConversationHandler(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := conversationIDParam(w, r)
+	if !ok {
+		return
+	}
+	summary, err := cfg.database.GetConversation(conversationID, userIDFromContext(r.Context()))
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Conversation not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation")
+		}
+		return
+	}
+	response, err := cfg.presentConversation(summary)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation")
+		return
+	}
+	respondWithJson(w, http.StatusOK, response)
+}
+
+// getMessagesHandler lists a conversation's messages, newest first.
+func (cfg *apiConfig) getMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := conversationIDParam(w, r)
+	if !ok {
+		return
+	}
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	page, err := cfg.database.GetMessages(conversationID, userIDFromContext(r.Context()), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotExist):
+			respondWithError(w, http.StatusNotFound, "Conversation not found")
+		case errors.Is(err, database.ErrInvalidCursor):
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get messages")
+		}
+		return
+	}
+	respondWithPage(w, r, page.Messages, page.NextCursor)
+}
+
+func (cfg *apiConfig) sendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	conversationID, ok := conversationIDParam(w, r)
+	if !ok {
+		return
+	}
+	params, err := decodeJsonBody(r.Body, parameters{})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	if strings.TrimSpace(params.Body) == "" {
+		respondWithError(w, http.StatusBadRequest, "Message is empty")
+		return
+	}
+	if len(params.Body) > maxMessageLength {
+		respondWithError(w, http.StatusBadRequest, "Message is too long")
+		return
+	}
+
+	message, conversation, err := cfg.database.SendMessage(conversationID, userIDFromContext(r.Context()), params.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotExist):
+			respondWithError(w, http.StatusNotFound, "Conversation not found")
+		case errors.Is(err, database.ErrBlocked):
+			respondWithError(w, http.StatusForbidden, "You can't message this conversation")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't send message")
+		}
+		return
+	}
+
+	cfg.publishMessageCreated(message, conversation)
+	respondWithJson(w, http.StatusCreated, message)
+}
+
+// markConversationReadHandler moves the caller's read receipt to message_id,
+// or to the newest message when the body is empty.
+func (cfg *apiConfig) markConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MessageID int `json:"message_id"`
+	}
+
+	conversationID, ok := conversationIDParam(w, r)
+	if !ok {
+		return
+	}
+	params := parameters{}
+	if r.ContentLength != 0 {
+		var err error
+		params, err = decodeJsonBody(r.Body, params)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+			return
+		}
+	}
+
+	userId := userIDFromContext(r.Context())
+	conversation, err := cfg.database.MarkConversationRead(conversationID, userId, params.MessageID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Conversation or message not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't mark conversation as read")
+		}
+		return
+	}
+	cfg.publishConversationRead(conversation, userId)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestConversationAccess(t *testing.T) {
+	cfg := newTestConfig(t)
+	senderToken := newTestUser(t, cfg, "sender@example.com")
+	recipientToken := newTestUser(t, cfg, "recipient@example.com")
+	outsiderToken := newTestUser(t, cfg, "outsider@example.com")
+	server := newTestServer(t, cfg)
+
+	if resp := server.send("POST", "/api/conversations", senderToken, `{"participant_ids":[2]}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected creating a conversation to return 201, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/api/conversations/1/messages", senderToken, `{"body":"hello"}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected sending a message to return 201, got %d", resp.StatusCode)
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"get conversation", "GET", "/api/conversations/1", ""},
+		{"get messages", "GET", "/api/conversations/1/messages", ""},
+		{"send message", "POST", "/api/conversations/1/messages", `{"body":"let me in"}`},
+		{"mark read", "POST", "/api/conversations/1/read", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if resp := server.send(c.method, c.path, outsiderToken, c.body); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected a non-participant to get 404, got %d", resp.StatusCode)
+			}
+			if resp := server.send(c.method, c.path, recipientToken, c.body); resp.StatusCode/100 != 2 {
+				t.Errorf("Expected a participant to succeed, got %d", resp.StatusCode)
+			}
+		})
+	}
+	page, err := cfg.database.GetMessages(1, 1, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't get messages: %v", err)
+	}
+	for _, message := range page.Messages {
+		if message.SenderID == 3 {
+			t.Errorf("Expected the non-participant's message to be dropped, got %+v", message)
+		}
+	}
+
+	if err := cfg.database.Block(2, 1); err != nil {
+		t.Fatalf("Couldn't block: %v", err)
+	}
+	if resp := server.send("POST", "/api/conversations/1/messages", senderToken, `{"body":"still there?"}`); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected messaging a participant who blocked you to return 403, got %d", resp.StatusCode)
+	}
+	if resp := server.send("GET", "/api/conversations/1", senderToken, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected a blocked participant to still read the conversation, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"internal/database"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// schedulerInterval is how often the scheduler looks for scheduled chirps
+// that are due, and so how late they may be published.
+const schedulerInterval = 10 * time.Second
+
+func draftInput(draft database.Draft) chirpInput {
+	return chirpInput{
+		Body:       draft.Body,
+		InReplyTo:  draft.InReplyTo,
+		QuoteOf:    draft.QuoteOf,
+		MediaIDs:   draft.MediaIDs,
+		Visibility: draft.Visibility,
+		Poll:       newPollInput(draft.Poll),
+	}
+}
+
+func newDraft(authorID int, input chirpInput, publishAt *time.Time) database.Draft {
+	return database.Draft{
+		AuthorID:   authorID,
+		Body:       input.Body,
+		InReplyTo:  input.InReplyTo,
+		QuoteOf:    input.QuoteOf,
+		MediaIDs:   input.MediaIDs,
+		Visibility: input.Visibility,
+		Poll:       input.Poll.poll(),
+		PublishAt:  publishAt,
+	}
+}
+
+// checkPublishAt checks that a chirp scheduled for publishAt is published in
+// the future, and before its poll closes.
+func checkPublishAt(publishAt time.Time, input chirpInput) error {
+	if !publishAt.After(time.Now()) {
+		return errors.New("publish_at must be in the future")
+	}
+	if input.Poll != nil && !input.Poll.ClosesAt.After(publishAt) {
+		return errors.New("poll must close after the chirp is published")
+	}
+	return nil
+}
+
+func respondWithDraftError(w http.ResponseWriter, err error) {
+	var invalid invalidChirpError
+	switch {
+	case errors.As(err, &invalid):
+		respondWithInvalidChirp(w, err)
+	case errors.Is(err, database.ErrNotExist):
+		respondWithError(w, http.StatusNotFound, "Couldn't find draft")
+	case errors.Is(err, database.ErrDraftChanged):
+		respondWithError(w, http.StatusConflict, "Draft changed while it was being published")
+	default:
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update draft")
+	}
+}
+
+// ownDraft returns the caller's draft named by the key route parameter,
+// but only if it is scheduled or not as asked.
+func (cfg *apiConfig) ownDraft(w http.ResponseWriter, r *http.Request, key string, scheduled bool) (database.Draft, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, key))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft id")
+		return database.Draft{}, false
+	}
+	draft, err := cfg.database.GetDraft(id, userIDFromContext(r.Context()))
+	if err == nil && draft.Scheduled() != scheduled {
+		err = database.ErrNotExist
+	}
+	if err != nil {
+		respondWithDraftError(w, err)
+		return database.Draft{}, false
+	}
+	return draft, true
+}
+
+// scheduleChirp stores input to be published at publishAt. It has already
+// been through prepareChirp, so obvious problems are caught now rather
+// than when it is due. It is moderated again when published, in case the
+// filters changed.
+func (cfg *apiConfig) scheduleChirp(w http.ResponseWriter, authorID int, input chirpInput, publishAt time.Time) {
+	err := checkPublishAt(publishAt, input)
+	if err != nil {
+		respondWithInvalidChirp(w, err)
+		return
+	}
+	draft, err := cfg.database.CreateDraft(newDraft(authorID, input, &publishAt))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't schedule chirp")
+		return
+	}
+	respondWithJson(w, http.StatusAccepted, draft)
+}
+
+// publishDraft moderates draft and publishes it as a chirp. Problems the
+// author has to fix are returned as invalidChirpErrors.
+func (cfg *apiConfig) publishDraft(draft database.Draft) (database.Chirp, error) {
+	prepared, err := cfg.prepareChirp(draft.AuthorID, draftInput(draft))
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	chirp, err := cfg.database.PublishDraft(draft, prepared)
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			err = invalidChirpError{errors.New(missingChirpMessage(err))}
+		}
+		return database.Chirp{}, err
+	}
+	if chirp.Hold == nil {
+		cfg.publishChirpCreated(chirp)
+	}
+	return chirp, nil
+}
+
+// runScheduler publishes scheduled chirps once they are due, checking at
+// start and then every schedulerInterval until ctx is done. Chirps that
+// came due while the server was down are published at start.
+func (cfg *apiConfig) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+	for {
+		cfg.publishDueDrafts(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDueDrafts publishes the scheduled chirps due at now. Chirps that
+// can't be published as they are go back to their author's drafts with the
+// reason, and other failures are retried on the next run.
+func (cfg *apiConfig) publishDueDrafts(now time.Time) {
+	due, err := cfg.database.GetDueDrafts(now)
+	if err != nil {
+		log.Printf("Couldn't get scheduled chirps: %v", err)
+		return
+	}
+	for _, draft := range due {
+		_, err := cfg.publishDraft(draft)
+		var invalid invalidChirpError
+		switch {
+		case err == nil || errors.Is(err, database.ErrDraftChanged):
+		case errors.As(err, &invalid):
+			_, err = cfg.database.UnscheduleDraft(draft, invalid.Error())
+			if err != nil && !errors.Is(err, database.ErrDraftChanged) {
+				log.Printf("Couldn't unschedule chirp %d: %v", draft.ID, err)
+			}
+		default:
+			log.Printf("Couldn't publish scheduled chirp %d: %v", draft.ID, err)
+		}
+	}
+}
+
+func (cfg *apiConfig) getScheduledChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	page, err := cfg.database.GetScheduledChirps(userIDFromContext(r.Context()), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get scheduled chirps")
+		}
+		return
+	}
+	respondWithPage(w, r, page.Drafts, page.NextCursor)
+}
+
+func (cfg *apiConfig) getScheduledChirpHandler(w http.ResponseWriter, r *http.Request) {
+	draft, ok := cfg.ownDraft(w, r, "scheduledID", true)
+	if !ok {
+		return
+	}
+	respondWithJson(w, http.StatusOK, draft)
+}
+
+// updateScheduledChirpHandler replaces a scheduled chirp. It keeps its
+// publish time unless a new publish_at is given.
+func (cfg *apiConfig) updateScheduledChirpHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		chirpInput
+		PublishAt *time.Time `json:"publish_at"`
+	}
+	draft, ok := cfg.ownDraft(w, r, "scheduledID", true)
+	if !ok {
+		return
+	}
+	params := parameters{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	if params.PublishAt == nil {
+		params.PublishAt = draft.PublishAt
+	}
+	err = checkPublishAt(*params.PublishAt, params.chirpInput)
+	if err != nil {
+		respondWithInvalidChirp(w, err)
+		return
+	}
+	_, err = cfg.prepareChirp(draft.AuthorID, params.chirpInput)
+	if err != nil {
+		respondWithInvalidChirp(w, err)
+		return
+	}
+
+	changes := newDraft(draft.AuthorID, params.chirpInput, params.PublishAt)
+	changes.ID = draft.ID
+	draft, err = cfg.database.UpdateDraft(changes)
+	if err != nil {
+		respondWithDraftError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, draft)
+}
+
+// cancelScheduledChirpHandler deletes a scheduled chirp before it is
+// published.
+func (cfg *apiConfig) cancelScheduledChirpHandler(w http.ResponseWriter, r *http.Request) {
+	draft, ok := cfg.ownDraft(w, r, "scheduledID", true)
+	if !ok {
+		return
+	}
+	err := cfg.database.DeleteDraft(draft.ID, draft.AuthorID)
+	if err != nil {
+		respondWithDraftError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createDraftHandler saves a draft. Drafts aren't moderated until they are
+// published.
+func (cfg *apiConfig) createDraftHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+	params := chirpInput{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	err = cfg.checkChirpInput(userID, params)
+	if err != nil {
+		respondWithInvalidChirp(w, err)
+		return
+	}
+	draft, err := cfg.database.CreateDraft(newDraft(userID, params, nil))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create draft")
+		return
+	}
+	respondWithJson(w, http.StatusCreated, draft)
+}
+
+func (cfg *apiConfig) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	page, err := cfg.database.GetDrafts(userIDFromContext(r.Context()), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get drafts")
+		}
+		return
+	}
+	respondWithPage(w, r, page.Drafts, page.NextCursor)
+}
+
+func (cfg *apiConfig) getDraftHandler(w http.ResponseWriter, r *http.Request) {
+	draft, ok := cfg.ownDraft(w, r, "draftID", false)
+	if !ok {
+		return
+	}
+	respondWithJson(w, http.StatusOK, draft)
+}
+
+func (cfg *apiConfig) updateDraftHandler(w http.ResponseWriter, r *http.Request) {
+	draft, ok := cfg.ownDraft(w, r, "draftID", false)
+	if !ok {
+		return
+	}
+	params := chirpInput{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	err = cfg.checkChirpInput(draft.AuthorID, params)
+	if err != nil {
+		respondWithInvalidChirp(w, err)
+		return
+	}
+	changes := newDraft(draft.AuthorID, params, nil)
+	changes.ID = draft.ID
+	draft, err = cfg.database.UpdateDraft(changes)
+	if err != nil {
+		respondWithDraftError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, draft)
+}
+
+func (cfg *apiConfig) deleteDraftHandler(w http.ResponseWriter, r *http.Request) {
+	draft, ok := cfg.ownDraft(w, r, "draftID", false)
+	if !ok {
+		return
+	}
+	err := cfg.database.DeleteDraft(draft.ID, draft.AuthorID)
+	if err != nil {
+		respondWithDraftError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishDraftHandler publishes a draft now, or schedules it when
+// publish_at is given.
+func (cfg *apiConfig) publishDraftHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		PublishAt *time.Time `json:"publish_at"`
+	}
+	draft, ok := cfg.ownDraft(w, r, "draftID", false)
+	if !ok {
+		return
+	}
+	params := parameters{}
+	if r.ContentLength != 0 {
+		var err error
+		params, err = decodeJsonBody(r.Body, params)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+			return
+		}
+	}
+
+	if params.PublishAt != nil {
+		err := checkPublishAt(*params.PublishAt, draftInput(draft))
+		if err == nil {
+			_, err = cfg.prepareChirp(draft.AuthorID, draftInput(draft))
+		}
+		if err != nil {
+			respondWithInvalidChirp(w, err)
+			return
+		}
+		changes := draft
+		changes.PublishAt = params.PublishAt
+		draft, err = cfg.database.UpdateDraft(changes)
+		if err != nil {
+			respondWithDraftError(w, err)
+			return
+		}
+		respondWithJson(w, http.StatusOK, draft)
+		return
+	}
+
+	chirp, err := cfg.publishDraft(draft)
+	if err != nil {
+		respondWithDraftError(w, err)
+		return
+	}
+	response, err := cfg.presentChirp(chirp, draft.AuthorID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp")
+		return
+	}
+	if chirp.Hold != nil {
+		respondWithJson(w, http.StatusAccepted, response)
+		return
+	}
+	respondWithJson(w, http.StatusCreated, response)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"internal/database"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestScheduledChirps(t *testing.T) {
+	cfg := newTestConfig(t)
+	token := newTestUser(t, cfg, "author@example.com")
+	otherToken := newTestUser(t, cfg, "other@example.com")
+	server := newTestServer(t, cfg)
+
+	if resp := server.send("POST", "/api/chirps", token, `{"body":"too early","publish_at":"2000-01-01T00:00:00Z"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a past publish_at to return 400, got %d", resp.StatusCode)
+	}
+	publishAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	resp := server.send("POST", "/api/chirps", token, `{"body":"later","publish_at":"`+publishAt+`"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected the chirp to be scheduled, got %d", resp.StatusCode)
+	}
+	scheduled := database.Draft{}
+	if err := json.NewDecoder(resp.Body).Decode(&scheduled); err != nil {
+		t.Fatalf("Couldn't decode scheduled chirp: %v", err)
+	}
+
+	if resp := server.send("GET", "/api/chirps/scheduled/1", otherToken, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected other users' scheduled chirps to be hidden, got %d", resp.StatusCode)
+	}
+	if resp := server.send("GET", "/api/drafts/1", token, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a scheduled chirp to not be a draft, got %d", resp.StatusCode)
+	}
+	resp = server.send("GET", "/api/chirps/scheduled", token, "")
+	page := struct {
+		Data []database.Draft `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("Couldn't decode scheduled chirps: %v", err)
+	}
+	if len(page.Data) != 1 || page.Data[0].Body != "later" {
+		t.Fatalf("Expected the scheduled chirp, got %+v", page.Data)
+	}
+
+	cfg.publishDueDrafts(time.Now())
+	if chirps, _ := cfg.database.GetChirps(); len(chirps) != 0 {
+		t.Fatalf("Expected nothing to be published early, got %+v", chirps)
+	}
+	cfg.publishDueDrafts(scheduled.PublishAt.Add(time.Second))
+	cfg.publishDueDrafts(scheduled.PublishAt.Add(time.Second))
+	chirps, err := cfg.database.GetChirps()
+	if err != nil || len(chirps) != 1 || chirps[0].Body != "later" {
+		t.Fatalf("Expected the chirp to be published once, got %+v, %v", chirps, err)
+	}
+	if resp := server.send("GET", "/api/chirps/scheduled/1", token, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the published chirp to no longer be scheduled, got %d", resp.StatusCode)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	cfg := newTestConfig(t)
+	token := newTestUser(t, cfg, "author@example.com")
+	otherToken := newTestUser(t, cfg, "other@example.com")
+	server := newTestServer(t, cfg)
+
+	if resp := server.send("POST", "/api/drafts", token, `{"body":"work in progress"}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected the draft to be created, got %d", resp.StatusCode)
+	}
+	if resp := server.send("GET", "/api/drafts/1", otherToken, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected other users' drafts to be hidden, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/api/drafts/1/publish", otherToken, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected other users to not publish drafts, got %d", resp.StatusCode)
+	}
+	if resp := server.send("PUT", "/api/drafts/1", token, `{"body":"finished"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the draft to be updated, got %d", resp.StatusCode)
+	}
+
+	resp := server.send("POST", "/api/drafts/1/publish", token, "")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected the draft to be published, got %d", resp.StatusCode)
+	}
+	chirp := database.Chirp{}
+	if err := json.NewDecoder(resp.Body).Decode(&chirp); err != nil {
+		t.Fatalf("Couldn't decode chirp: %v", err)
+	}
+	if chirp.Body != "finished" {
+		t.Errorf("Expected the edited body to be published, got %+v", chirp)
+	}
+	if resp := server.send("GET", "/api/drafts/1", token, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the published draft to be gone, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"internal/database"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type followResponse struct {
+	UserID    int  `json:"user_id"`
+	Following bool `json:"following"`
+	database.FollowCounts
+}
+
+func (cfg *apiConfig) followHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateFollowHandler(w, r, true)
+}
+
+func (cfg *apiConfig) unfollowHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateFollowHandler(w, r, false)
+}
+
+func (cfg *apiConfig) updateFollowHandler(w http.ResponseWriter, r *http.Request, follow bool) {
+	userId := userIDFromContext(r.Context())
+
+	// Users can only follow those they can see, but can always unfollow.
+	var target database.User
+	if follow {
+		var ok bool
+		target, ok = cfg.resolveVisibleUser(w, r)
+		if !ok {
+			return
+		}
+	} else {
+		var err error
+		target, err = cfg.resolveUser(chi.URLParam(r, "user"))
+		if err != nil {
+			if errors.Is(err, database.ErrNotExist) {
+				respondWithError(w, http.StatusNotFound, "User not found")
+			} else {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+			}
+			return
+		}
+	}
+	targetID := target.ID
+	if targetID == userId {
+		respondWithError(w, http.StatusBadRequest, "You can't follow yourself")
+		return
+	}
+
+	var err error
+	if follow {
+		err = cfg.database.Follow(userId, targetID)
+	} else {
+		err = cfg.database.Unfollow(userId, targetID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotExist):
+			respondWithError(w, http.StatusNotFound, "User not found")
+		case errors.Is(err, database.ErrBlocked):
+			respondWithError(w, http.StatusForbidden, "You can't follow this user")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update follow")
+		}
+		return
+	}
+
+	counts, err := cfg.database.GetFollowCounts(targetID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get follow counts")
+		return
+	}
+	respondWithJson(w, http.StatusOK, followResponse{
+		UserID:       targetID,
+		Following:    follow,
+		FollowCounts: counts,
+	})
+}
+
+func (cfg *apiConfig) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.followListHandler(w, r, cfg.database.GetFollowers, func(counts database.FollowCounts) int {
+		return counts.Followers
+	})
+}
+
+func (cfg *apiConfig) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.followListHandler(w, r, cfg.database.GetFollowing, func(counts database.FollowCounts) int {
+		return counts.Following
+	})
+}
+
+func (cfg *apiConfig) followListHandler(w http.ResponseWriter, r *http.Request, list func(userID int, cursor string, limit int) (database.UserPage, error), count func(counts database.FollowCounts) int) {
+	type response struct {
+		pageResponse
+		Count int `json:"count"`
+	}
+
+	user, ok := cfg.resolveVisibleUser(w, r)
+	if !ok {
+		return
+	}
+	userID := user.ID
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	page, err := list(userID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotExist):
+			respondWithError(w, http.StatusNotFound, "User not found")
+		case errors.Is(err, database.ErrInvalidCursor):
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get follows")
+		}
+		return
+	}
+	counts, err := cfg.database.GetFollowCounts(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get follow counts")
+		return
+	}
+	entries, err := cfg.presentUserEntries(page.Entries)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get follows")
+		return
+	}
+
+	setNextLink(w, r, page.NextCursor)
+	respondWithJson(w, http.StatusOK, response{
+		pageResponse: pageResponse{Data: entries, NextCursor: page.NextCursor},
+		Count:        count(counts),
+	})
+}
+
+// homeTimelineHandler merges the chirps of everyone the caller follows,
+// and the caller's own, newest first.
+func (cfg *apiConfig) homeTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	userId := userIDFromContext(r.Context())
+
+	authorIDs, err := cfg.database.GetFollowingIDs(userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get timeline")
+		return
+	}
+	cfg.respondWithTimeline(w, r, append(authorIDs, userId))
+}
+
+// respondWithTimeline responds with a page of the chirps by authorIDs that
+// the caller can see, newest first.
+func (cfg *apiConfig) respondWithTimeline(w http.ResponseWriter, r *http.Request, authorIDs []int) {
+	viewerID := userIDFromContext(r.Context())
+
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	if len(authorIDs) == 0 {
+		respondWithPage(w, r, []chirpResponse{}, "")
+		return
+	}
+
+	page, err := cfg.database.QueryChirps(database.ChirpQuery{
+		ViewerID:  viewerID,
+		AuthorIDs: authorIDs,
+		Sort:      database.SortIDDesc,
+		Limit:     limit,
+		Cursor:    r.URL.Query().Get("cursor"),
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get timeline")
+		}
+		return
+	}
+
+	responseChirps, err := cfg.presentChirps(page.Chirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get timeline")
+		return
+	}
+	respondWithPage(w, r, responseChirps, page.NextCursor)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"internal/database"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestFollow(t *testing.T) {
+	cfg := newTestConfig(t)
+	token := newTestUser(t, cfg, "reader@example.com")
+	newTestUser(t, cfg, "followed@example.com")
+	newTestUser(t, cfg, "banned@example.com")
+	newTestUser(t, cfg, "leaving@example.com")
+	if _, err := cfg.database.SetUserStatus(3, 0, database.UserBanned, "spam", nil); err != nil {
+		t.Fatalf("Couldn't ban user: %v", err)
+	}
+	if _, err := cfg.database.RequestDeletion(4, time.Hour); err != nil {
+		t.Fatalf("Couldn't request deletion: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	if resp := server.send("POST", "/api/users/1/follow", token, ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected following yourself to return 400, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/api/users/5/follow", token, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected following a missing user to return 404, got %d", resp.StatusCode)
+	}
+	for _, userID := range []string{"3", "4"} {
+		if resp := server.send("POST", "/api/users/"+userID+"/follow", token, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected following hidden user %s to return 404, got %d", userID, resp.StatusCode)
+		}
+		if resp := server.send("DELETE", "/api/users/"+userID+"/follow", token, ""); resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected unfollowing hidden user %s to succeed, got %d", userID, resp.StatusCode)
+		}
+	}
+	for _, userID := range []int{3, 4} {
+		page, err := cfg.database.GetNotifications(userID, "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't get notifications: %v", err)
+		}
+		if len(page.Groups) != 0 {
+			t.Errorf("Expected hidden user %d not to be notified, got %+v", userID, page.Groups)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		resp := server.send("POST", "/api/users/2/follow", token, "")
+		follow := followResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&follow); err != nil {
+			t.Fatalf("Couldn't decode follow: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || !follow.Following || follow.Followers != 1 {
+			t.Errorf("Expected one follower after following, got %d %+v", resp.StatusCode, follow)
+		}
+	}
+	if following, err := cfg.database.IsFollowing(1, 2); err != nil || !following {
+		t.Errorf("Expected the follow to be stored, got %v, %v", following, err)
+	}
+
+	resp := server.send("DELETE", "/api/users/2/follow", token, "")
+	follow := followResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&follow); err != nil {
+		t.Fatalf("Couldn't decode follow: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || follow.Following || follow.Followers != 0 {
+		t.Errorf("Expected no followers after unfollowing, got %d %+v", resp.StatusCode, follow)
+	}
+	if resp := server.send("DELETE", "/api/users/2/follow", token, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected unfollowing twice to succeed, got %d", resp.StatusCode)
+	}
+}
+
+func TestHomeTimeline(t *testing.T) {
+	cfg := newTestConfig(t)
+	token := newTestUser(t, cfg, "reader@example.com")
+	for _, email := range []string{"first@example.com", "second@example.com", "stranger@example.com"} {
+		newTestUser(t, cfg, email)
+	}
+	for _, authorID := range []int{2, 4, 1, 3, 2, 4} {
+		if _, err := cfg.database.CreateChirp(database.Chirp{Body: "chirp", AuthorId: authorID}); err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	for _, followeeID := range []int{2, 3} {
+		if err := cfg.database.Follow(1, followeeID); err != nil {
+			t.Fatalf("Couldn't follow: %v", err)
+		}
+	}
+	server := newTestServer(t, cfg)
+
+	get := func(path string) (pageResponse, []chirpResponse) {
+		t.Helper()
+		resp := server.send("GET", path, token, "")
+		chirps := []chirpResponse{}
+		page := pageResponse{Data: &chirps}
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			t.Fatalf("Couldn't decode timeline: %v", err)
+		}
+		return page, chirps
+	}
+
+	var ids []int
+	path := "/api/timeline/home?limit=2"
+	for path != "" {
+		page, chirps := get(path)
+		for _, chirp := range chirps {
+			ids = append(ids, chirp.ID)
+		}
+		path = ""
+		if page.NextCursor != "" {
+			path = "/api/timeline/home?limit=2&cursor=" + url.QueryEscape(page.NextCursor)
+		}
+	}
+	want := []int{5, 4, 3, 1}
+	if len(ids) != len(want) {
+		t.Fatalf("Expected chirps %v, got %v", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("Expected chirps %v, got %v", want, ids)
+		}
+	}
+}
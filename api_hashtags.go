@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"internal/database"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+)
+
+func (cfg *apiConfig) getHashtagChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := database.NormalizeHashtag(chi.URLParam(r, "tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid hashtag")
+		return
+	}
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	page, err := cfg.database.QueryChirps(database.ChirpQuery{
+		ViewerID: userIDFromContext(r.Context()),
+		Hashtag:  tag,
+		Sort:     database.SortIDDesc,
+		Limit:    limit,
+		Cursor:   r.URL.Query().Get("cursor"),
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps")
+		}
+		return
+	}
+
+	responseChirps, err := cfg.presentChirps(page.Chirps, userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps")
+		return
+	}
+	respondWithPage(w, r, responseChirps, page.NextCursor)
+}
+
+// getTrendingHashtagsHandler ranks hashtags by how many chirps used them
+// within a sliding window ending now.
+func (cfg *apiConfig) getTrendingHashtagsHandler(w http.ResponseWriter, r *http.Request) {
+	window := defaultTrendingWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		var err error
+		window, err = time.ParseDuration(value)
+		if err != nil || window <= 0 || window > maxTrendingWindow {
+			respondWithError(w, http.StatusBadRequest, "Invalid window, expected a duration of up to 168h")
+			return
+		}
+	}
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	trending, err := cfg.database.TrendingHashtags(time.Now().Add(-window), limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get trending hashtags")
+		return
+	}
+	respondWithJson(w, http.StatusOK, trending)
+}
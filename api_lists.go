@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"internal/database"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxListNameLength        = 25
+	maxListDescriptionLength = 100
+)
+
+type listParameters struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+}
+
+func checkList(params listParameters) error {
+	name := strings.TrimSpace(params.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxListNameLength {
+		return errors.New("List name must be between 1 and 25 characters")
+	}
+	if utf8.RuneCountInString(params.Description) > maxListDescriptionLength {
+		return errors.New("List description can't be longer than 100 characters")
+	}
+	return nil
+}
+
+func (params listParameters) list(ownerID int) database.List {
+	return database.List{
+		OwnerID:     ownerID,
+		Name:        strings.TrimSpace(params.Name),
+		Description: strings.TrimSpace(params.Description),
+		Private:     params.Private,
+	}
+}
+
+// visibleList returns the list named by the {listID} route parameter if
+// the caller may see it.
+func (cfg *apiConfig) visibleList(w http.ResponseWriter, r *http.Request) (database.List, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "listID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list id")
+		return database.List{}, false
+	}
+	list, err := cfg.database.GetList(id, userIDFromContext(r.Context()))
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find list")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get list")
+		}
+		return database.List{}, false
+	}
+	return list, true
+}
+
+// ownList is visibleList for changes, which only the owner may make.
+func (cfg *apiConfig) ownList(w http.ResponseWriter, r *http.Request) (database.List, bool) {
+	list, ok := cfg.visibleList(w, r)
+	if !ok {
+		return database.List{}, false
+	}
+	if list.OwnerID != userIDFromContext(r.Context()) {
+		respondWithError(w, http.StatusForbidden, "You are not allowed to change lists from other users")
+		return database.List{}, false
+	}
+	return list, true
+}
+
+func (cfg *apiConfig) createListHandler(w http.ResponseWriter, r *http.Request) {
+	params := listParameters{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	err = checkList(params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list, err := cfg.database.CreateList(params.list(userIDFromContext(r.Context())))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create list")
+		return
+	}
+	respondWithJson(w, http.StatusCreated, list)
+}
+
+// getMyListsHandler lists the caller's lists, private ones included.
+func (cfg *apiConfig) getMyListsHandler(w http.ResponseWriter, r *http.Request) {
+	userId := userIDFromContext(r.Context())
+	cfg.respondWithLists(w, r, userId)
+}
+
+// getUserListsHandler lists the lists of the {user} route parameter that
+// the caller may see.
+func (cfg *apiConfig) getUserListsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.resolveVisibleUser(w, r)
+	if !ok {
+		return
+	}
+	cfg.respondWithLists(w, r, user.ID)
+}
+
+func (cfg *apiConfig) respondWithLists(w http.ResponseWriter, r *http.Request, ownerID int) {
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	page, err := cfg.database.GetLists(ownerID, userIDFromContext(r.Context()), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get lists")
+		}
+		return
+	}
+	respondWithPage(w, r, page.Lists, page.NextCursor)
+}
+
+func (cfg *apiConfig) getListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+	respondWithJson(w, http.StatusOK, list)
+}
+
+func (cfg *apiConfig) updateListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.ownList(w, r)
+	if !ok {
+		return
+	}
+	params := listParameters{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	err = checkList(params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	changes := params.list(list.OwnerID)
+	changes.ID = list.ID
+	list, err = cfg.database.UpdateList(changes)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update list")
+		return
+	}
+	respondWithJson(w, http.StatusOK, list)
+}
+
+func (cfg *apiConfig) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.ownList(w, r)
+	if !ok {
+		return
+	}
+	err := cfg.database.DeleteList(list.ID, list.OwnerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete list")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getListMembersHandler lists a list's members, most recently added first.
+func (cfg *apiConfig) getListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	page, err := cfg.database.GetListMembers(list.ID, userIDFromContext(r.Context()), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotExist):
+			respondWithError(w, http.StatusNotFound, "Couldn't find list")
+		case errors.Is(err, database.ErrInvalidCursor):
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get list members")
+		}
+		return
+	}
+	entries, err := cfg.presentUserEntries(page.Entries)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get list members")
+		return
+	}
+	respondWithPage(w, r, entries, page.NextCursor)
+}
+
+func (cfg *apiConfig) addListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listMemberHandler(w, r, cfg.database.AddListMember)
+}
+
+func (cfg *apiConfig) removeListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listMemberHandler(w, r, cfg.database.RemoveListMember)
+}
+
+func (cfg *apiConfig) listMemberHandler(w http.ResponseWriter, r *http.Request, apply func(listID, ownerID, memberID int) (database.List, error)) {
+	list, ok := cfg.ownList(w, r)
+	if !ok {
+		return
+	}
+	member, err := cfg.resolveUser(chi.URLParam(r, "user"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "User not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		}
+		return
+	}
+
+	list, err = apply(list.ID, list.OwnerID, member.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotExist):
+			respondWithError(w, http.StatusNotFound, "User not found")
+		case errors.Is(err, database.ErrBlocked):
+			respondWithError(w, http.StatusForbidden, "You can't add this user to a list")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update list members")
+		}
+		return
+	}
+	respondWithJson(w, http.StatusOK, list)
+}
+
+// getListTimelineHandler merges the chirps of a list's members, newest
+// first, like the home timeline.
+func (cfg *apiConfig) getListTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+	memberIDs, err := cfg.database.GetListMemberIDs(list.ID, userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get timeline")
+		return
+	}
+	cfg.respondWithTimeline(w, r, memberIDs)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"internal/database"
+	"net/http"
+	"testing"
+)
+
+func TestBookmarkChirps(t *testing.T) {
+	cfg := newTestConfig(t)
+	token := newTestUser(t, cfg, "reader@example.com")
+	if _, err := cfg.database.CreateChirp(database.Chirp{Body: "save me", AuthorId: 1}); err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	if resp := server.send("POST", "/api/chirps/2/bookmark", token, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected bookmarking a missing chirp to return 404, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/api/chirps/1/bookmark", token, ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected the chirp to be bookmarked, got %d", resp.StatusCode)
+	}
+	resp := server.send("GET", "/api/bookmarks", token, "")
+	page := struct {
+		Data []chirpResponse `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("Couldn't decode bookmarks: %v", err)
+	}
+	if len(page.Data) != 1 || page.Data[0].ID != 1 || page.Data[0].BookmarkedByMe == nil || !*page.Data[0].BookmarkedByMe {
+		t.Errorf("Expected the bookmarked chirp, got %+v", page.Data)
+	}
+	if resp := server.send("GET", "/api/bookmarks", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected bookmarks to require auth, got %d", resp.StatusCode)
+	}
+}
+
+func TestLists(t *testing.T) {
+	cfg := newTestConfig(t)
+	ownerToken := newTestUser(t, cfg, "owner@example.com")
+	otherToken := newTestUser(t, cfg, "other@example.com")
+	newTestUser(t, cfg, "member@example.com")
+	for _, chirp := range []database.Chirp{{Body: "by other", AuthorId: 2}, {Body: "by member", AuthorId: 3}} {
+		if _, err := cfg.database.CreateChirp(chirp); err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	server := newTestServer(t, cfg)
+
+	if resp := server.send("POST", "/api/lists", ownerToken, `{"name":""}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an empty name to return 400, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/api/lists", ownerToken, `{"name":"friends","private":true}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected the list to be created, got %d", resp.StatusCode)
+	}
+	if resp := server.send("PUT", "/api/lists/1/members/3", ownerToken, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the member to be added, got %d", resp.StatusCode)
+	}
+
+	for _, path := range []string{"/api/lists/1", "/api/lists/1/members", "/api/lists/1/timeline"} {
+		if resp := server.send("GET", path, otherToken, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected %s to be hidden from other users, got %d", path, resp.StatusCode)
+		}
+	}
+
+	resp := server.send("GET", "/api/lists/1/timeline", ownerToken, "")
+	page := struct {
+		Data []chirpResponse `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("Couldn't decode timeline: %v", err)
+	}
+	if len(page.Data) != 1 || page.Data[0].AuthorId != 3 {
+		t.Errorf("Expected only the member's chirps, got %+v", page.Data)
+	}
+
+	if resp := server.send("PUT", "/api/lists/1", ownerToken, `{"name":"friends"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the list to be made public, got %d", resp.StatusCode)
+	}
+	if resp := server.send("GET", "/api/lists/1/timeline", "", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected a public list's timeline to be visible, got %d", resp.StatusCode)
+	}
+	if resp := server.send("GET", "/api/users/1/lists", otherToken, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the owner's public lists to be visible, got %d", resp.StatusCode)
+	}
+	for _, req := range []struct{ method, path, body string }{
+		{"PUT", "/api/lists/1", `{"name":"mine now"}`},
+		{"DELETE", "/api/lists/1", ""},
+		{"PUT", "/api/lists/1/members/2", ""},
+	} {
+		if resp := server.send(req.method, req.path, otherToken, req.body); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected %s %s by another user to return 403, got %d", req.method, req.path, resp.StatusCode)
+		}
+	}
+	if resp := server.send("DELETE", "/api/lists/1", ownerToken, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected the owner to delete the list, got %d", resp.StatusCode)
+	}
+}
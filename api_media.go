@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"internal/database"
+	"internal/storage"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxUploadSize        = 5 << 20
+	maxAttachments       = 4
+	maxAttachmentPixels  = 4096
+	maxAvatarPixels      = 1024
+	mediaURLPrefix       = "/media/"
+	mediaCacheControl    = "public, max-age=31536000, immutable"
+	multipartMemoryLimit = 1 << 20
+)
+
+// uploadExtensions lists the accepted upload types, detected from the
+// file's content rather than the name or header sent by the client.
+var uploadExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+var (
+	errUploadTooLarge   = fmt.Errorf("File can't be larger than %d MB", maxUploadSize>>20)
+	errUploadType       = errors.New("File must be a PNG, JPEG or GIF image")
+	errUploadDimensions = errors.New("Image is too large")
+)
+
+type mediaResponse struct {
+	ID          int    `json:"id"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+func newMediaResponse(media database.Media) mediaResponse {
+	return mediaResponse{
+		ID:          media.ID,
+		URL:         mediaURL(media.Key),
+		ContentType: media.ContentType,
+		Width:       media.Width,
+		Height:      media.Height,
+	}
+}
+
+func mediaURL(key string) string {
+	if key == "" {
+		return ""
+	}
+	return mediaURLPrefix + key
+}
+
+// readUpload reads and checks the "file" field of a multipart upload. It
+// returns the file's contents, its sniffed content type and its dimensions.
+func readUpload(w http.ResponseWriter, r *http.Request, maxPixels int) ([]byte, string, image.Config, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+multipartMemoryLimit)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, "", image.Config{}, errUploadTooLarge
+		}
+		return nil, "", image.Config{}, errors.New("Missing file")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+	if err != nil {
+		return nil, "", image.Config{}, err
+	}
+	if len(data) > maxUploadSize {
+		return nil, "", image.Config{}, errUploadTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := uploadExtensions[contentType]; !ok {
+		return nil, "", image.Config{}, errUploadType
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", image.Config{}, errUploadType
+	}
+	if config.Width > maxPixels || config.Height > maxPixels {
+		return nil, "", image.Config{}, errUploadDimensions
+	}
+	return data, contentType, config, nil
+}
+
+// storeUpload saves an upload under a name derived from its content, so
+// identical files share a blob.
+func (cfg *apiConfig) storeUpload(w http.ResponseWriter, r *http.Request, kind database.MediaKind, maxPixels int) (database.Media, bool) {
+	data, contentType, config, err := readUpload(w, r, maxPixels)
+	if err != nil {
+		switch {
+		case errors.Is(err, errUploadTooLarge):
+			respondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, errUploadType):
+			respondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+		default:
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		}
+		return database.Media{}, false
+	}
+
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:]) + uploadExtensions[contentType]
+	err = cfg.blobStore.Put(key, bytes.NewReader(data))
+	if err != nil {
+		log.Print(err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store file")
+		return database.Media{}, false
+	}
+
+	media, err := cfg.database.CreateMedia(database.Media{
+		OwnerID:     userIDFromContext(r.Context()),
+		Kind:        kind,
+		Key:         key,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       config.Width,
+		Height:      config.Height,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save media")
+		return database.Media{}, false
+	}
+	return media, true
+}
+
+func (cfg *apiConfig) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	media, ok := cfg.storeUpload(w, r, database.MediaKindAttachment, maxAttachmentPixels)
+	if !ok {
+		return
+	}
+	respondWithJson(w, http.StatusCreated, newMediaResponse(media))
+}
+
+func (cfg *apiConfig) uploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	media, ok := cfg.storeUpload(w, r, database.MediaKindAvatar, maxAvatarPixels)
+	if !ok {
+		return
+	}
+	user, replacedKey, err := cfg.database.SetAvatar(media.OwnerID, media.Key)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update avatar")
+		return
+	}
+	if replacedKey != "" {
+		if err := cfg.blobStore.Delete(replacedKey); err != nil {
+			log.Printf("Couldn't delete replaced avatar %s: %v", replacedKey, err)
+		}
+	}
+	cfg.respondWithOwnProfile(w, user)
+}
+
+// validateAttachments checks that every media ID is an attachment uploaded
+// by the chirp's author.
+func (cfg *apiConfig) validateAttachments(mediaIDs []int, authorID int) error {
+	if len(mediaIDs) > maxAttachments {
+		return fmt.Errorf("a chirp can't have more than %d attachments", maxAttachments)
+	}
+	media, err := cfg.database.GetMedia(mediaIDs)
+	if err != nil {
+		return err
+	}
+	seen := make(map[int]bool, len(mediaIDs))
+	for _, id := range mediaIDs {
+		m, ok := media[id]
+		if !ok || m.OwnerID != authorID || m.Kind != database.MediaKindAttachment || seen[id] {
+			return fmt.Errorf("invalid attachment %d", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+func (cfg *apiConfig) serveMediaHandler(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	blob, err := cfg.blobStore.Open(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) || errors.Is(err, storage.ErrInvalidKey) {
+			http.NotFound(w, r)
+		} else {
+			log.Print(err)
+			http.Error(w, "Couldn't read file", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", mediaCacheControl)
+	http.ServeContent(w, r, key, time.Time{}, blob)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"internal/storage"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// encodePNG returns a PNG of the given size whose pixels depend on shade, so
+// different shades produce different blobs.
+func encodePNG(t *testing.T, width, height int, shade uint8) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = shade
+	}
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Couldn't encode image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadChecks(t *testing.T) {
+	cfg := newTestConfig(t)
+	blobStore, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Couldn't create blob store: %v", err)
+	}
+	cfg.blobStore = blobStore
+	token := newTestUser(t, cfg, "uploader@example.com")
+	server := newTestServer(t, cfg)
+
+	upload := func(path string, data []byte) *http.Response {
+		t.Helper()
+		body := bytes.Buffer{}
+		form := multipart.NewWriter(&body)
+		if data != nil {
+			part, err := form.CreateFormFile("file", "upload.png")
+			if err != nil {
+				t.Fatalf("Couldn't create form file: %v", err)
+			}
+			part.Write(data)
+		}
+		form.Close()
+		req, err := http.NewRequest("POST", server.URL+path, &body)
+		if err != nil {
+			t.Fatalf("Couldn't create request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Couldn't send request: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	cases := []struct {
+		name   string
+		path   string
+		data   []byte
+		status int
+	}{
+		{"missing file", "/api/media", nil, http.StatusBadRequest},
+		{"too large", "/api/media", bytes.Repeat([]byte{0}, maxUploadSize+1), http.StatusRequestEntityTooLarge},
+		{"not an image", "/api/media", []byte("just some text"), http.StatusUnsupportedMediaType},
+		{"truncated image", "/api/media", encodePNG(t, 10, 10, 1)[:20], http.StatusUnsupportedMediaType},
+		{"avatar too wide", "/api/users/me/avatar", encodePNG(t, maxAvatarPixels+1, 1, 1), http.StatusBadRequest},
+		{"attachment too tall", "/api/media", encodePNG(t, 1, maxAttachmentPixels+1, 1), http.StatusBadRequest},
+		{"wide attachment", "/api/media", encodePNG(t, maxAvatarPixels+1, 1, 1), http.StatusCreated},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if resp := upload(c.path, c.data); resp.StatusCode != c.status {
+				t.Errorf("Expected %d, got %d", c.status, resp.StatusCode)
+			}
+		})
+	}
+
+	resp := upload("/api/users/me/avatar", encodePNG(t, 10, 10, 1))
+	profile := map[string]interface{}{}
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		t.Fatalf("Couldn't decode profile: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(profile["avatar_url"].(string), mediaURLPrefix) {
+		t.Fatalf("Expected the avatar to be set, got %d %+v", resp.StatusCode, profile)
+	}
+	for _, field := range []string{"email", "password", "roles", "status", "tokens_valid_after"} {
+		if _, ok := profile[field]; ok {
+			t.Errorf("Expected the profile not to include %s", field)
+		}
+	}
+
+	firstKey := strings.TrimPrefix(profile["avatar_url"].(string), mediaURLPrefix)
+	if resp := upload("/api/users/me/avatar", encodePNG(t, 10, 10, 2)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the avatar to be replaced, got %d", resp.StatusCode)
+	}
+	if _, err := cfg.blobStore.Open(firstKey); !errors.Is(err, storage.ErrNotExist) {
+		t.Errorf("Expected the replaced avatar to be deleted, got %v", err)
+	}
+}
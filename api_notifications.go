@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"internal/database"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type notificationResponse struct {
+	ID         int                       `json:"id"`
+	Type       database.NotificationType `json:"type"`
+	ChirpID    int                       `json:"chirp_id,omitempty"`
+	ReportID   int                       `json:"report_id,omitempty"`
+	Actors     []userSummary             `json:"actors"`
+	ActorCount int                       `json:"actor_count"`
+	Summary    string                    `json:"summary"`
+	Read       bool                      `json:"read"`
+	CreatedAt  time.Time                 `json:"created_at"`
+}
+
+var notificationVerbs = map[database.NotificationType]string{
+	database.NotificationMention: "mentioned you",
+	database.NotificationReply:   "replied to your chirp",
+	database.NotificationFollow:  "followed you",
+	database.NotificationLike:    "liked your chirp",
+	database.NotificationRechirp: "rechirped your chirp",
+	database.NotificationQuote:   "quoted your chirp",
+}
+
+// moderationSummaries describe notifications from moderators, which have no
+// actors.
+var moderationSummaries = map[database.NotificationType]string{
+	database.NotificationReport:  "A moderator reviewed your report",
+	database.NotificationWarning: "A moderator warned you about your behavior",
+}
+
+// notificationSummary describes a group in a sentence, such as "@alice and
+// @bob liked your chirp" or "3 people liked your chirp".
+func notificationSummary(notificationType database.NotificationType, actors []userSummary, actorCount int) string {
+	if summary, ok := moderationSummaries[notificationType]; ok {
+		return summary
+	}
+	names := make([]string, 0, len(actors))
+	for _, actor := range actors {
+		names = append(names, actorName(actor))
+	}
+	var who string
+	switch {
+	case actorCount > 2:
+		who = fmt.Sprintf("%d people", actorCount)
+	case len(names) == 2:
+		who = names[0] + " and " + names[1]
+	case len(names) == 1:
+		who = names[0]
+	default:
+		who = "Someone"
+	}
+	return who + " " + notificationVerbs[notificationType]
+}
+
+func actorName(user userSummary) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return "Someone"
+}
+
+func (cfg *apiConfig) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		pageResponse
+		UnreadCount int `json:"unread_count"`
+	}
+
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	page, err := cfg.database.GetNotifications(userIDFromContext(r.Context()), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get notifications")
+		}
+		return
+	}
+
+	actorIDs := make([]int, 0)
+	for _, group := range page.Groups {
+		actorIDs = append(actorIDs, group.ActorIDs...)
+	}
+	users, err := cfg.database.GetUsers(actorIDs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get notifications")
+		return
+	}
+
+	notifications := make([]notificationResponse, 0, len(page.Groups))
+	for _, group := range page.Groups {
+		actors := make([]userSummary, 0, len(group.ActorIDs))
+		for _, actorID := range group.ActorIDs {
+			if user, ok := users[actorID]; ok {
+				actors = append(actors, newUserSummary(user))
+			}
+		}
+		notifications = append(notifications, notificationResponse{
+			ID:         group.ID,
+			Type:       group.Type,
+			ChirpID:    group.ChirpID,
+			ReportID:   group.ReportID,
+			Actors:     actors,
+			ActorCount: group.ActorCount,
+			Summary:    notificationSummary(group.Type, actors, group.ActorCount),
+			Read:       group.Read,
+			CreatedAt:  group.CreatedAt,
+		})
+	}
+
+	setNextLink(w, r, page.NextCursor)
+	respondWithJson(w, http.StatusOK, response{
+		pageResponse: pageResponse{Data: notifications, NextCursor: page.NextCursor},
+		UnreadCount:  page.UnreadCount,
+	})
+}
+
+// markNotificationReadHandler marks a notification group as read, using the
+// ID the group was listed with.
+func (cfg *apiConfig) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification id")
+		return
+	}
+	err = cfg.database.MarkNotificationRead(userIDFromContext(r.Context()), id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Notification not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't mark notification as read")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	err := cfg.database.MarkAllNotificationsRead(userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark notifications as read")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	preferences, err := cfg.database.GetNotificationPreferences(userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get notification preferences")
+		return
+	}
+	respondWithJson(w, http.StatusOK, preferences)
+}
+
+// updateNotificationPreferencesHandler turns notification types on or off.
+// Types left out of the body keep their current setting.
+func (cfg *apiConfig) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	changes, err := decodeJsonBody(r.Body, database.NotificationPreferences{})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	for notificationType := range changes {
+		if !notificationType.Valid() {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown notification type %q", notificationType))
+			return
+		}
+	}
+
+	preferences, err := cfg.database.UpdateNotificationPreferences(userIDFromContext(r.Context()), changes)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update notification preferences")
+		return
+	}
+	respondWithJson(w, http.StatusOK, preferences)
+}
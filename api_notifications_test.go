@@ -0,0 +1,42 @@
+package main
+
+import (
+	"internal/database"
+	"testing"
+)
+
+func TestNotificationSummary(t *testing.T) {
+	actors := []userSummary{{ID: 1, Username: "alice"}}
+	types := append([]database.NotificationType{}, database.NotificationTypes...)
+	types = append(types, database.NotificationWarning)
+	for _, notificationType := range types {
+		t.Run(string(notificationType), func(t *testing.T) {
+			want, moderation := moderationSummaries[notificationType]
+			if !moderation {
+				verb, ok := notificationVerbs[notificationType]
+				if !ok {
+					t.Fatalf("Expected a verb for %s", notificationType)
+				}
+				want = "@alice " + verb
+			}
+			if got := notificationSummary(notificationType, actors, 1); got != want {
+				t.Errorf("Expected %q, got %q", want, got)
+			}
+		})
+	}
+
+	cases := []struct {
+		actors     []userSummary
+		actorCount int
+		want       string
+	}{
+		{actors: nil, actorCount: 0, want: "Someone quoted your chirp"},
+		{actors: []userSummary{{Username: "alice"}, {DisplayName: "Bob"}}, actorCount: 2, want: "@alice and Bob quoted your chirp"},
+		{actors: []userSummary{{Username: "alice"}, {Username: "bob"}, {Username: "carol"}}, actorCount: 5, want: "5 people quoted your chirp"},
+	}
+	for _, c := range cases {
+		if got := notificationSummary(database.NotificationQuote, c.actors, c.actorCount); got != c.want {
+			t.Errorf("Expected %q, got %q", c.want, got)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"internal/database"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 25
+)
+
+// pollInput is a poll as its author writes it.
+type pollInput struct {
+	Options  []string  `json:"options"`
+	Multiple bool      `json:"multiple"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+func (input *pollInput) poll() *database.Poll {
+	if input == nil {
+		return nil
+	}
+	options := make([]database.PollOption, 0, len(input.Options))
+	for _, option := range input.Options {
+		options = append(options, database.PollOption{Text: strings.TrimSpace(option)})
+	}
+	return &database.Poll{
+		Options:  options,
+		Multiple: input.Multiple,
+		ClosesAt: input.ClosesAt,
+	}
+}
+
+func newPollInput(poll *database.Poll) *pollInput {
+	if poll == nil {
+		return nil
+	}
+	options := make([]string, 0, len(poll.Options))
+	for _, option := range poll.Options {
+		options = append(options, option.Text)
+	}
+	return &pollInput{
+		Options:  options,
+		Multiple: poll.Multiple,
+		ClosesAt: poll.ClosesAt,
+	}
+}
+
+// checkPoll validates a poll's options and that it is still open at now.
+func checkPoll(input *pollInput, now time.Time) error {
+	if input == nil {
+		return nil
+	}
+	if len(input.Options) < minPollOptions || len(input.Options) > maxPollOptions {
+		return errors.New("polls need between 2 and 4 options")
+	}
+	seen := make(map[string]bool, len(input.Options))
+	for _, option := range input.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || len(option) > maxPollOptionLength {
+			return errors.New("poll options must be between 1 and 25 characters")
+		}
+		if seen[strings.ToLower(option)] {
+			return errors.New("poll options must be different")
+		}
+		seen[strings.ToLower(option)] = true
+	}
+	if !input.ClosesAt.After(now) {
+		return errors.New("poll closes_at must be in the future")
+	}
+	return nil
+}
+
+// pollResponse is a poll as seen by a particular viewer. Counts are left
+// out until the viewer votes or the poll closes.
+type pollResponse struct {
+	Options    []pollOptionResponse `json:"options"`
+	Multiple   bool                 `json:"multiple"`
+	ClosesAt   time.Time            `json:"closes_at"`
+	Closed     bool                 `json:"closed"`
+	VoterCount *int                 `json:"voter_count,omitempty"`
+	MyChoices  []int                `json:"my_choices,omitempty"`
+}
+
+type pollOptionResponse struct {
+	Text  string `json:"text"`
+	Votes *int   `json:"votes,omitempty"`
+}
+
+func newPollResponse(poll database.Poll, vote *database.PollVote, now time.Time) *pollResponse {
+	response := &pollResponse{
+		Options:  make([]pollOptionResponse, 0, len(poll.Options)),
+		Multiple: poll.Multiple,
+		ClosesAt: poll.ClosesAt,
+		Closed:   poll.Closed(now),
+	}
+	showResults := response.Closed || vote != nil
+	for _, option := range poll.Options {
+		optionResponse := pollOptionResponse{Text: option.Text}
+		if showResults {
+			votes := option.Votes
+			optionResponse.Votes = &votes
+		}
+		response.Options = append(response.Options, optionResponse)
+	}
+	if showResults {
+		voterCount := poll.VoterCount
+		response.VoterCount = &voterCount
+	}
+	if vote != nil {
+		response.MyChoices = vote.Choices
+	}
+	return response
+}
+
+// voteHandler records the caller's vote in a chirp's poll. Each user votes
+// once.
+func (cfg *apiConfig) voteHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Choices []int `json:"choices"`
+	}
+
+	userId := userIDFromContext(r.Context())
+
+	chirpID := chi.URLParam(r, "chirpID")
+	id, err := strconv.Atoi(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+
+	params := parameters{}
+	params, err = decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	chirp, err := cfg.database.Vote(id, userId, params.Choices)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotExist):
+			respondWithError(w, http.StatusNotFound, "Couldn't find poll")
+		case errors.Is(err, database.ErrPollClosed):
+			respondWithError(w, http.StatusConflict, "Poll is closed")
+		case errors.Is(err, database.ErrAlreadyExist):
+			respondWithError(w, http.StatusConflict, "You already voted in this poll")
+		case errors.Is(err, database.ErrInvalidVote):
+			respondWithError(w, http.StatusBadRequest, "Invalid choices, expected the index of one option, or of several different options in a multiple choice poll")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't vote")
+		}
+		return
+	}
+
+	response, err := cfg.presentChirp(chirp, userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp")
+		return
+	}
+	respondWithJson(w, http.StatusOK, response)
+}
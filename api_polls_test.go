@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolls(t *testing.T) {
+	cfg := newTestConfig(t)
+	authorToken := newTestUser(t, cfg, "author@example.com")
+	voterToken := newTestUser(t, cfg, "voter@example.com")
+	server := newTestServer(t, cfg)
+
+	decodePoll := func(resp *http.Response) pollResponse {
+		t.Helper()
+		chirp := struct {
+			Poll *pollResponse `json:"poll"`
+		}{}
+		if err := json.NewDecoder(resp.Body).Decode(&chirp); err != nil {
+			t.Fatalf("Couldn't decode chirp: %v", err)
+		}
+		if chirp.Poll == nil {
+			t.Fatalf("Expected the chirp to have a poll")
+		}
+		return *chirp.Poll
+	}
+
+	closesAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	for _, body := range []string{
+		`{"body":"pick","poll":{"options":["only"],"closes_at":"` + closesAt + `"}}`,
+		`{"body":"pick","poll":{"options":["a","A"],"closes_at":"` + closesAt + `"}}`,
+		`{"body":"pick","poll":{"options":["a","b"],"closes_at":"2000-01-01T00:00:00Z"}}`,
+		`{"body":"` + strings.Repeat("a", maxChirpLength+1) + `","poll":{"options":["a","b"],"closes_at":"` + closesAt + `"}}`,
+	} {
+		if resp := server.send("POST", "/api/chirps", authorToken, body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected %s to return 400, got %d", body, resp.StatusCode)
+		}
+	}
+	resp := server.send("POST", "/api/chirps", authorToken, `{"body":"pick","poll":{"options":["a","b","c"],"closes_at":"`+closesAt+`"}}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected the chirp to be created, got %d", resp.StatusCode)
+	}
+
+	poll := decodePoll(server.send("GET", "/api/chirps/1", voterToken, ""))
+	if len(poll.Options) != 3 || poll.Options[0].Votes != nil || poll.VoterCount != nil {
+		t.Errorf("Expected results to be hidden before voting, got %+v", poll)
+	}
+	if resp := server.send("POST", "/api/chirps/1/votes", "", `{"choices":[1]}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected anonymous votes to return 401, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/api/chirps/1/votes", voterToken, `{"choices":[0,1]}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected two choices in a single choice poll to return 400, got %d", resp.StatusCode)
+	}
+	resp = server.send("POST", "/api/chirps/1/votes", voterToken, `{"choices":[1]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the vote to be recorded, got %d", resp.StatusCode)
+	}
+	poll = decodePoll(resp)
+	if poll.VoterCount == nil || *poll.VoterCount != 1 || poll.Options[1].Votes == nil || *poll.Options[1].Votes != 1 || len(poll.MyChoices) != 1 {
+		t.Errorf("Expected results after voting, got %+v", poll)
+	}
+	if resp := server.send("POST", "/api/chirps/1/votes", voterToken, `{"choices":[0]}`); resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected a second vote to return 409, got %d", resp.StatusCode)
+	}
+
+	poll = decodePoll(server.send("GET", "/api/chirps/1", "", ""))
+	if poll.VoterCount != nil {
+		t.Errorf("Expected results to stay hidden from anonymous viewers, got %+v", poll)
+	}
+	chirp, err := cfg.database.GetChirp(1)
+	if err != nil {
+		t.Fatalf("Couldn't get chirp: %v", err)
+	}
+	closed := newPollResponse(*chirp.Poll, nil, chirp.Poll.ClosesAt)
+	if !closed.Closed || closed.VoterCount == nil || *closed.VoterCount != 1 {
+		t.Errorf("Expected results to be shown once the poll closes, got %+v", closed)
+	}
+}
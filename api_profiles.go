@@ -0,0 +1,363 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"internal/database"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxWebsiteLength     = 100
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,15}$`)
+
+// reservedUsernames can't be claimed because they collide with routes or
+// could be used to impersonate the service.
+var reservedUsernames = map[string]bool{
+	"about": true, "admin": true, "administrator": true, "api": true,
+	"app": true, "chirpy": true, "explore": true, "help": true,
+	"home": true, "login": true, "logout": true, "me": true,
+	"media": true, "mod": true, "moderator": true, "notifications": true,
+	"null": true, "root": true, "search": true, "settings": true,
+	"signup": true, "support": true, "system": true, "timeline": true,
+	"undefined": true,
+}
+
+// userSummary is the compact, public view of a user embedded in other
+// responses.
+type userSummary struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	IsChirpyRed bool   `json:"is_chirpy_red"`
+}
+
+func newUserSummary(user database.User) userSummary {
+	return userSummary{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		AvatarURL:   mediaURL(user.Avatar),
+		IsChirpyRed: user.IsChirpyRed,
+	}
+}
+
+type publicProfile struct {
+	userSummary
+	Bio       string    `json:"bio,omitempty"`
+	Website   string    `json:"website,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	database.FollowCounts
+	Relationship *database.Relationship `json:"relationship,omitempty"`
+}
+
+func newPublicProfile(user database.User, counts database.FollowCounts) publicProfile {
+	return publicProfile{
+		userSummary:  newUserSummary(user),
+		Bio:          user.Bio,
+		Website:      user.Website,
+		CreatedAt:    user.CreatedAt,
+		FollowCounts: counts,
+	}
+}
+
+type userEntryResponse struct {
+	User      userSummary `json:"user"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func validateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return errors.New("Username must be 3 to 15 letters, digits or underscores")
+	}
+	if strings.Trim(username, "0123456789") == "" {
+		return errors.New("Username can't be only digits")
+	}
+	if reservedUsernames[strings.ToLower(username)] {
+		return errors.New("Username is reserved")
+	}
+	return nil
+}
+
+func validateProfile(profile database.Profile) error {
+	if profile.Username != "" {
+		if err := validateUsername(profile.Username); err != nil {
+			return err
+		}
+	}
+	if utf8.RuneCountInString(profile.DisplayName) > maxDisplayNameLength {
+		return fmt.Errorf("Display name can't be longer than %d characters", maxDisplayNameLength)
+	}
+	if utf8.RuneCountInString(profile.Bio) > maxBioLength {
+		return fmt.Errorf("Bio can't be longer than %d characters", maxBioLength)
+	}
+	if profile.Website != "" {
+		website, err := url.Parse(profile.Website)
+		if err != nil || (website.Scheme != "http" && website.Scheme != "https") || website.Host == "" {
+			return errors.New("Website must be an http or https URL")
+		}
+		if len(profile.Website) > maxWebsiteLength {
+			return fmt.Errorf("Website can't be longer than %d characters", maxWebsiteLength)
+		}
+	}
+	return nil
+}
+
+// resolveUser finds a user from a route parameter holding either a numeric
+// ID or a username, with or without the leading @.
+func (cfg *apiConfig) resolveUser(ref string) (database.User, error) {
+	ref = strings.TrimPrefix(ref, "@")
+	if id, err := strconv.Atoi(ref); err == nil {
+		return cfg.database.GetUser(id)
+	}
+	return cfg.database.GetUserByUsername(ref)
+}
+
+// resolveFilterUser resolves a user named in a query filter for viewerID.
+// Users on either side of a block with the viewer, or pending deletion, are
+// reported as not found.
+func (cfg *apiConfig) resolveFilterUser(ref string, viewerID int) (database.User, error) {
+	user, err := cfg.resolveUser(ref)
+	if err != nil {
+		return database.User{}, err
+	}
+	if user.PendingDeletion() {
+		return database.User{}, database.ErrNotExist
+	}
+	relationship, err := cfg.database.GetRelationship(viewerID, user.ID)
+	if err != nil {
+		return database.User{}, err
+	}
+	if relationship.Blocking || relationship.BlockedBy {
+		return database.User{}, database.ErrNotExist
+	}
+	return user, nil
+}
+
+// resolveVisibleUser resolves the {user} route parameter for the caller.
+// Users who block the caller, are suspended or banned, or are pending
+// deletion are reported as not found.
+func (cfg *apiConfig) resolveVisibleUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	user, err := cfg.resolveUser(chi.URLParam(r, "user"))
+	if err == nil && (user.Restricted(time.Now()) || user.PendingDeletion()) {
+		err = database.ErrNotExist
+	}
+	if err == nil {
+		var relationship database.Relationship
+		relationship, err = cfg.database.GetRelationship(userIDFromContext(r.Context()), user.ID)
+		if err == nil && relationship.BlockedBy {
+			err = database.ErrNotExist
+		}
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "User not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		}
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// presentUserEntries replaces the user IDs in entries with user summaries.
+func (cfg *apiConfig) presentUserEntries(entries []database.UserEntry) ([]userEntryResponse, error) {
+	userIDs := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		userIDs = append(userIDs, entry.UserID)
+	}
+	users, err := cfg.database.GetUsers(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]userEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		user, ok := users[entry.UserID]
+		if !ok {
+			continue
+		}
+		responses = append(responses, userEntryResponse{
+			User:      newUserSummary(user),
+			CreatedAt: entry.CreatedAt,
+		})
+	}
+	return responses, nil
+}
+
+func (cfg *apiConfig) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Username    *string `json:"username"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		Website     *string `json:"website"`
+	}
+	userId := userIDFromContext(r.Context())
+
+	params := parameters{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	user, err := cfg.database.GetUser(userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		return
+	}
+	profile := database.Profile{
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		Website:     user.Website,
+	}
+	if params.Username != nil {
+		profile.Username = strings.TrimPrefix(*params.Username, "@")
+	}
+	if params.DisplayName != nil {
+		profile.DisplayName = strings.TrimSpace(*params.DisplayName)
+	}
+	if params.Bio != nil {
+		profile.Bio = strings.TrimSpace(*params.Bio)
+	}
+	if params.Website != nil {
+		profile.Website = strings.TrimSpace(*params.Website)
+	}
+	if err := validateProfile(profile); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err = cfg.database.UpdateProfile(userId, profile)
+	if err != nil {
+		if errors.Is(err, database.ErrAlreadyExist) {
+			respondWithError(w, http.StatusConflict, "Username is already taken")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update profile")
+		}
+		return
+	}
+	cfg.respondWithOwnProfile(w, user)
+}
+
+// respondWithOwnProfile responds with the caller's profile after they change
+// it. It has the same shape as the public profile so account details like
+// the email and roles are never echoed back.
+func (cfg *apiConfig) respondWithOwnProfile(w http.ResponseWriter, user database.User) {
+	counts, err := cfg.database.GetFollowCounts(user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get follow counts")
+		return
+	}
+	respondWithJson(w, http.StatusOK, newPublicProfile(user, counts))
+}
+
+// getUserProfileHandler shows a user's public profile, along with how the
+// caller relates to them when the caller is signed in.
+func (cfg *apiConfig) getUserProfileHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.resolveVisibleUser(w, r)
+	if !ok {
+		return
+	}
+	counts, err := cfg.database.GetFollowCounts(user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get follow counts")
+		return
+	}
+	profile := newPublicProfile(user, counts)
+
+	viewerID := userIDFromContext(r.Context())
+	if viewerID != 0 && viewerID != user.ID {
+		relationship, err := cfg.database.GetRelationship(viewerID, user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get relationship")
+			return
+		}
+		profile.Relationship = &relationship
+	}
+	respondWithJson(w, http.StatusOK, profile)
+}
+
+func (cfg *apiConfig) getUserChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.resolveVisibleUser(w, r)
+	if !ok {
+		return
+	}
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	page, err := cfg.database.QueryChirps(database.ChirpQuery{
+		ViewerID:  userIDFromContext(r.Context()),
+		AuthorIDs: []int{user.ID},
+		Sort:      database.SortIDDesc,
+		Limit:     limit,
+		Cursor:    r.URL.Query().Get("cursor"),
+		PinnedID:  user.PinnedChirpID,
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps")
+		}
+		return
+	}
+
+	chirps := page.Chirps
+	if page.Pinned != nil {
+		chirps = append([]database.Chirp{*page.Pinned}, chirps...)
+	}
+	responseChirps, err := cfg.presentChirps(chirps, userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps")
+		return
+	}
+	if page.Pinned != nil {
+		responseChirps[0].Pinned = true
+	}
+	respondWithPage(w, r, responseChirps, page.NextCursor)
+}
+
+// pinChirpHandler pins one of the caller's chirps to their profile, in
+// place of any chirp pinned before.
+func (cfg *apiConfig) pinChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.pinHandler(w, r, cfg.database.PinChirp)
+}
+
+func (cfg *apiConfig) unpinChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.pinHandler(w, r, cfg.database.UnpinChirp)
+}
+
+func (cfg *apiConfig) pinHandler(w http.ResponseWriter, r *http.Request, apply func(userID, chirpID int) (database.User, error)) {
+	id, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+	_, err = apply(userIDFromContext(r.Context()), id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update pinned chirp")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
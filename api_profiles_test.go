@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"internal/database"
+	"net/http"
+	"testing"
+)
+
+func TestValidateUsername(t *testing.T) {
+	cases := []struct {
+		username string
+		valid    bool
+	}{
+		{username: "chirper_42", valid: true},
+		{username: "Bob", valid: true},
+		{username: "ab", valid: false},
+		{username: "this_is_way_too_long", valid: false},
+		{username: "no-dashes", valid: false},
+		{username: "12345", valid: false},
+		{username: "admin", valid: false},
+		{username: "Me", valid: false},
+	}
+	for _, c := range cases {
+		t.Run(c.username, func(t *testing.T) {
+			err := validateUsername(c.username)
+			if c.valid && err != nil {
+				t.Errorf("Expected %q to be valid, got %v", c.username, err)
+			}
+			if !c.valid && err == nil {
+				t.Errorf("Expected %q to be rejected", c.username)
+			}
+		})
+	}
+}
+
+func TestValidateProfileWebsite(t *testing.T) {
+	cases := []struct {
+		website string
+		valid   bool
+	}{
+		{website: "", valid: true},
+		{website: "https://example.com/me", valid: true},
+		{website: "javascript:alert(1)", valid: false},
+		{website: "example.com", valid: false},
+	}
+	for _, c := range cases {
+		t.Run(c.website, func(t *testing.T) {
+			err := validateProfile(database.Profile{Website: c.website})
+			if c.valid && err != nil {
+				t.Errorf("Expected %q to be valid, got %v", c.website, err)
+			}
+			if !c.valid && err == nil {
+				t.Errorf("Expected %q to be rejected", c.website)
+			}
+		})
+	}
+}
+
+func TestUpdateProfileResponse(t *testing.T) {
+	cfg := newTestConfig(t)
+	token := newTestUser(t, cfg, "profile@example.com")
+	server := newTestServer(t, cfg)
+
+	resp := server.send("PATCH", "/api/users/me", token, `{"username":"chirper","bio":"hello"}`)
+	profile := map[string]interface{}{}
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		t.Fatalf("Couldn't decode profile: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || profile["username"] != "chirper" || profile["bio"] != "hello" {
+		t.Fatalf("Expected the updated profile, got %d %+v", resp.StatusCode, profile)
+	}
+	for _, field := range []string{"email", "password", "roles", "status", "tokens_valid_after"} {
+		if _, ok := profile[field]; ok {
+			t.Errorf("Expected the profile not to include %s", field)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"internal/database"
+	"net/http"
+	"testing"
+)
+
+func TestQuoteChirps(t *testing.T) {
+	cfg := newTestConfig(t)
+	newTestUser(t, cfg, "author@example.com")
+	quoterToken := newTestUser(t, cfg, "quoter@example.com")
+	if _, err := cfg.database.CreateChirp(database.Chirp{Body: "followers only", AuthorId: 1, Visibility: database.VisibilityFollowers}); err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	if _, err := cfg.database.CreateChirp(database.Chirp{Body: "public", AuthorId: 1}); err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	decodeQuote := func(resp *http.Response) quoteResponse {
+		t.Helper()
+		chirp := struct {
+			Quote *quoteResponse `json:"quote"`
+		}{}
+		if err := json.NewDecoder(resp.Body).Decode(&chirp); err != nil {
+			t.Fatalf("Couldn't decode chirp: %v", err)
+		}
+		if chirp.Quote == nil {
+			t.Fatalf("Expected the chirp to quote another")
+		}
+		return *chirp.Quote
+	}
+
+	if resp := server.send("POST", "/api/chirps", quoterToken, `{"body":"psst","quote_of":1}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected quoting a chirp the author can't see to return 404, got %d", resp.StatusCode)
+	}
+	resp := server.send("POST", "/api/chirps", quoterToken, `{"body":"look","quote_of":2}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected the quote to be created, got %d", resp.StatusCode)
+	}
+	quote := decodeQuote(resp)
+	if quote.ID != 2 || quote.Body != "public" || quote.Author == nil || quote.Author.ID != 1 || quote.Unavailable {
+		t.Errorf("Expected a snapshot of the quoted chirp, got %+v", quote)
+	}
+
+	if err := cfg.database.DeleteChirp(2); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+	quote = decodeQuote(server.send("GET", "/api/chirps/3", "", ""))
+	if quote.ID != 2 || quote.Body != "" || !quote.Unavailable {
+		t.Errorf("Expected the deleted chirp to be unavailable, got %+v", quote)
+	}
+}
+
+func TestPinnedChirp(t *testing.T) {
+	cfg := newTestConfig(t)
+	token := newTestUser(t, cfg, "author@example.com")
+	otherToken := newTestUser(t, cfg, "other@example.com")
+	for _, body := range []string{"pin me", "newer", "newest"} {
+		if _, err := cfg.database.CreateChirp(database.Chirp{Body: body, AuthorId: 1}); err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	server := newTestServer(t, cfg)
+
+	listing := func() []chirpResponse {
+		t.Helper()
+		page := struct {
+			Data []chirpResponse `json:"data"`
+		}{}
+		if err := json.NewDecoder(server.send("GET", "/api/users/1/chirps", "", "").Body).Decode(&page); err != nil {
+			t.Fatalf("Couldn't decode chirps: %v", err)
+		}
+		return page.Data
+	}
+
+	if resp := server.send("POST", "/api/chirps/1/pin", otherToken, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected pinning another user's chirp to return 404, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/api/chirps/1/pin", token, ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected the chirp to be pinned, got %d", resp.StatusCode)
+	}
+	chirps := listing()
+	if len(chirps) != 3 || chirps[0].ID != 1 || !chirps[0].Pinned || chirps[1].ID != 3 || chirps[2].ID != 2 {
+		t.Errorf("Expected the pinned chirp first, got %+v", chirps)
+	}
+
+	if resp := server.send("DELETE", "/api/chirps/1/pin", token, ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected the chirp to be unpinned, got %d", resp.StatusCode)
+	}
+	chirps = listing()
+	if len(chirps) != 3 || chirps[0].ID != 3 || chirps[0].Pinned {
+		t.Errorf("Expected newest first after unpinning, got %+v", chirps)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"internal/database"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (cfg *apiConfig) addReactionHandler(reaction database.Reaction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg.reactionHandler(w, r, reaction, cfg.database.AddReaction)
+	}
+}
+
+func (cfg *apiConfig) removeReactionHandler(reaction database.Reaction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg.reactionHandler(w, r, reaction, cfg.database.RemoveReaction)
+	}
+}
+
+func (cfg *apiConfig) reactionHandler(w http.ResponseWriter, r *http.Request, reaction database.Reaction, apply func(chirpID, userID int, reaction database.Reaction) (database.Chirp, error)) {
+	userId := userIDFromContext(r.Context())
+
+	chirpID := chi.URLParam(r, "chirpID")
+	id, err := strconv.Atoi(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+
+	chirp, err := apply(id, userId, reaction)
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update "+string(reaction))
+		}
+		return
+	}
+
+	response, err := cfg.presentChirp(chirp, userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp")
+		return
+	}
+	respondWithJson(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) getReactionsHandler(reaction database.Reaction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chirpID := chi.URLParam(r, "chirpID")
+		id, err := strconv.Atoi(chirpID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+			return
+		}
+		limit, err := parseLimitParam(r)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+
+		page, err := cfg.database.GetReactions(id, userIDFromContext(r.Context()), reaction, r.URL.Query().Get("cursor"), limit)
+		if err != nil {
+			switch {
+			case errors.Is(err, database.ErrNotExist):
+				respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+			case errors.Is(err, database.ErrInvalidCursor):
+				respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			default:
+				respondWithError(w, http.StatusInternalServerError, "Couldn't get "+string(reaction)+"s")
+			}
+			return
+		}
+		entries, err := cfg.presentUserEntries(page.Entries)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get "+string(reaction)+"s")
+			return
+		}
+		respondWithPage(w, r, entries, page.NextCursor)
+	}
+}
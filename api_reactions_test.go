@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"internal/database"
+	"net/http"
+	"testing"
+)
+
+func TestReactionFlags(t *testing.T) {
+	cfg := newTestConfig(t)
+	newTestUser(t, cfg, "author@example.com")
+	fanToken := newTestUser(t, cfg, "fan@example.com")
+	otherToken := newTestUser(t, cfg, "other@example.com")
+	if _, err := cfg.database.CreateChirp(database.Chirp{Body: "like me", AuthorId: 1}); err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	send := func(method, path, token string) chirpResponse {
+		t.Helper()
+		resp := server.send(method, path, token, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected %s %s to return 200, got %d", method, path, resp.StatusCode)
+		}
+		chirp := chirpResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&chirp); err != nil {
+			t.Fatalf("Couldn't decode chirp: %v", err)
+		}
+		return chirp
+	}
+	flag := func(b *bool) string {
+		if b == nil {
+			return "unset"
+		}
+		if *b {
+			return "true"
+		}
+		return "false"
+	}
+
+	if chirp := send("POST", "/api/chirps/1/like", fanToken); chirp.LikeCount != 1 || flag(chirp.LikedByMe) != "true" || flag(chirp.RechirpedByMe) != "false" {
+		t.Errorf("Expected the like to be reflected for the fan, got %d likes, liked %s, rechirped %s", chirp.LikeCount, flag(chirp.LikedByMe), flag(chirp.RechirpedByMe))
+	}
+	if chirp := send("POST", "/api/chirps/1/rechirp", fanToken); flag(chirp.LikedByMe) != "true" || flag(chirp.RechirpedByMe) != "true" {
+		t.Errorf("Expected both flags for the fan, got liked %s, rechirped %s", flag(chirp.LikedByMe), flag(chirp.RechirpedByMe))
+	}
+	if chirp := send("GET", "/api/chirps/1", otherToken); flag(chirp.LikedByMe) != "false" || flag(chirp.RechirpedByMe) != "false" {
+		t.Errorf("Expected no flags for another user, got liked %s, rechirped %s", flag(chirp.LikedByMe), flag(chirp.RechirpedByMe))
+	}
+	if chirp := send("GET", "/api/chirps/1", ""); flag(chirp.LikedByMe) != "unset" || flag(chirp.RechirpedByMe) != "unset" {
+		t.Errorf("Expected no flags for an anonymous viewer, got liked %s, rechirped %s", flag(chirp.LikedByMe), flag(chirp.RechirpedByMe))
+	}
+	if chirp := send("DELETE", "/api/chirps/1/like", fanToken); chirp.LikeCount != 0 || flag(chirp.LikedByMe) != "false" || flag(chirp.RechirpedByMe) != "true" {
+		t.Errorf("Expected only the like to be removed, got %d likes, liked %s, rechirped %s", chirp.LikeCount, flag(chirp.LikedByMe), flag(chirp.RechirpedByMe))
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"internal/database"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxReportCommentLength = 500
+
+// reportResponse is a report as its reporter sees it, without the
+// moderator's identity or notes.
+type reportResponse struct {
+	ID         int                       `json:"id"`
+	ChirpID    int                       `json:"chirp_id,omitempty"`
+	UserID     int                       `json:"user_id"`
+	Reason     database.ReportReason     `json:"reason"`
+	Comment    string                    `json:"comment,omitempty"`
+	Status     database.ReportStatus     `json:"status"`
+	Outcome    database.ModerationAction `json:"outcome,omitempty"`
+	ResolvedAt *time.Time                `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time                 `json:"created_at"`
+}
+
+func newReportResponse(report database.Report) reportResponse {
+	response := reportResponse{
+		ID:        report.ID,
+		ChirpID:   report.ChirpID,
+		UserID:    report.UserID,
+		Reason:    report.Reason,
+		Comment:   report.Comment,
+		Status:    report.Status,
+		CreatedAt: report.CreatedAt,
+	}
+	if report.Resolution != nil {
+		response.Outcome = report.Resolution.Action
+		response.ResolvedAt = &report.Resolution.ResolvedAt
+	}
+	return response
+}
+
+func (cfg *apiConfig) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ChirpID int                   `json:"chirp_id"`
+		UserID  int                   `json:"user_id"`
+		Reason  database.ReportReason `json:"reason"`
+		Comment string                `json:"comment"`
+	}
+	params := parameters{}
+	params, err := decodeJsonBody(r.Body, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	if (params.ChirpID == 0) == (params.UserID == 0) {
+		respondWithError(w, http.StatusBadRequest, "Report either a chirp_id or a user_id")
+		return
+	}
+	if !params.Reason.Valid() {
+		respondWithError(w, http.StatusBadRequest, "Invalid reason, expected one of spam, harassment, hate, violence, impersonation or other")
+		return
+	}
+	if len(params.Comment) > maxReportCommentLength {
+		respondWithError(w, http.StatusBadRequest, "Comment is too long")
+		return
+	}
+
+	report, err := cfg.database.CreateReport(database.Report{
+		ReporterID: userIDFromContext(r.Context()),
+		ChirpID:    params.ChirpID,
+		UserID:     params.UserID,
+		Reason:     params.Reason,
+		Comment:    params.Comment,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotExist) && params.ChirpID != 0:
+			respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		case errors.Is(err, database.ErrNotExist):
+			respondWithError(w, http.StatusNotFound, "User not found")
+		case errors.Is(err, database.ErrInvalidReport):
+			respondWithError(w, http.StatusBadRequest, "You can't report yourself")
+		case errors.Is(err, database.ErrAlreadyExist):
+			respondWithError(w, http.StatusConflict, "You already reported this")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create report")
+		}
+		return
+	}
+	respondWithJson(w, http.StatusCreated, newReportResponse(report))
+}
+
+// getReportHandler lets reporters follow up on their own reports.
+func (cfg *apiConfig) getReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := reportIDParam(w, r)
+	if !ok {
+		return
+	}
+	report, err := cfg.database.GetReport(id)
+	if err == nil && report.ReporterID != userIDFromContext(r.Context()) {
+		err = database.ErrNotExist
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find report")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get report")
+		}
+		return
+	}
+	respondWithJson(w, http.StatusOK, newReportResponse(report))
+}
+
+func reportIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report id")
+		return 0, false
+	}
+	return id, true
+}
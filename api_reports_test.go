@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"internal/database"
+	"net/http"
+	"testing"
+)
+
+func TestReports(t *testing.T) {
+	cfg := newTestConfig(t)
+	reporterToken := newTestUser(t, cfg, "reporter@example.com")
+	authorToken := newTestUser(t, cfg, "author@example.com")
+	moderatorToken := newTestUser(t, cfg, "moderator@example.com", database.RoleModerator)
+	if _, err := cfg.database.CreateChirp(database.Chirp{Body: "buy now", AuthorId: 2}); err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"chirp", `{"chirp_id":1,"reason":"spam"}`, http.StatusCreated},
+		{"duplicate", `{"chirp_id":1,"reason":"spam"}`, http.StatusConflict},
+		{"user", `{"user_id":2,"reason":"impersonation","comment":"not really them"}`, http.StatusCreated},
+		{"yourself", `{"user_id":1,"reason":"spam"}`, http.StatusBadRequest},
+		{"chirp and user", `{"chirp_id":1,"user_id":2,"reason":"spam"}`, http.StatusBadRequest},
+		{"unknown reason", `{"chirp_id":1,"reason":"boring"}`, http.StatusBadRequest},
+		{"missing chirp", `{"chirp_id":9,"reason":"spam"}`, http.StatusNotFound},
+		{"missing user", `{"user_id":9,"reason":"spam"}`, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if resp := server.send("POST", "/api/reports", reporterToken, c.body); resp.StatusCode != c.status {
+				t.Errorf("Expected %d, got %d", c.status, resp.StatusCode)
+			}
+		})
+	}
+	if resp := server.send("POST", "/api/reports", "", `{"chirp_id":1,"reason":"spam"}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected an anonymous report to return 401, got %d", resp.StatusCode)
+	}
+
+	for name, token := range map[string]string{"reported author": authorToken, "moderator": moderatorToken} {
+		if resp := server.send("GET", "/api/reports/1", token, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected the %s to not see someone else's report, got %d", name, resp.StatusCode)
+		}
+	}
+	if resp := server.send("GET", "/admin/reports/1", authorToken, ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a user to not see the report queue, got %d", resp.StatusCode)
+	}
+	if resp := server.send("POST", "/admin/reports/1/resolve", moderatorToken, `{"action":"dismiss","note":"ads are allowed"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected a moderator to dismiss the report, got %d", resp.StatusCode)
+	}
+
+	resp := server.send("GET", "/api/reports/1", reporterToken, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the reporter to see their report, got %d", resp.StatusCode)
+	}
+	report := map[string]any{}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("Couldn't decode report: %v", err)
+	}
+	if report["status"] != string(database.ReportResolved) || report["outcome"] != string(database.ModerationDismiss) {
+		t.Errorf("Expected the reporter to see the outcome, got %+v", report)
+	}
+	for _, field := range []string{"reporter_id", "moderator_id", "claimed_at", "resolution"} {
+		if _, ok := report[field]; ok {
+			t.Errorf("Expected the reporter to not see %s, got %+v", field, report)
+		}
+	}
+	if resp := server.send("GET", "/api/reports/9", reporterToken, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a missing report to return 404, got %d", resp.StatusCode)
+	}
+}
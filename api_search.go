@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"internal/database"
+	"net/http"
+)
+
+type chirpSearchResponse struct {
+	chirpResponse
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+type userSearchResponse struct {
+	userSummary
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// searchHandler runs a full-text search over chirps, or over user profiles
+// with type=users. Snippets are HTML-escaped with matches wrapped in <mark>.
+func (cfg *apiConfig) searchHandler(w http.ResponseWriter, r *http.Request) {
+	query := database.SearchQuery{
+		ViewerID: userIDFromContext(r.Context()),
+		Text:     r.URL.Query().Get("q"),
+		Hashtag:  r.URL.Query().Get("hashtag"),
+		Cursor:   r.URL.Query().Get("cursor"),
+	}
+
+	if authorParam := r.URL.Query().Get("author"); authorParam != "" {
+		author, err := cfg.resolveFilterUser(authorParam, query.ViewerID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotExist) {
+				respondWithError(w, http.StatusNotFound, "Couldn't find author")
+			} else {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't find author")
+			}
+			return
+		}
+		query.AuthorID = author.ID
+	}
+
+	var err error
+	query.Since, err = parseTimeParam(r, "since")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid since, expected an RFC 3339 time")
+		return
+	}
+	query.Until, err = parseTimeParam(r, "until")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid until, expected an RFC 3339 time")
+		return
+	}
+	query.Limit, err = parseLimitParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	switch r.URL.Query().Get("type") {
+	case "", "chirps":
+		cfg.searchChirps(w, r, query)
+	case "users":
+		cfg.searchUsers(w, r, query)
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid type, expected chirps or users")
+	}
+}
+
+func (cfg *apiConfig) searchChirps(w http.ResponseWriter, r *http.Request, query database.SearchQuery) {
+	page, err := cfg.database.SearchChirps(query)
+	if err != nil {
+		respondWithSearchError(w, err)
+		return
+	}
+
+	chirps := make([]database.Chirp, 0, len(page.Results))
+	for _, result := range page.Results {
+		chirps = append(chirps, result.Chirp)
+	}
+	responseChirps, err := cfg.presentChirps(chirps, userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search chirps")
+		return
+	}
+
+	results := make([]chirpSearchResponse, 0, len(page.Results))
+	for i, result := range page.Results {
+		results = append(results, chirpSearchResponse{
+			chirpResponse: responseChirps[i],
+			Score:         result.Score,
+			Snippet:       result.Snippet,
+		})
+	}
+	respondWithPage(w, r, results, page.NextCursor)
+}
+
+func (cfg *apiConfig) searchUsers(w http.ResponseWriter, r *http.Request, query database.SearchQuery) {
+	page, err := cfg.database.SearchUsers(query)
+	if err != nil {
+		respondWithSearchError(w, err)
+		return
+	}
+
+	results := make([]userSearchResponse, 0, len(page.Results))
+	for _, result := range page.Results {
+		results = append(results, userSearchResponse{
+			userSummary: newUserSummary(result.User),
+			Score:       result.Score,
+			Snippet:     result.Snippet,
+		})
+	}
+	respondWithPage(w, r, results, page.NextCursor)
+}
+
+func respondWithSearchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrInvalidQuery):
+		respondWithError(w, http.StatusBadRequest, "Invalid query, expected at least one search term")
+	case errors.Is(err, database.ErrInvalidCursor):
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+	default:
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSearchAuthorFilter(t *testing.T) {
+	cfg := newTestConfig(t)
+	viewerToken := newTestUser(t, cfg, "viewer@example.com")
+	newTestUser(t, cfg, "blocker@example.com")
+	newTestUser(t, cfg, "blocked@example.com")
+	if err := cfg.database.Block(2, 1); err != nil {
+		t.Fatalf("Couldn't block: %v", err)
+	}
+	if err := cfg.database.Block(1, 3); err != nil {
+		t.Fatalf("Couldn't block: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	search := func(query, token string) int {
+		t.Helper()
+		return server.send("GET", "/api/search?q=hello&"+query, token, "").StatusCode
+	}
+
+	cases := []struct {
+		name   string
+		query  string
+		token  string
+		status int
+	}{
+		{"visible author", "author=1", "", http.StatusOK},
+		{"blocked by author", "author=2", viewerToken, http.StatusNotFound},
+		{"blocking author", "author=3", viewerToken, http.StatusNotFound},
+		{"anonymous viewer", "author=2", "", http.StatusOK},
+		{"missing author", "author=4", viewerToken, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if status := search(c.query, c.token); status != c.status {
+				t.Errorf("Expected %d, got %d", c.status, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"internal/auth"
+	"internal/database"
+	"internal/stream"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamBufferSize       = 1000
+	streamSubscriberBuffer = 64
+	streamHeartbeat        = 15 * time.Second
+	streamWriteTimeout     = 10 * time.Second
+	streamRetry            = 3 * time.Second
+)
+
+const (
+	eventChirpCreated     = "chirp_created"
+	eventChirpDeleted     = "chirp_deleted"
+	eventMessageCreated   = "message_created"
+	eventConversationRead = "conversation_read"
+	// eventReset tells a resuming client that events were missed and it
+	// should reload instead of relying on the stream.
+	eventReset = "reset"
+)
+
+// chirpDeletedEvent keeps the deleted chirp so the event only reaches the
+// viewers who could see it.
+type chirpDeletedEvent struct {
+	ID       int `json:"id"`
+	AuthorID int `json:"author_id"`
+	chirp    database.Chirp
+}
+
+// messageCreatedEvent and conversationReadEvent are only delivered to the
+// conversation's participants.
+type messageCreatedEvent struct {
+	database.Message
+	recipients []int
+}
+
+type conversationReadEvent struct {
+	ConversationID int       `json:"conversation_id"`
+	UserID         int       `json:"user_id"`
+	MessageID      int       `json:"message_id"`
+	ReadAt         time.Time `json:"read_at"`
+	recipients     []int
+}
+
+type streamMessage struct {
+	ID   uint64      `json:"id,omitempty"`
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// streamTicketLifetime is how long a client has to open a stream with a
+// ticket. The stream stays open after the ticket expires.
+const streamTicketLifetime = time.Minute
+
+// createStreamTicketHandler issues a short-lived ticket for opening a stream
+// from clients that can't send the Authorization header.
+func (cfg *apiConfig) createStreamTicketHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Ticket    string    `json:"ticket"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	ticket, err := auth.GenerateStreamTicket(userIDFromContext(r.Context()), cfg.jwtSecret, streamTicketLifetime)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create stream ticket")
+		return
+	}
+	respondWithJson(w, http.StatusCreated, response{
+		Ticket:    ticket,
+		ExpiresAt: time.Now().Add(streamTicketLifetime).UTC(),
+	})
+}
+
+// checkStreamOrigin lets WebSockets be opened from pages served by this host
+// or one of the configured stream origins. Requests without an Origin header
+// don't come from a browser and are let through.
+func (cfg *apiConfig) checkStreamOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if cfg.streamOrigins[origin] {
+		return true
+	}
+	originURL, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(originURL.Host, r.Host)
+}
+
+func (cfg *apiConfig) publishChirpCreated(chirp database.Chirp) {
+	response, err := cfg.presentChirp(chirp, 0)
+	if err != nil {
+		log.Printf("Couldn't publish chirp %d: %v", chirp.ID, err)
+		return
+	}
+	cfg.hub.Publish(eventChirpCreated, response)
+}
+
+func (cfg *apiConfig) publishChirpDeleted(chirp database.Chirp) {
+	cfg.hub.Publish(eventChirpDeleted, chirpDeletedEvent{
+		ID:       chirp.ID,
+		AuthorID: chirp.AuthorId,
+		chirp:    chirp,
+	})
+}
+
+func (cfg *apiConfig) publishMessageCreated(message database.Message, conversation database.Conversation) {
+	cfg.hub.Publish(eventMessageCreated, messageCreatedEvent{
+		Message:    message,
+		recipients: conversation.ParticipantIDs,
+	})
+}
+
+func (cfg *apiConfig) publishConversationRead(conversation database.Conversation, userID int) {
+	receipt := conversation.ReadReceipts[userID]
+	cfg.hub.Publish(eventConversationRead, conversationReadEvent{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+		MessageID:      receipt.MessageID,
+		ReadAt:         receipt.ReadAt,
+		recipients:     conversation.ParticipantIDs,
+	})
+}
+
+// streamFilter selects the events a connection receives. Zero fields match
+// everything, and homeUserID limits events to that user's home timeline.
+// Events are hidden from viewerID like they are in timelines.
+type streamFilter struct {
+	viewerID   int
+	authorID   int
+	hashtag    string
+	homeUserID int
+}
+
+// streamFilterFromRequest reads the author, hashtag and timeline query
+// parameters, responding with an error if they are invalid.
+func (cfg *apiConfig) streamFilterFromRequest(w http.ResponseWriter, r *http.Request) (streamFilter, bool) {
+	filter := streamFilter{
+		viewerID: userIDFromContext(r.Context()),
+		hashtag:  database.NormalizeHashtag(r.URL.Query().Get("hashtag")),
+	}
+	if authorParam := r.URL.Query().Get("author"); authorParam != "" {
+		author, err := cfg.resolveUser(authorParam)
+		if err != nil {
+			if errors.Is(err, database.ErrNotExist) {
+				respondWithError(w, http.StatusNotFound, "Couldn't find author")
+			} else {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't find author")
+			}
+			return filter, false
+		}
+		filter.authorID = author.ID
+	}
+	switch r.URL.Query().Get("timeline") {
+	case "":
+	case "home":
+		filter.homeUserID = userIDFromContext(r.Context())
+		if filter.homeUserID == 0 {
+			respondWithError(w, http.StatusUnauthorized, "The home timeline requires a JWT token")
+			return filter, false
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid timeline, expected home")
+		return filter, false
+	}
+	return filter, true
+}
+
+func (cfg *apiConfig) streamMatches(filter streamFilter, event stream.Event) bool {
+	var chirp database.Chirp
+	switch data := event.Data.(type) {
+	case messageCreatedEvent:
+		return cfg.streamDeliversPrivately(filter, data.recipients, data.SenderID)
+	case conversationReadEvent:
+		return cfg.streamDeliversPrivately(filter, data.recipients, data.UserID)
+	case chirpResponse:
+		chirp = data.Chirp
+	case chirpDeletedEvent:
+		chirp = data.chirp
+	default:
+		return false
+	}
+	authorID := chirp.AuthorId
+
+	if filter.authorID != 0 && authorID != filter.authorID {
+		return false
+	}
+	visible, err := cfg.database.CanView(filter.viewerID, chirp)
+	if err != nil || !visible {
+		return false
+	}
+	scoped := filter.authorID != 0 || filter.homeUserID != 0
+	if !scoped && !chirp.Listed() && authorID != filter.viewerID {
+		return false
+	}
+	if filter.viewerID != 0 && filter.authorID == 0 {
+		muted, err := cfg.database.IsMuted(filter.viewerID, authorID)
+		if err != nil || muted {
+			return false
+		}
+	}
+	if filter.hashtag != "" {
+		tagged := false
+		for _, tag := range chirp.Hashtags() {
+			tagged = tagged || tag == filter.hashtag
+		}
+		if !tagged {
+			return false
+		}
+	}
+	if filter.homeUserID != 0 && authorID != filter.homeUserID {
+		following, err := cfg.database.IsFollowing(filter.homeUserID, authorID)
+		return err == nil && following
+	}
+	return true
+}
+
+// streamDeliversPrivately reports whether an event caused by actorID in a
+// conversation reaches the viewer. The chirp filters don't apply: every
+// stream a participant opens receives their conversations' events, except
+// those from users blocking or blocked by them.
+func (cfg *apiConfig) streamDeliversPrivately(filter streamFilter, recipients []int, actorID int) bool {
+	for _, userID := range recipients {
+		if userID == filter.viewerID && userID != 0 {
+			blocked, err := cfg.database.IsBlocked(filter.viewerID, actorID)
+			return err == nil && !blocked
+		}
+	}
+	return false
+}
+
+// subscribe starts a subscription resuming after the Last-Event-ID header
+// or last_event_id query parameter, responding with an error on failure.
+func (cfg *apiConfig) subscribe(w http.ResponseWriter, r *http.Request) (sub *stream.Subscription, replay []stream.Event, complete bool, ok bool) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	var lastEventID uint64
+	if value != "" {
+		var err error
+		lastEventID, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid last event id")
+			return nil, nil, false, false
+		}
+	}
+
+	sub, replay, complete, err := cfg.hub.Subscribe(lastEventID)
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "Server is shutting down")
+		return nil, nil, false, false
+	}
+	return sub, replay, complete, true
+}
+
+// streamHandler pushes chirp events as Server-Sent Events. Comments are sent
+// as heartbeats so idle connections aren't closed by proxies.
+func (cfg *apiConfig) streamHandler(w http.ResponseWriter, r *http.Request) {
+	filter, ok := cfg.streamFilterFromRequest(w, r)
+	if !ok {
+		return
+	}
+	sub, replay, complete, ok := cfg.subscribe(w, r)
+	if !ok {
+		return
+	}
+	defer cfg.hub.Unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	write := func(format string, args ...interface{}) error {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	writeEvent := func(event stream.Event) error {
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			return err
+		}
+		return write("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := write("retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+		return
+	}
+	if !complete {
+		if err := write("event: %s\ndata: {}\n\n", eventReset); err != nil {
+			return
+		}
+	}
+	for _, event := range replay {
+		if cfg.streamMatches(filter, event) {
+			if err := writeEvent(event); err != nil {
+				return
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, open := <-sub.Events():
+			if !open {
+				if errors.Is(sub.Err(), stream.ErrSlowConsumer) {
+					write("event: error\ndata: {\"error\":%q}\n\n", "Stream fell behind, reconnect to resume")
+				}
+				return
+			}
+			if !cfg.streamMatches(filter, event) {
+				continue
+			}
+			if err := writeEvent(event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// streamWebSocketHandler pushes the same events as streamHandler over a
+// WebSocket, as JSON messages. Pings are sent as heartbeats and clients that
+// stop answering them are disconnected.
+func (cfg *apiConfig) streamWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	filter, ok := cfg.streamFilterFromRequest(w, r)
+	if !ok {
+		return
+	}
+	sub, replay, complete, ok := cfg.subscribe(w, r)
+	if !ok {
+		return
+	}
+	defer cfg.hub.Unsubscribe(sub)
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     cfg.checkStreamOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	cfg.webSockets.Add(1)
+	defer cfg.webSockets.Done()
+
+	// Clients only send control frames, which are handled while reading.
+	disconnected := make(chan struct{})
+	conn.SetReadLimit(1024)
+	conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	})
+	go func() {
+		defer close(disconnected)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(message streamMessage) error {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		return conn.WriteJSON(message)
+	}
+	closeWith := func(code int, reason string) {
+		message := websocket.FormatCloseMessage(code, reason)
+		conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(streamWriteTimeout))
+	}
+
+	if !complete {
+		if err := write(streamMessage{Type: eventReset}); err != nil {
+			return
+		}
+	}
+	for _, event := range replay {
+		if cfg.streamMatches(filter, event) {
+			if err := write(streamMessage{ID: event.ID, Type: event.Type, Data: event.Data}); err != nil {
+				return
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, open := <-sub.Events():
+			if !open {
+				if errors.Is(sub.Err(), stream.ErrSlowConsumer) {
+					closeWith(websocket.CloseTryAgainLater, "Stream fell behind, reconnect to resume")
+				} else {
+					closeWith(websocket.CloseGoingAway, "Server is shutting down")
+				}
+				return
+			}
+			if !cfg.streamMatches(filter, event) {
+				continue
+			}
+			if err := write(streamMessage{ID: event.ID, Type: event.Type, Data: event.Data}); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+			if err != nil {
+				return
+			}
+		case <-disconnected:
+			return
+		}
+	}
+}
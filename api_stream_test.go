@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestStreamTicket(t *testing.T) {
+	cfg := newTestConfig(t)
+	token := newTestUser(t, cfg, "reader@example.com")
+	server := newTestServer(t, cfg)
+
+	if resp := server.send("POST", "/api/stream/ticket", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a ticket to require auth, got %d", resp.StatusCode)
+	}
+	resp := server.send("POST", "/api/stream/ticket", token, "")
+	ticket := struct {
+		Ticket string `json:"ticket"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&ticket); err != nil {
+		t.Fatalf("Couldn't decode ticket: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated || ticket.Ticket == "" {
+		t.Fatalf("Expected a ticket, got %d %+v", resp.StatusCode, ticket)
+	}
+
+	cases := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"ticket", "ticket=" + ticket.Ticket, http.StatusOK},
+		{"access token as ticket", "ticket=" + token, http.StatusUnauthorized},
+		{"access token in query", "access_token=" + token, http.StatusUnauthorized},
+		{"invalid ticket", "ticket=nonsense", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if resp := server.send("GET", "/api/stream?timeline=home&"+c.query, "", ""); resp.StatusCode != c.status {
+				t.Errorf("Expected %d, got %d", c.status, resp.StatusCode)
+			}
+		})
+	}
+
+	// Tickets end up in access logs, so they mustn't stand in for a
+	// refresh token.
+	for _, path := range []string{"/api/refresh", "/api/revoke"} {
+		if resp := server.send("POST", path, ticket.Ticket, ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected POST %s to reject a ticket, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestStreamWebSocketOrigin(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.streamOrigins = map[string]bool{"https://app.example.com": true}
+	server := newTestServer(t, cfg)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/stream/ws"
+
+	cases := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{"no origin", "", true},
+		{"same host", server.URL, true},
+		{"configured origin", "https://app.example.com", true},
+		{"other origin", "https://evil.example.com", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			header := http.Header{}
+			if c.origin != "" {
+				header.Set("Origin", c.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+			if conn != nil {
+				conn.Close()
+			}
+			if c.allowed && err != nil {
+				t.Errorf("Expected the connection to open, got %v", err)
+			}
+			if !c.allowed && (err == nil || resp == nil || resp.StatusCode != http.StatusForbidden) {
+				t.Errorf("Expected the connection to be refused with 403, got %v", err)
+			}
+		})
+	}
+}
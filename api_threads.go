@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"internal/database"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type threadNode struct {
+	chirpResponse
+	Replies []threadNode `json:"replies"`
+}
+
+type threadEntry struct {
+	chirpResponse
+	Depth int `json:"depth"`
+}
+
+func (cfg *apiConfig) getChirpThreadHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID := chi.URLParam(r, "chirpID")
+	id, err := strconv.Atoi(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "tree" && format != "flat" {
+		respondWithError(w, http.StatusBadRequest, "Invalid format, expected tree or flat")
+		return
+	}
+
+	thread, err := cfg.database.GetThread(id, userIDFromContext(r.Context()))
+	if err != nil {
+		if errors.Is(err, database.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get thread")
+		}
+		return
+	}
+
+	responses, err := cfg.presentChirps(thread, userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get thread")
+		return
+	}
+	responsesByID := make(map[int]chirpResponse, len(responses))
+	for _, response := range responses {
+		responsesByID[response.ID] = response
+	}
+
+	root, children := groupReplies(thread)
+	if format == "flat" {
+		entries := make([]threadEntry, 0, len(thread))
+		var walk func(chirp database.Chirp, depth int)
+		walk = func(chirp database.Chirp, depth int) {
+			entries = append(entries, threadEntry{chirpResponse: responsesByID[chirp.ID], Depth: depth})
+			for _, reply := range children[chirp.ID] {
+				walk(reply, depth+1)
+			}
+		}
+		walk(root, 0)
+		respondWithJson(w, http.StatusOK, entries)
+		return
+	}
+
+	var build func(chirp database.Chirp) threadNode
+	build = func(chirp database.Chirp) threadNode {
+		node := threadNode{chirpResponse: responsesByID[chirp.ID], Replies: make([]threadNode, 0, len(children[chirp.ID]))}
+		for _, reply := range children[chirp.ID] {
+			node.Replies = append(node.Replies, build(reply))
+		}
+		return node
+	}
+	respondWithJson(w, http.StatusOK, build(root))
+}
+
+// groupReplies splits a thread ordered by ID into its root chirp and the
+// replies to each chirp, keeping replies in the order they were posted.
+func groupReplies(thread []database.Chirp) (database.Chirp, map[int][]database.Chirp) {
+	root := thread[0]
+	children := make(map[int][]database.Chirp)
+	for _, chirp := range thread {
+		if chirp.ID == chirp.ThreadID {
+			root = chirp
+			continue
+		}
+		children[chirp.InReplyTo] = append(children[chirp.InReplyTo], chirp)
+	}
+	return root, children
+}
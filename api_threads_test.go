@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"internal/database"
+	"net/http"
+	"testing"
+)
+
+func TestGetChirpThread(t *testing.T) {
+	cfg := newTestConfig(t)
+	newTestUser(t, cfg, "author@example.com")
+	// 1 is the root, 2 and 4 reply to it and 3 replies to 2.
+	for _, chirp := range []database.Chirp{
+		{Body: "root", AuthorId: 1},
+		{Body: "reply", AuthorId: 1, InReplyTo: 1},
+		{Body: "nested reply", AuthorId: 1, InReplyTo: 2},
+		{Body: "second reply", AuthorId: 1, InReplyTo: 1},
+	} {
+		if _, err := cfg.database.CreateChirp(chirp); err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	if err := cfg.database.DeleteChirp(2); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+	server := newTestServer(t, cfg)
+
+	get := func(path string) *http.Response {
+		t.Helper()
+		return server.send("GET", path, "", "")
+	}
+
+	resp := get("/api/chirps/3/thread")
+	root := threadNode{}
+	if err := json.NewDecoder(resp.Body).Decode(&root); err != nil {
+		t.Fatalf("Couldn't decode thread: %v", err)
+	}
+	if root.ID != 1 || len(root.Replies) != 2 || root.Replies[0].ID != 2 || root.Replies[1].ID != 4 {
+		t.Fatalf("Expected the root with both replies in order, got %+v", root)
+	}
+	if tombstone := root.Replies[0]; !tombstone.Deleted || tombstone.Body != "" || len(tombstone.Replies) != 1 || tombstone.Replies[0].ID != 3 {
+		t.Errorf("Expected the deleted reply as a tombstone holding its reply, got %+v", tombstone)
+	}
+
+	resp = get("/api/chirps/1/thread?format=flat")
+	entries := []threadEntry{}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		t.Fatalf("Couldn't decode thread: %v", err)
+	}
+	want := []struct{ id, depth int }{{1, 0}, {2, 1}, {3, 2}, {4, 1}}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %+v", len(want), entries)
+	}
+	for i, entry := range entries {
+		if entry.ID != want[i].id || entry.Depth != want[i].depth {
+			t.Errorf("Expected entry %d to be chirp %d at depth %d, got chirp %d at depth %d", i, want[i].id, want[i].depth, entry.ID, entry.Depth)
+		}
+	}
+
+	if resp := get("/api/chirps/1/thread?format=list"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an unknown format to return 400, got %d", resp.StatusCode)
+	}
+}
@@ -1,12 +1,24 @@
 package main
 
 import (
+	"internal/audit"
 	"internal/auth"
 	"internal/database"
-	"log"
 	"net/http"
 )
 
+const (
+	planFree = "free"
+	planRed  = "red"
+)
+
+func userPlan(user database.User) string {
+	if user.IsChirpyRed {
+		return planRed
+	}
+	return planFree
+}
+
 func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
@@ -36,6 +48,8 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 		ID:          user.ID,
 		Email:       user.Email,
 		IsChirpyRed: user.IsChirpyRed,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
 	})
 }
 
@@ -45,26 +59,10 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 		Password string `json:"password"`
 	}
 
-	authHeader := r.Header.Get("Authorization")
-	token, err := auth.ValidateJWTToken(authHeader, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid JWT token")
-		return
-	}
-	userId, err := auth.GetUserFromTokenClaims(token)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error while decoding JWT token")
-		return
-	}
-	issuer, err := token.Claims.GetIssuer()
-	log.Printf("JWT ISSUER %v", issuer)
-	if err != nil || issuer == "chirpy-refresh" {
-		respondWithError(w, http.StatusUnauthorized, "Invalid JWT token issuer")
-		return
-	}
+	userId := userIDFromContext(r.Context())
 
 	params := parameters{}
-	params, err = decodeJsonBody(r.Body, params)
+	params, err := decodeJsonBody(r.Body, params)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
 		return
@@ -81,9 +79,16 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusInternalServerError, "Couldn't update user")
 		return
 	}
+	cfg.recordAudit(r, audit.Event{
+		Action:  auditUpdateCredentials,
+		Target:  userTarget(user.ID),
+		Outcome: audit.OutcomeSuccess,
+	})
 	respondWithJson(w, 200, database.User{
 		ID:          user.ID,
 		Email:       user.Email,
 		IsChirpyRed: user.IsChirpyRed,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
 	})
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"internal/audit"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// Audited actions, named after what they act on.
+const (
+	auditLogin             = "auth.login"
+	auditRevokeToken       = "auth.revoke"
+	auditUpdateCredentials = "user.update_credentials"
+	auditExportAccount     = "user.export"
+	auditDeleteAccount     = "user.delete"
+	auditPurgeAccount      = "user.purge"
+	auditChangeStatus      = "user.status"
+	auditGrantRole         = "role.grant"
+	auditRevokeRole        = "role.revoke"
+	auditDeleteChirp       = "chirp.delete"
+	auditPolkaUpgrade      = "polka.upgrade"
+)
+
+func userTarget(id int) string {
+	return "user:" + strconv.Itoa(id)
+}
+
+func chirpTarget(id int) string {
+	return "chirp:" + strconv.Itoa(id)
+}
+
+// recordAudit adds event to the audit log, filling in the caller's IP and
+// user agent from r, and the actor from the request context unless one is
+// set. r is nil for actions the server takes by itself. Failing to record
+// is logged rather than failing the action.
+func (cfg *apiConfig) recordAudit(r *http.Request, event audit.Event) {
+	if r != nil {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		event.IP = host
+		event.UserAgent = r.UserAgent()
+		if event.ActorID == 0 {
+			event.ActorID = userIDFromContext(r.Context())
+		}
+	}
+	_, err := cfg.auditLog.Record(event)
+	if err != nil {
+		log.Printf("Couldn't record audit event %s: %v", event.Action, err)
+	}
+}
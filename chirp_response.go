@@ -0,0 +1,152 @@
+package main
+
+import (
+	"internal/database"
+	"time"
+)
+
+// chirpResponse is a chirp as seen by a particular viewer. The viewer-only
+// fields are left out of anonymous responses. Quote and Poll replace the
+// stored quote and poll, so neither shows the viewer more than they may
+// see.
+type chirpResponse struct {
+	database.Chirp
+	Author         *userSummary    `json:"author,omitempty"`
+	Quote          *quoteResponse  `json:"quote,omitempty"`
+	Poll           *pollResponse   `json:"poll,omitempty"`
+	Attachments    []mediaResponse `json:"attachments,omitempty"`
+	Pinned         bool            `json:"pinned,omitempty"`
+	LikedByMe      *bool           `json:"liked_by_me,omitempty"`
+	RechirpedByMe  *bool           `json:"rechirped_by_me,omitempty"`
+	BookmarkedByMe *bool           `json:"bookmarked_by_me,omitempty"`
+}
+
+// quoteResponse is the snapshot of a quoted chirp. Once the quoted chirp
+// is deleted, or if the viewer can't see it, only its ID is left and
+// Unavailable is set.
+type quoteResponse struct {
+	ID          int          `json:"id"`
+	Author      *userSummary `json:"author,omitempty"`
+	Body        string       `json:"body,omitempty"`
+	CreatedAt   *time.Time   `json:"created_at,omitempty"`
+	Unavailable bool         `json:"unavailable,omitempty"`
+}
+
+func (cfg *apiConfig) presentChirp(chirp database.Chirp, viewerID int) (chirpResponse, error) {
+	responses, err := cfg.presentChirps([]database.Chirp{chirp}, viewerID)
+	if err != nil {
+		return chirpResponse{}, err
+	}
+	return responses[0], nil
+}
+
+func (cfg *apiConfig) presentChirps(chirps []database.Chirp, viewerID int) ([]chirpResponse, error) {
+	chirpIDs := make([]int, 0, len(chirps))
+	authorIDs := make([]int, 0, len(chirps))
+	mediaIDs := make([]int, 0)
+	pollIDs := make([]int, 0)
+	quotedIDs := make([]int, 0)
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+		authorIDs = append(authorIDs, chirp.AuthorId)
+		mediaIDs = append(mediaIDs, chirp.MediaIDs...)
+		if chirp.Poll != nil {
+			pollIDs = append(pollIDs, chirp.ID)
+		}
+		if chirp.QuoteOf != 0 {
+			quotedIDs = append(quotedIDs, chirp.QuoteOf)
+			if chirp.Quote != nil {
+				authorIDs = append(authorIDs, chirp.Quote.AuthorID)
+			}
+		}
+	}
+	authors, err := cfg.database.GetUsers(authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	media, err := cfg.database.GetMedia(mediaIDs)
+	if err != nil {
+		return nil, err
+	}
+	quotable := make(map[int]bool)
+	if len(quotedIDs) > 0 {
+		quotable, err = cfg.database.GetVisibleChirps(quotedIDs, viewerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	votes := make(map[int]database.PollVote)
+	if viewerID != 0 && len(pollIDs) > 0 {
+		votes, err = cfg.database.GetPollVotes(pollIDs, viewerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	responses := make([]chirpResponse, 0, len(chirps))
+	for _, chirp := range chirps {
+		response := chirpResponse{Chirp: chirp}
+		if author, ok := authors[chirp.AuthorId]; ok {
+			summary := newUserSummary(author)
+			response.Author = &summary
+		}
+		for _, mediaID := range chirp.MediaIDs {
+			if m, ok := media[mediaID]; ok {
+				response.Attachments = append(response.Attachments, newMediaResponse(m))
+			}
+		}
+		if chirp.QuoteOf != 0 {
+			response.Quote = newQuoteResponse(chirp, quotable[chirp.QuoteOf], authors)
+		}
+		if chirp.Poll != nil {
+			var vote *database.PollVote
+			if v, ok := votes[chirp.ID]; ok {
+				vote = &v
+			}
+			response.Poll = newPollResponse(*chirp.Poll, vote, now)
+		}
+		responses = append(responses, response)
+	}
+	if viewerID == 0 {
+		return responses, nil
+	}
+
+	liked, err := cfg.database.GetReactedChirps(chirpIDs, viewerID, database.ReactionLike)
+	if err != nil {
+		return nil, err
+	}
+	rechirped, err := cfg.database.GetReactedChirps(chirpIDs, viewerID, database.ReactionRechirp)
+	if err != nil {
+		return nil, err
+	}
+	bookmarked, err := cfg.database.GetBookmarkedChirps(chirpIDs, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range responses {
+		likedByMe := liked[responses[i].ID]
+		rechirpedByMe := rechirped[responses[i].ID]
+		bookmarkedByMe := bookmarked[responses[i].ID]
+		responses[i].LikedByMe = &likedByMe
+		responses[i].RechirpedByMe = &rechirpedByMe
+		responses[i].BookmarkedByMe = &bookmarkedByMe
+	}
+	return responses, nil
+}
+
+func newQuoteResponse(chirp database.Chirp, visible bool, authors map[int]database.User) *quoteResponse {
+	response := &quoteResponse{ID: chirp.QuoteOf}
+	if !visible || chirp.Quote == nil {
+		response.Unavailable = true
+		return response
+	}
+	if author, ok := authors[chirp.Quote.AuthorID]; ok {
+		summary := newUserSummary(author)
+		response.Author = &summary
+	}
+	response.Body = chirp.Quote.Body
+	createdAt := chirp.Quote.CreatedAt
+	response.CreatedAt = &createdAt
+	return response
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"internal/audit"
+	"internal/auth"
+	"internal/database"
+	"io"
+)
+
+// createAdmin implements the create-admin command, which bootstraps the
+// first admin: it grants the admin role to the user with -email, creating
+// them with -password if they don't exist yet. The grant is recorded in
+// auditLog with no actor.
+func createAdmin(db *database.DB, auditLog *audit.Log, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	email := flags.String("email", "", "Email of the user to make an admin")
+	password := flags.String("password", "", "Password for the user, if they don't exist yet")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("create-admin: -email is required")
+	}
+
+	user, err := db.GetUserByEmail(*email)
+	if errors.Is(err, database.ErrNotExist) {
+		if *password == "" {
+			return fmt.Errorf("create-admin: no user with email %s, pass -password to create one", *email)
+		}
+		var hash string
+		hash, err = auth.HashPassword(*password)
+		if err != nil {
+			return err
+		}
+		user, err = db.CreateUser(*email, hash)
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err = db.GrantRole(user.ID, database.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	_, err = auditLog.Record(audit.Event{
+		Action:  auditGrantRole,
+		Target:  userTarget(user.ID),
+		Outcome: audit.OutcomeSuccess,
+		Details: map[string]string{"role": string(database.RoleAdmin), "source": "cli"},
+	})
+	if err != nil {
+		return fmt.Errorf("create-admin: couldn't record audit event: %w", err)
+	}
+	fmt.Fprintf(out, "User %d (%s) is now an admin\n", user.ID, user.Email)
+	return nil
+}
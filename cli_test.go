@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"internal/audit"
+	"internal/database"
+	"testing"
+)
+
+func TestCreateAdmin(t *testing.T) {
+	cfg := newTestConfig(t)
+	out := bytes.Buffer{}
+	err := createAdmin(cfg.database, cfg.auditLog, []string{"-email", "admin@example.com", "-password", "password"}, &out)
+	if err != nil {
+		t.Fatalf("Couldn't create admin: %v", err)
+	}
+	user, err := cfg.database.GetUserByEmail("admin@example.com")
+	if err != nil {
+		t.Fatalf("Couldn't get admin: %v", err)
+	}
+	if !user.HasRole(database.RoleAdmin) {
+		t.Errorf("Expected the user to be an admin, got %+v", user.Roles)
+	}
+
+	page, err := cfg.auditLog.Query(audit.Filter{Action: auditGrantRole}, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't query audit log: %v", err)
+	}
+	if len(page.Events) != 1 || page.Events[0].Target != userTarget(user.ID) || page.Events[0].Details["role"] != "admin" {
+		t.Errorf("Expected the grant to be audited, got %+v", page.Events)
+	}
+
+	if err := createAdmin(cfg.database, cfg.auditLog, []string{"-email", "nobody@example.com"}, &out); err == nil {
+		t.Error("Expected an unknown email without a password to fail")
+	}
+}
@@ -6,11 +6,25 @@ require (
 	github.com/golang-jwt/jwt/v5 v5.0.0
 	github.com/joho/godotenv v1.5.1
 	golang.org/x/crypto v0.13.0
+	golang.org/x/text v0.13.0
 	github.com/go-chi/chi/v5 v5.0.10
+	github.com/gorilla/websocket v1.5.0
 	internal/database v1.0.0
 	internal/auth v1.0.0
+	internal/storage v1.0.0
+	internal/moderation v1.0.0
+	internal/stream v1.0.0
+	internal/audit v1.0.0
 )
 
 replace internal/database => ./internal/database
 
 replace internal/auth => ./internal/auth
+
+replace internal/storage => ./internal/storage
+
+replace internal/stream => ./internal/stream
+
+replace internal/moderation => ./internal/moderation
+
+replace internal/audit => ./internal/audit
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"internal/audit"
+	"internal/auth"
+	"internal/database"
+	"internal/moderation"
+	"internal/stream"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "database.json"))
+	if err != nil {
+		t.Fatalf("Couldn't create database: %v", err)
+	}
+	moderator, err := moderation.NewPipeline("")
+	if err != nil {
+		t.Fatalf("Couldn't create moderation pipeline: %v", err)
+	}
+	auditLog, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("Couldn't open audit log: %v", err)
+	}
+	cfg := &apiConfig{
+		database:  db,
+		hub:       stream.NewHub(streamBufferSize, streamSubscriberBuffer),
+		moderator: moderator,
+		auditLog:  auditLog,
+		jwtSecret: "secret",
+	}
+	t.Cleanup(cfg.hub.Close)
+	t.Cleanup(func() { auditLog.Close() })
+	return cfg
+}
+
+// newTestUser creates a user with roles and returns an access token for
+// them.
+func newTestUser(t *testing.T, cfg *apiConfig, email string, roles ...database.Role) string {
+	t.Helper()
+	user, err := cfg.database.CreateUser(email, "password")
+	if err != nil {
+		t.Fatalf("Couldn't create user: %v", err)
+	}
+	for _, role := range roles {
+		if user, err = cfg.database.GrantRole(user.ID, role); err != nil {
+			t.Fatalf("Couldn't grant role: %v", err)
+		}
+	}
+	token, _, err := auth.GenerateJWTTokens(user.ID, roleNames(user.Roles), cfg.jwtSecret)
+	if err != nil {
+		t.Fatalf("Couldn't generate token: %v", err)
+	}
+	return token
+}
+
+// testServer serves a test config's router over HTTP.
+type testServer struct {
+	*httptest.Server
+	t *testing.T
+}
+
+// newTestServer starts a server for cfg that is closed when the test ends.
+func newTestServer(t *testing.T, cfg *apiConfig) *testServer {
+	t.Helper()
+	server := httptest.NewServer(cfg.router(t.TempDir()))
+	t.Cleanup(server.Close)
+	return &testServer{Server: server, t: t}
+}
+
+// send sends a request to path, authorized with token unless it is empty.
+// Requests are cancelled and response bodies closed when the test ends, so
+// open streams don't keep the server from closing.
+func (s *testServer) send(method, path, token, body string) *http.Response {
+	s.t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		s.t.Fatalf("Couldn't create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "chirpy-test")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatalf("Couldn't send request: %v", err)
+	}
+	s.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
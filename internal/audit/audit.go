@@ -0,0 +1,333 @@
+// Package audit keeps an append-only log of security-relevant actions.
+// Every event carries the hash of the one before it, so editing, removing
+// or reordering events breaks the chain and shows up in Verify.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 100
+)
+
+// maxEventSize bounds a single line of the log file.
+const maxEventSize = 1 << 20
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Outcome says how an audited action ended.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	// OutcomeFailure is an action that failed, such as a wrong password.
+	OutcomeFailure Outcome = "failure"
+	// OutcomeDenied is an action the actor wasn't allowed to take.
+	OutcomeDenied Outcome = "denied"
+)
+
+// Event is one audited action. Seq, PrevHash and Hash are set by Record,
+// and so is Time unless the caller sets it.
+type Event struct {
+	Seq       uint64            `json:"seq"`
+	Time      time.Time         `json:"time"`
+	ActorID   int               `json:"actor_id,omitempty"`
+	Action    string            `json:"action"`
+	Target    string            `json:"target,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	Outcome   Outcome           `json:"outcome"`
+	Details   map[string]string `json:"details,omitempty"`
+	PrevHash  string            `json:"prev_hash"`
+	Hash      string            `json:"hash"`
+}
+
+// computeHash hashes every field of the event but Hash itself.
+func (event Event) computeHash() (string, error) {
+	event.Hash = ""
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends events to a file of JSON lines. It is safe for concurrent
+// use.
+type Log struct {
+	mux      sync.Mutex
+	path     string
+	file     *os.File
+	seq      uint64
+	lastHash string
+}
+
+// Open opens the log at path, creating it if needed, and continues the
+// chain from its last readable event. Lines that can't be read are left
+// for Verify to report rather than stopping the log from opening.
+func Open(path string) (*Log, error) {
+	log := &Log{path: path}
+	err := log.scan(func(line int, event Event, err error) error {
+		if err == nil {
+			log.seq = event.Seq
+			log.lastHash = event.Hash
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	log.file, err = os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	// A crash while writing can leave a partial last line. Ending it keeps
+	// new events on lines of their own.
+	info, err := log.file.Stat()
+	if err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		_, err = log.file.ReadAt(last, info.Size()-1)
+		if err == nil && last[0] != '\n' {
+			_, err = log.file.Write([]byte{'\n'})
+		}
+	}
+	if err != nil {
+		log.file.Close()
+		return nil, err
+	}
+	return log, nil
+}
+
+func (log *Log) Close() error {
+	log.mux.Lock()
+	defer log.mux.Unlock()
+	return log.file.Close()
+}
+
+// Record appends event to the log and returns it as stored. The event is
+// synced to disk before Record returns.
+func (log *Log) Record(event Event) (Event, error) {
+	log.mux.Lock()
+	defer log.mux.Unlock()
+
+	event.Seq = log.seq + 1
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+	event.PrevHash = log.lastHash
+	hash, err := event.computeHash()
+	if err != nil {
+		return Event{}, err
+	}
+	event.Hash = hash
+	data, err := json.Marshal(event)
+	if err != nil {
+		return Event{}, err
+	}
+	_, err = log.file.Write(append(data, '\n'))
+	if err != nil {
+		return Event{}, err
+	}
+	err = log.file.Sync()
+	if err != nil {
+		return Event{}, err
+	}
+	log.seq = event.Seq
+	log.lastHash = event.Hash
+	return event, nil
+}
+
+// scan calls fn with every event in the file, in order, along with its
+// line number and any error decoding it.
+func (log *Log) scan(fn func(line int, event Event, err error) error) error {
+	file, err := os.Open(log.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		event := Event{}
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		err = fn(line, event, err)
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Filter selects events. Zero fields match every event.
+type Filter struct {
+	ActorID int
+	Action  string
+	Target  string
+	Outcome Outcome
+	Since   time.Time
+	Until   time.Time
+}
+
+func (f Filter) matches(event Event) bool {
+	switch {
+	case f.ActorID != 0 && event.ActorID != f.ActorID:
+		return false
+	case f.Action != "" && event.Action != f.Action:
+		return false
+	case f.Target != "" && event.Target != f.Target:
+		return false
+	case f.Outcome != "" && event.Outcome != f.Outcome:
+		return false
+	case !f.Since.IsZero() && event.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !event.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+type Page struct {
+	Events     []Event
+	NextCursor string
+}
+
+// Query returns a page of the events matching filter, newest first.
+func (log *Log) Query(filter Filter, cursor string, limit int) (Page, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+	var before uint64
+	if cursor != "" {
+		var err error
+		before, err = decodeCursor(cursor)
+		if err != nil {
+			return Page{}, err
+		}
+	}
+
+	log.mux.Lock()
+	defer log.mux.Unlock()
+	matches := make([]Event, 0)
+	err := log.scan(func(line int, event Event, err error) error {
+		if err != nil || cursor != "" && event.Seq >= before {
+			return nil
+		}
+		if filter.matches(event) {
+			matches = append(matches, event)
+		}
+		return nil
+	})
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Events: make([]Event, 0, min(limit, len(matches)))}
+	for i := len(matches) - 1; i >= 0; i-- {
+		if len(page.Events) == limit {
+			page.NextCursor = encodeCursor(page.Events[limit-1].Seq)
+			break
+		}
+		page.Events = append(page.Events, matches[i])
+	}
+	return page, nil
+}
+
+func encodeCursor(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("audit:%d", seq)))
+}
+
+func decodeCursor(cursor string) (uint64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	var seq uint64
+	_, err = fmt.Sscanf(string(data), "audit:%d", &seq)
+	if err != nil || seq == 0 {
+		return 0, ErrInvalidCursor
+	}
+	return seq, nil
+}
+
+// Verification is the result of checking the hash chain.
+type Verification struct {
+	Valid  bool   `json:"valid"`
+	Events uint64 `json:"events"`
+	// HeadHash is the hash of the last event. Keeping a copy elsewhere
+	// lets you tell if events were later cut from the end of the log.
+	HeadHash string `json:"head_hash"`
+	// BrokenAt is the line of the first event that doesn't fit the chain.
+	BrokenAt int    `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+var errChainBroken = errors.New("chain broken")
+
+// Verify checks that every event is numbered in order, points at the hash
+// of the event before it and still matches its own hash, and that no
+// events recorded by this Log are missing from the end of the file.
+func (log *Log) Verify() (Verification, error) {
+	log.mux.Lock()
+	defer log.mux.Unlock()
+
+	result := Verification{Valid: true}
+	broken := func(line int, reason string) error {
+		result.Valid = false
+		result.BrokenAt = line
+		result.Reason = reason
+		return errChainBroken
+	}
+	err := log.scan(func(line int, event Event, err error) error {
+		if err != nil {
+			return broken(line, "event can't be read")
+		}
+		if event.Seq != result.Events+1 {
+			return broken(line, fmt.Sprintf("expected event %d, found %d", result.Events+1, event.Seq))
+		}
+		if event.PrevHash != result.HeadHash {
+			return broken(line, "previous hash doesn't match")
+		}
+		hash, err := event.computeHash()
+		if err != nil {
+			return err
+		}
+		if event.Hash != hash {
+			return broken(line, "hash doesn't match the event")
+		}
+		result.Events = event.Seq
+		result.HeadHash = event.Hash
+		return nil
+	})
+	if errors.Is(err, errChainBroken) {
+		return result, nil
+	}
+	if err != nil {
+		return Verification{}, err
+	}
+	if result.Events < log.seq {
+		return Verification{
+			Events:   result.Events,
+			HeadHash: result.HeadHash,
+			Reason:   fmt.Sprintf("expected %d events, found %d", log.seq, result.Events),
+		}, nil
+	}
+	return result, nil
+}
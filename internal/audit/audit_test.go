@@ -0,0 +1,204 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestLog(t *testing.T) (*Log, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("Couldn't open log: %v", err)
+	}
+	t.Cleanup(func() { log.Close() })
+	return log, path
+}
+
+func record(t *testing.T, log *Log, events ...Event) {
+	t.Helper()
+	for _, event := range events {
+		if _, err := log.Record(event); err != nil {
+			t.Fatalf("Couldn't record event: %v", err)
+		}
+	}
+}
+
+func TestRecordChainsEvents(t *testing.T) {
+	log, path := newTestLog(t)
+	first, err := log.Record(Event{ActorID: 1, Action: "auth.login", Outcome: OutcomeSuccess})
+	if err != nil {
+		t.Fatalf("Couldn't record event: %v", err)
+	}
+	if first.Seq != 1 || first.PrevHash != "" || first.Hash == "" {
+		t.Errorf("Expected the first event to start the chain, got %+v", first)
+	}
+	log.Close()
+
+	log, err = Open(path)
+	if err != nil {
+		t.Fatalf("Couldn't reopen log: %v", err)
+	}
+	defer log.Close()
+	second, err := log.Record(Event{ActorID: 1, Action: "auth.revoke", Outcome: OutcomeSuccess})
+	if err != nil {
+		t.Fatalf("Couldn't record event: %v", err)
+	}
+	if second.Seq != 2 || second.PrevHash != first.Hash {
+		t.Errorf("Expected the reopened log to continue the chain, got %+v", second)
+	}
+	verification, err := log.Verify()
+	if err != nil {
+		t.Fatalf("Couldn't verify log: %v", err)
+	}
+	if !verification.Valid || verification.Events != 2 || verification.HeadHash != second.Hash {
+		t.Errorf("Expected a valid chain of 2 events, got %+v", verification)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	log, _ := newTestLog(t)
+	record(t, log,
+		Event{ActorID: 1, Action: "auth.login", Outcome: OutcomeSuccess},
+		Event{Action: "auth.login", Outcome: OutcomeFailure, Details: map[string]string{"email": "who@example.com"}},
+		Event{ActorID: 1, Action: "chirp.delete", Target: "chirp:3", Outcome: OutcomeSuccess},
+		Event{ActorID: 2, Action: "auth.login", Outcome: OutcomeSuccess},
+	)
+
+	page, err := log.Query(Filter{Action: "auth.login", Outcome: OutcomeSuccess}, "", 1)
+	if err != nil {
+		t.Fatalf("Couldn't query log: %v", err)
+	}
+	if len(page.Events) != 1 || page.Events[0].Seq != 4 || page.NextCursor == "" {
+		t.Fatalf("Expected the newest login and a cursor, got %+v", page)
+	}
+	page, err = log.Query(Filter{Action: "auth.login", Outcome: OutcomeSuccess}, page.NextCursor, 1)
+	if err != nil {
+		t.Fatalf("Couldn't query log: %v", err)
+	}
+	if len(page.Events) != 1 || page.Events[0].Seq != 1 || page.NextCursor != "" {
+		t.Errorf("Expected the oldest login on the last page, got %+v", page)
+	}
+
+	page, err = log.Query(Filter{ActorID: 1, Target: "chirp:3"}, "", 0)
+	if err != nil || len(page.Events) != 1 || page.Events[0].Action != "chirp.delete" {
+		t.Errorf("Expected the chirp deletion, got %+v %v", page, err)
+	}
+	if _, err := log.Query(Filter{}, "nope", 0); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines [][]byte) [][]byte
+		line   int
+	}{
+		{"edited", func(lines [][]byte) [][]byte {
+			lines[1] = bytes.Replace(lines[1], []byte(`"failure"`), []byte(`"success"`), 1)
+			return lines
+		}, 2},
+		{"removed", func(lines [][]byte) [][]byte {
+			return append(lines[:1], lines[2:]...)
+		}, 2},
+		{"reordered", func(lines [][]byte) [][]byte {
+			lines[0], lines[1] = lines[1], lines[0]
+			return lines
+		}, 1},
+		{"garbled", func(lines [][]byte) [][]byte {
+			lines[2] = []byte("{")
+			return lines
+		}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, path := newTestLog(t)
+			record(t, log,
+				Event{ActorID: 1, Action: "auth.login", Outcome: OutcomeSuccess},
+				Event{ActorID: 1, Action: "auth.login", Outcome: OutcomeFailure},
+				Event{ActorID: 1, Action: "auth.revoke", Outcome: OutcomeSuccess},
+			)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Couldn't read log: %v", err)
+			}
+			lines := tt.tamper(bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")))
+			err = os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0600)
+			if err != nil {
+				t.Fatalf("Couldn't write log: %v", err)
+			}
+
+			verification, err := log.Verify()
+			if err != nil {
+				t.Fatalf("Couldn't verify log: %v", err)
+			}
+			if verification.Valid || verification.BrokenAt != tt.line {
+				t.Errorf("Expected the chain to break at line %d, got %+v", tt.line, verification)
+			}
+		})
+	}
+}
+
+func TestVerifyDetectsTruncation(t *testing.T) {
+	log, path := newTestLog(t)
+	record(t, log,
+		Event{ActorID: 1, Action: "auth.login", Outcome: OutcomeSuccess},
+		Event{ActorID: 1, Action: "auth.revoke", Outcome: OutcomeSuccess},
+	)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Couldn't read log: %v", err)
+	}
+	err = os.WriteFile(path, data[:bytes.IndexByte(data, '\n')+1], 0600)
+	if err != nil {
+		t.Fatalf("Couldn't write log: %v", err)
+	}
+	verification, err := log.Verify()
+	if err != nil {
+		t.Fatalf("Couldn't verify log: %v", err)
+	}
+	if verification.Valid || verification.Events != 1 {
+		t.Errorf("Expected the missing event to be reported, got %+v", verification)
+	}
+}
+
+func TestOpenEndsPartialLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("Couldn't open log: %v", err)
+	}
+	record(t, log, Event{ActorID: 1, Action: "auth.login", Outcome: OutcomeSuccess})
+	log.Close()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("Couldn't open log file: %v", err)
+	}
+	file.Write([]byte(`{"seq":2,"act`))
+	file.Close()
+
+	log, err = Open(path)
+	if err != nil {
+		t.Fatalf("Couldn't reopen log: %v", err)
+	}
+	defer log.Close()
+	event, err := log.Record(Event{ActorID: 1, Action: "auth.revoke", Outcome: OutcomeSuccess})
+	if err != nil {
+		t.Fatalf("Couldn't record event: %v", err)
+	}
+	if event.Seq != 2 {
+		t.Errorf("Expected the chain to continue from the last complete event, got %d", event.Seq)
+	}
+	page, err := log.Query(Filter{}, "", 0)
+	if err != nil || len(page.Events) != 2 {
+		t.Errorf("Expected both complete events to be readable, got %+v %v", page, err)
+	}
+	verification, err := log.Verify()
+	if err != nil || verification.Valid || verification.BrokenAt != 2 {
+		t.Errorf("Expected the partial line to be reported, got %+v %v", verification, err)
+	}
+}
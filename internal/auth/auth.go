@@ -23,39 +23,52 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
+// CustomClaims adds the user's roles to access tokens, so clients can tell
+// what the user may do. Refresh tokens carry no roles.
 type CustomClaims struct {
 	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
 }
 
-func generateJWTToken(userID int, issuer, jwtSecret string, expiration time.Duration) (string, error) {
+func generateJWTToken(userID int, roles []string, issuer, jwtSecret string, expiration time.Duration) (string, error) {
 	now := time.Now()
 	expiresDate := now.Add(expiration)
 
-	claims := jwt.RegisteredClaims{
-		Issuer:    issuer,
-		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(expiresDate),
-		Subject:   strconv.Itoa(userID),
+	claims := CustomClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresDate),
+			Subject:   strconv.Itoa(userID),
+		},
+		Roles: roles,
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims)
 	return token.SignedString([]byte(jwtSecret))
 }
 
-func GenerateJWTTokens(userID int, jwtSecret string) (string, string, error) {
-	accessToken, err := generateJWTToken(userID, "chirpy-access", jwtSecret, time.Hour)
+func GenerateJWTTokens(userID int, roles []string, jwtSecret string) (string, string, error) {
+	accessToken, err := generateJWTToken(userID, roles, "chirpy-access", jwtSecret, time.Hour)
 	if err != nil {
 		return "", "", err
 	}
-	refreshToken, err := generateJWTToken(userID, "chirpy-refresh", jwtSecret, time.Hour*24*60)
+	refreshToken, err := generateJWTToken(userID, nil, "chirpy-refresh", jwtSecret, time.Hour*24*60)
 	if err != nil {
 		return "", "", err
 	}
 	return accessToken, refreshToken, nil
 }
 
+// GenerateStreamTicket issues a short-lived token for opening an event
+// stream. Browsers can only send it in the URL, where it may end up in logs,
+// so it expires long before an access token would.
+func GenerateStreamTicket(userID int, jwtSecret string, expiration time.Duration) (string, error) {
+	return generateJWTToken(userID, nil, "chirpy-stream", jwtSecret, expiration)
+}
+
 func ValidateJWTToken(authHeader, jwtSecret string) (*jwt.Token, error) {
 	tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-	claims := jwt.RegisteredClaims{}
+	claims := CustomClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
 		return []byte(jwtSecret), nil
 	})
@@ -77,13 +90,26 @@ func GetUserFromTokenClaims(token *jwt.Token) (int, error) {
 	return userId, nil
 }
 
-func GenerateAccessTokenFromRefresh(refreshToken *jwt.Token, jwtSecret string) (string, error) {
+// GetRolesFromTokenClaims returns the roles in a token validated by
+// ValidateJWTToken.
+func GetRolesFromTokenClaims(token *jwt.Token) []string {
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok {
+		return nil
+	}
+	return claims.Roles
+}
+
+// GenerateAccessTokenFromRefresh issues a new access token for the user of
+// refreshToken. roles should be the user's current roles, since they may
+// have changed since the refresh token was issued.
+func GenerateAccessTokenFromRefresh(refreshToken *jwt.Token, roles []string, jwtSecret string) (string, error) {
 	userID, err := GetUserFromTokenClaims(refreshToken)
 	if err != nil {
 		return "", err
 	}
 
-	token, err := generateJWTToken(userID, "chirpy-access", jwtSecret, time.Hour)
+	token, err := generateJWTToken(userID, roles, "chirpy-access", jwtSecret, time.Hour)
 	if err != nil {
 		return "", err
 	}
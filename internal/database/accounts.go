@@ -0,0 +1,373 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DeletionPolicy says what happens to a deleted user's chirps when the
+// account is purged.
+type DeletionPolicy string
+
+const (
+	// DeletionAnonymize keeps the chirps but removes their author, so
+	// conversations the user took part in still make sense.
+	DeletionAnonymize DeletionPolicy = "anonymize"
+	// DeletionDelete deletes the chirps like their author would.
+	DeletionDelete DeletionPolicy = "delete"
+)
+
+func (p DeletionPolicy) Valid() bool {
+	return p == DeletionAnonymize || p == DeletionDelete
+}
+
+var ErrDeletionPending = errors.New("account deletion pending")
+
+// PendingDeletion reports whether the user asked for their account to be
+// deleted and it hasn't been purged or cancelled yet.
+func (user User) PendingDeletion() bool {
+	return user.DeletionScheduledAt != nil
+}
+
+// TokenValid reports whether a token issued at issuedAt may still be used.
+// Tokens carry whole seconds, so only tokens from earlier seconds than
+// TokensValidAfter are rejected.
+func (user User) TokenValid(issuedAt time.Time) bool {
+	return user.TokensValidAfter == nil || !issuedAt.Before(*user.TokensValidAfter)
+}
+
+// RequestDeletion schedules the user's account to be purged once
+// coolingOff has passed, and revokes every token issued so far. Until the
+// purge the account is hidden as if it were gone.
+func (db *DB) RequestDeletion(id int, coolingOff time.Duration) (User, error) {
+	var user User
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.Users[id]
+		if !ok {
+			return ErrNotExist
+		}
+		if user.PendingDeletion() {
+			return ErrDeletionPending
+		}
+		now := time.Now().UTC()
+		purgeAt := now.Add(coolingOff)
+		validAfter := now.Truncate(time.Second)
+		user.DeletionScheduledAt = &purgeAt
+		user.TokensValidAfter = &validAfter
+		user.UpdatedAt = now
+		dbStructure.Users[id] = user
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// CancelDeletion keeps an account that is pending deletion. Tokens revoked
+// by RequestDeletion stay revoked.
+func (db *DB) CancelDeletion(id int) (User, error) {
+	var user User
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.Users[id]
+		if !ok {
+			return ErrNotExist
+		}
+		if !user.PendingDeletion() {
+			return nil
+		}
+		user.DeletionScheduledAt = nil
+		user.UpdatedAt = time.Now().UTC()
+		dbStructure.Users[id] = user
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// PurgeResult describes what PurgeDeletedUsers removed.
+type PurgeResult struct {
+	UserIDs []int
+	// DeletedChirps are the published chirps deleted under DeletionDelete.
+	DeletedChirps []Chirp
+	// OrphanedKeys are blob keys no media refers to anymore, which can be
+	// removed from the blob store.
+	OrphanedKeys []string
+}
+
+// PurgeDeletedUsers removes every user whose deletion was scheduled before
+// now, applying policy to their chirps. Their reactions, follows, blocks,
+// mutes, notifications, drafts and messages go with them. Reports and the
+// moderation log keep their ID, since they are the record of moderation
+// decisions.
+func (db *DB) PurgeDeletedUsers(now time.Time, policy DeletionPolicy) (PurgeResult, error) {
+	result := PurgeResult{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		for id, user := range dbStructure.Users {
+			if user.PendingDeletion() && !now.Before(*user.DeletionScheduledAt) {
+				result.UserIDs = append(result.UserIDs, id)
+			}
+		}
+		if len(result.UserIDs) == 0 {
+			return nil
+		}
+		keys := make(map[string]bool)
+		for _, id := range result.UserIDs {
+			dbStructure.purgeUser(id, policy, &result, keys)
+		}
+		for _, media := range dbStructure.Media {
+			delete(keys, media.Key)
+		}
+		for _, user := range dbStructure.Users {
+			delete(keys, user.Avatar)
+		}
+		for key := range keys {
+			result.OrphanedKeys = append(result.OrphanedKeys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return PurgeResult{}, err
+	}
+	return result, nil
+}
+
+// purgeUser removes user id and everything tied to them. The keys of media
+// records it deletes are added to keys.
+func (dbStructure *DBStructure) purgeUser(id int, policy DeletionPolicy, result *PurgeResult, keys map[string]bool) {
+	kept := make(map[int]bool)
+	for _, chirpID := range append([]int(nil), dbStructure.chirpsByAuthor[id]...) {
+		chirp := dbStructure.Chirps[chirpID]
+		if policy == DeletionAnonymize && chirp.Hold == nil {
+			dbStructure.unindexChirp(chirp)
+			chirp.AuthorId = 0
+			dbStructure.indexChirp(chirp)
+			dbStructure.Chirps[chirpID] = chirp
+			if chirp.QuoteCount > 0 {
+				dbStructure.updateQuotes(chirpID, func(quote *ChirpQuote) *ChirpQuote {
+					quote.AuthorID = 0
+					return quote
+				})
+			}
+			for _, mediaID := range chirp.MediaIDs {
+				kept[mediaID] = true
+			}
+			continue
+		}
+		dbStructure.deleteChirp(chirpID)
+		if chirp.Hold == nil {
+			result.DeletedChirps = append(result.DeletedChirps, chirp)
+		}
+	}
+	for draftID, draft := range dbStructure.Drafts {
+		if draft.AuthorID == id {
+			delete(dbStructure.Drafts, draftID)
+		}
+	}
+	for mediaID, media := range dbStructure.Media {
+		if media.OwnerID != id {
+			continue
+		}
+		if kept[mediaID] {
+			media.OwnerID = 0
+			dbStructure.Media[mediaID] = media
+			continue
+		}
+		keys[media.Key] = true
+		delete(dbStructure.Media, mediaID)
+	}
+
+	for _, collection := range []string{"likes", "rechirps"} {
+		for chirpID, users := range dbStructure.userEntries(collection) {
+			if _, ok := users[id]; !ok {
+				continue
+			}
+			dbStructure.deleteUserEntry(collection, chirpID, id)
+			chirp, ok := dbStructure.Chirps[chirpID]
+			if !ok {
+				continue
+			}
+			chirp.LikeCount = len(dbStructure.Likes[chirpID])
+			chirp.RechirpCount = len(dbStructure.Rechirps[chirpID])
+			dbStructure.Chirps[chirpID] = chirp
+		}
+	}
+
+	for chirpID := range dbStructure.PollVotes {
+		dbStructure.removeVote(chirpID, id)
+	}
+	for chirpID := range dbStructure.Bookmarks[id] {
+		dbStructure.removeBookmark(id, chirpID)
+	}
+	for listID, list := range dbStructure.Lists {
+		if list.OwnerID == id {
+			delete(dbStructure.Lists, listID)
+			dbStructure.deleteUserEntries("list_members", listID)
+			continue
+		}
+		dbStructure.removeListMember(list, id)
+	}
+
+	for followeeID := range dbStructure.Following[id] {
+		dbStructure.removeFollow(id, followeeID)
+	}
+	for followerID := range dbStructure.Followers[id] {
+		dbStructure.removeFollow(followerID, id)
+	}
+	for _, collection := range []string{"blocks", "mutes"} {
+		dbStructure.deleteUserEntries(collection, id)
+		for otherID := range dbStructure.userEntries(collection) {
+			dbStructure.deleteUserEntry(collection, otherID, id)
+		}
+	}
+
+	for notificationID, notification := range dbStructure.Notifications {
+		if notification.UserID == id || notification.ActorID == id {
+			delete(dbStructure.Notifications, notificationID)
+			dbStructure.unindexNotification(notification)
+		}
+	}
+	delete(dbStructure.NotificationPreferences, id)
+
+	for _, conversationID := range append([]int(nil), dbStructure.conversationsByUser[id]...) {
+		dbStructure.leaveConversation(conversationID, id)
+	}
+
+	user := dbStructure.Users[id]
+	delete(dbStructure.usersByUsername, strings.ToLower(user.Username))
+	dbStructure.userSearch.remove(id)
+	delete(dbStructure.Users, id)
+}
+
+// leaveConversation deletes userID's messages from a conversation and takes
+// them out of it. Conversations left with a single participant are deleted.
+func (dbStructure *DBStructure) leaveConversation(conversationID, userID int) {
+	conversation := dbStructure.Conversations[conversationID]
+	for _, messageID := range append([]int(nil), dbStructure.messagesByConversation[conversationID]...) {
+		if dbStructure.Messages[messageID].SenderID == userID {
+			delete(dbStructure.Messages, messageID)
+			removeFromIndex(dbStructure.messagesByConversation, conversationID, messageID)
+		}
+	}
+	removeFromIndex(dbStructure.conversationsByUser, userID, conversationID)
+
+	participants := make([]int, 0, len(conversation.ParticipantIDs))
+	for _, participantID := range conversation.ParticipantIDs {
+		if participantID != userID {
+			participants = append(participants, participantID)
+		}
+	}
+	if len(participants) < 2 {
+		for _, participantID := range participants {
+			removeFromIndex(dbStructure.conversationsByUser, participantID, conversationID)
+		}
+		for _, messageID := range dbStructure.messagesByConversation[conversationID] {
+			delete(dbStructure.Messages, messageID)
+		}
+		delete(dbStructure.messagesByConversation, conversationID)
+		delete(dbStructure.Conversations, conversationID)
+		return
+	}
+
+	conversation.ParticipantIDs = participants
+	conversation.ReadReceipts = copyReadReceipts(conversation.ReadReceipts)
+	delete(conversation.ReadReceipts, userID)
+	conversation.LastMessageID = 0
+	if ids := dbStructure.messagesByConversation[conversationID]; len(ids) > 0 {
+		conversation.LastMessageID = ids[len(ids)-1]
+	}
+	dbStructure.Conversations[conversationID] = conversation
+}
+
+// UserExport is everything Chirpy stores about a user that they can take
+// with them.
+type UserExport struct {
+	User      User                    `json:"user"`
+	Chirps    []Chirp                 `json:"chirps"`
+	Revisions map[int][]ChirpRevision `json:"revisions"`
+	Drafts    []Draft                 `json:"drafts"`
+	Likes     []ExportedLike          `json:"likes"`
+	PollVotes []ExportedPollVote      `json:"poll_votes"`
+	Bookmarks []ExportedBookmark      `json:"bookmarks"`
+	Lists     []ExportedList          `json:"lists"`
+	Following []UserEntry             `json:"following"`
+	Followers []UserEntry             `json:"followers"`
+}
+
+type ExportedLike struct {
+	ChirpID   int       `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportedBookmark struct {
+	ChirpID   int       `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportedList struct {
+	List
+	Members []UserEntry `json:"members"`
+}
+
+// ExportUser gathers the user's profile, chirps with their revisions,
+// drafts, likes, poll votes, bookmarks, lists and follows, oldest first. The password hash is left out.
+func (db *DB) ExportUser(id int) (UserExport, error) {
+	export := UserExport{
+		Chirps:    make([]Chirp, 0),
+		Revisions: make(map[int][]ChirpRevision),
+		Drafts:    make([]Draft, 0),
+		Likes:     make([]ExportedLike, 0),
+		Bookmarks: make([]ExportedBookmark, 0),
+		Lists:     make([]ExportedList, 0),
+	}
+	err := db.view(func(dbStructure *DBStructure) error {
+		var ok bool
+		export.User, ok = dbStructure.Users[id]
+		if !ok {
+			return ErrNotExist
+		}
+		export.User.Password = ""
+		for _, chirpID := range dbStructure.chirpsByAuthor[id] {
+			export.Chirps = append(export.Chirps, dbStructure.Chirps[chirpID])
+			if revisions := dbStructure.ChirpRevisions[chirpID]; len(revisions) > 0 {
+				export.Revisions[chirpID] = revisions
+			}
+		}
+		for draftID := 1; draftID <= dbStructure.Sequences["drafts"]; draftID++ {
+			if draft, ok := dbStructure.Drafts[draftID]; ok && draft.AuthorID == id {
+				export.Drafts = append(export.Drafts, draft)
+			}
+		}
+		for chirpID, users := range dbStructure.Likes {
+			if t, ok := users[id]; ok {
+				export.Likes = append(export.Likes, ExportedLike{ChirpID: chirpID, CreatedAt: t})
+			}
+		}
+		sort.Slice(export.Likes, func(i, j int) bool {
+			a, b := export.Likes[i], export.Likes[j]
+			return entryBefore(UserEntry{UserID: a.ChirpID, CreatedAt: a.CreatedAt}, UserEntry{UserID: b.ChirpID, CreatedAt: b.CreatedAt})
+		})
+		export.PollVotes = dbStructure.exportPollVotes(id)
+		for _, entry := range dbStructure.sortedUserEntries("bookmarks", id) {
+			export.Bookmarks = append(export.Bookmarks, ExportedBookmark{ChirpID: entry.UserID, CreatedAt: entry.CreatedAt})
+		}
+		for listID := 1; listID <= dbStructure.Sequences["lists"]; listID++ {
+			if list, ok := dbStructure.Lists[listID]; ok && list.OwnerID == id {
+				export.Lists = append(export.Lists, ExportedList{List: list, Members: dbStructure.sortedUserEntries("list_members", listID)})
+			}
+		}
+		export.Following = dbStructure.sortedUserEntries("following", id)
+		export.Followers = dbStructure.sortedUserEntries("followers", id)
+		return nil
+	})
+	if err != nil {
+		return UserExport{}, err
+	}
+	return export, nil
+}
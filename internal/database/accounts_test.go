@@ -0,0 +1,155 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	for _, policy := range []DeletionPolicy{DeletionAnonymize, DeletionDelete} {
+		t.Run(string(policy), func(t *testing.T) {
+			db := newTestDB(t)
+			for _, email := range []string{"leaver@example.com", "friend@example.com", "other@example.com"} {
+				if _, err := db.CreateUser(email, "password"); err != nil {
+					t.Fatalf("Couldn't create user: %v", err)
+				}
+			}
+			if _, err := db.UpdateProfile(1, Profile{Username: "leaver"}); err != nil {
+				t.Fatalf("Couldn't update profile: %v", err)
+			}
+			attachment, err := db.CreateMedia(Media{OwnerID: 1, Kind: MediaKindAttachment, Key: "attachment"})
+			if err != nil {
+				t.Fatalf("Couldn't create media: %v", err)
+			}
+			chirp, err := db.CreateChirp(Chirp{Body: "goodbye", AuthorId: 1, MediaIDs: []int{attachment.ID}})
+			if err != nil {
+				t.Fatalf("Couldn't create chirp: %v", err)
+			}
+			friendChirp, err := db.CreateChirp(Chirp{Body: "hello @leaver", AuthorId: 2})
+			if err != nil {
+				t.Fatalf("Couldn't create chirp: %v", err)
+			}
+			if _, err := db.AddReaction(friendChirp.ID, 1, ReactionLike); err != nil {
+				t.Fatalf("Couldn't like chirp: %v", err)
+			}
+			if _, err := db.AddReaction(chirp.ID, 2, ReactionLike); err != nil {
+				t.Fatalf("Couldn't like chirp: %v", err)
+			}
+			if err := db.Follow(1, 2); err != nil {
+				t.Fatalf("Couldn't follow: %v", err)
+			}
+			if err := db.Follow(2, 1); err != nil {
+				t.Fatalf("Couldn't follow: %v", err)
+			}
+			if err := db.Block(3, 1); err != nil {
+				t.Fatalf("Couldn't block: %v", err)
+			}
+			conversation, _, err := db.CreateConversation(2, []int{1})
+			if err != nil {
+				t.Fatalf("Couldn't create conversation: %v", err)
+			}
+			if _, _, err := db.SendMessage(conversation.ID, 1, "bye"); err != nil {
+				t.Fatalf("Couldn't send message: %v", err)
+			}
+
+			export, err := db.ExportUser(1)
+			if err != nil {
+				t.Fatalf("Couldn't export user: %v", err)
+			}
+			if export.User.Password != "" || len(export.Chirps) != 1 || len(export.Likes) != 1 || len(export.Following) != 1 || len(export.Followers) != 1 {
+				t.Errorf("Expected the user's data without their password, got %+v", export)
+			}
+
+			user, err := db.RequestDeletion(1, time.Hour)
+			if err != nil {
+				t.Fatalf("Couldn't request deletion: %v", err)
+			}
+			if user.TokenValid(time.Now().Add(-time.Minute)) || !user.TokenValid(time.Now()) {
+				t.Errorf("Expected earlier tokens to be revoked, got %v", user.TokensValidAfter)
+			}
+			if _, err := db.RequestDeletion(1, time.Hour); !errors.Is(err, ErrDeletionPending) {
+				t.Errorf("Expected ErrDeletionPending, got %v", err)
+			}
+			if _, err := db.GetChirpForViewer(chirp.ID, 2); !errors.Is(err, ErrNotExist) {
+				t.Errorf("Expected chirps to be hidden during the cooling-off period, got %v", err)
+			}
+
+			result, err := db.PurgeDeletedUsers(time.Now(), policy)
+			if err != nil {
+				t.Fatalf("Couldn't purge users: %v", err)
+			}
+			if len(result.UserIDs) != 0 {
+				t.Errorf("Expected nothing to be purged during the cooling-off period, got %v", result.UserIDs)
+			}
+			result, err = db.PurgeDeletedUsers(time.Now().Add(time.Hour), policy)
+			if err != nil {
+				t.Fatalf("Couldn't purge users: %v", err)
+			}
+			if len(result.UserIDs) != 1 || result.UserIDs[0] != 1 {
+				t.Fatalf("Expected the user to be purged, got %v", result.UserIDs)
+			}
+
+			if _, err := db.GetUser(1); !errors.Is(err, ErrNotExist) {
+				t.Errorf("Expected the user to be gone, got %v", err)
+			}
+			if _, err := db.GetUserByUsername("leaver"); !errors.Is(err, ErrNotExist) {
+				t.Errorf("Expected the username to be free, got %v", err)
+			}
+			liked, err := db.GetChirpForViewer(friendChirp.ID, 2)
+			if err != nil || liked.LikeCount != 0 {
+				t.Errorf("Expected the like to be gone, got %+v %v", liked, err)
+			}
+			counts, err := db.GetFollowCounts(2)
+			if err != nil || counts.Followers != 0 || counts.Following != 0 {
+				t.Errorf("Expected the follows to be gone, got %+v %v", counts, err)
+			}
+			if _, err := db.GetConversation(conversation.ID, 2); !errors.Is(err, ErrNotExist) {
+				t.Errorf("Expected the conversation to be gone, got %v", err)
+			}
+
+			media, err := db.GetMedia([]int{attachment.ID})
+			if err != nil {
+				t.Fatalf("Couldn't get media: %v", err)
+			}
+			kept, err := db.GetChirpForViewer(chirp.ID, 2)
+			switch policy {
+			case DeletionAnonymize:
+				if err != nil || kept.AuthorId != 0 || kept.Body != "goodbye" {
+					t.Errorf("Expected the chirp without its author, got %+v %v", kept, err)
+				}
+				if len(media) != 1 || len(result.OrphanedKeys) != 0 {
+					t.Errorf("Expected the attachment to be kept, got %v %v", media, result.OrphanedKeys)
+				}
+			case DeletionDelete:
+				if !errors.Is(err, ErrNotExist) || len(result.DeletedChirps) != 1 {
+					t.Errorf("Expected the chirp to be deleted, got %+v %v", kept, err)
+				}
+				if len(media) != 0 || len(result.OrphanedKeys) != 1 || result.OrphanedKeys[0] != "attachment" {
+					t.Errorf("Expected the attachment to be orphaned, got %v %v", media, result.OrphanedKeys)
+				}
+			}
+		})
+	}
+}
+
+func TestCancelDeletion(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.CreateUser("user@example.com", "password"); err != nil {
+		t.Fatalf("Couldn't create user: %v", err)
+	}
+	if _, err := db.RequestDeletion(1, 0); err != nil {
+		t.Fatalf("Couldn't request deletion: %v", err)
+	}
+	user, err := db.CancelDeletion(1)
+	if err != nil {
+		t.Fatalf("Couldn't cancel deletion: %v", err)
+	}
+	if user.PendingDeletion() || user.TokensValidAfter == nil {
+		t.Errorf("Expected the account to be kept with its tokens revoked, got %+v", user)
+	}
+	result, err := db.PurgeDeletedUsers(time.Now(), DeletionDelete)
+	if err != nil || len(result.UserIDs) != 0 {
+		t.Errorf("Expected nothing to be purged, got %+v %v", result, err)
+	}
+}
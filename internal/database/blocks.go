@@ -0,0 +1,162 @@
+package database
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+var ErrBlocked = errors.New("blocked")
+
+// Relationship describes how a viewer relates to another user.
+type Relationship struct {
+	Following  bool `json:"following"`
+	FollowedBy bool `json:"followed_by"`
+	Blocking   bool `json:"blocking"`
+	BlockedBy  bool `json:"blocked_by"`
+	Muting     bool `json:"muting"`
+}
+
+// Block stops blockerID and blockedID from seeing or interacting with each
+// other, ends any follow between them and takes each out of the other's
+// lists. Blocking twice is a no-op.
+func (db *DB) Block(blockerID, blockedID int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[blockedID]; !ok {
+			return ErrNotExist
+		}
+		if _, ok := dbStructure.Blocks[blockerID][blockedID]; ok {
+			return nil
+		}
+		dbStructure.setUserEntry("blocks", blockerID, blockedID, time.Now().UTC())
+		dbStructure.removeFollow(blockerID, blockedID)
+		dbStructure.removeFollow(blockedID, blockerID)
+		dbStructure.removeFromLists(blockerID, blockedID)
+		dbStructure.removeFromLists(blockedID, blockerID)
+		return nil
+	})
+}
+
+// Unblock undoes Block. Follows ended by the block are not restored.
+func (db *DB) Unblock(blockerID, blockedID int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[blockedID]; !ok {
+			return ErrNotExist
+		}
+		dbStructure.deleteUserEntry("blocks", blockerID, blockedID)
+		return nil
+	})
+}
+
+// Mute hides mutedID's chirps from muterID's timelines, search results and
+// notifications without them knowing. Muting twice is a no-op.
+func (db *DB) Mute(muterID, mutedID int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[mutedID]; !ok {
+			return ErrNotExist
+		}
+		if _, ok := dbStructure.Mutes[muterID][mutedID]; ok {
+			return nil
+		}
+		dbStructure.setUserEntry("mutes", muterID, mutedID, time.Now().UTC())
+		return nil
+	})
+}
+
+func (db *DB) Unmute(muterID, mutedID int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[mutedID]; !ok {
+			return ErrNotExist
+		}
+		dbStructure.deleteUserEntry("mutes", muterID, mutedID)
+		return nil
+	})
+}
+
+// GetBlocks lists the users userID blocks, most recent first.
+func (db *DB) GetBlocks(userID int, cursor string, limit int) (UserPage, error) {
+	var page UserPage
+	err := db.view(func(dbStructure *DBStructure) error {
+		var err error
+		page, err = pageUserEntries(dbStructure.userEntryIndex["blocks"][userID], "blocks:"+strconv.Itoa(userID), cursor, limit)
+		return err
+	})
+	return page, err
+}
+
+// GetMutes lists the users userID mutes, most recent first.
+func (db *DB) GetMutes(userID int, cursor string, limit int) (UserPage, error) {
+	var page UserPage
+	err := db.view(func(dbStructure *DBStructure) error {
+		var err error
+		page, err = pageUserEntries(dbStructure.userEntryIndex["mutes"][userID], "mutes:"+strconv.Itoa(userID), cursor, limit)
+		return err
+	})
+	return page, err
+}
+
+func (db *DB) GetRelationship(viewerID, userID int) (Relationship, error) {
+	var relationship Relationship
+	err := db.view(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[userID]; !ok {
+			return ErrNotExist
+		}
+		_, relationship.Following = dbStructure.Following[viewerID][userID]
+		_, relationship.FollowedBy = dbStructure.Following[userID][viewerID]
+		_, relationship.Blocking = dbStructure.Blocks[viewerID][userID]
+		_, relationship.BlockedBy = dbStructure.Blocks[userID][viewerID]
+		_, relationship.Muting = dbStructure.Mutes[viewerID][userID]
+		return nil
+	})
+	return relationship, err
+}
+
+// IsBlocked reports whether either user blocks the other.
+func (db *DB) IsBlocked(userID, otherID int) (bool, error) {
+	var blocked bool
+	err := db.view(func(dbStructure *DBStructure) error {
+		blocked = dbStructure.blocked(userID, otherID)
+		return nil
+	})
+	return blocked, err
+}
+
+func (db *DB) IsMuted(muterID, mutedID int) (bool, error) {
+	var muted bool
+	err := db.view(func(dbStructure *DBStructure) error {
+		muted = dbStructure.muted(muterID, mutedID)
+		return nil
+	})
+	return muted, err
+}
+
+// blocked reports whether either user blocks the other. Anonymous viewers
+// are never blocked.
+func (dbStructure *DBStructure) blocked(userID, otherID int) bool {
+	if userID == 0 || otherID == 0 {
+		return false
+	}
+	_, blocking := dbStructure.Blocks[userID][otherID]
+	_, blockedBy := dbStructure.Blocks[otherID][userID]
+	return blocking || blockedBy
+}
+
+func (dbStructure *DBStructure) muted(muterID, mutedID int) bool {
+	_, ok := dbStructure.Mutes[muterID][mutedID]
+	return ok
+}
+
+// GetChirpForViewer is GetChirp for chirps read on behalf of viewerID.
+// Chirps the viewer may not see don't exist as far as they can tell.
+func (db *DB) GetChirpForViewer(id, viewerID int) (Chirp, error) {
+	var chirp Chirp
+	err := db.view(func(dbStructure *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStructure.Chirps[id]
+		if !ok || chirp.Deleted || !dbStructure.canView(viewerID, chirp) {
+			return ErrNotExist
+		}
+		return nil
+	})
+	return chirp, err
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func chirpIDs(chirps []Chirp) []int {
+	ids := make([]int, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	return ids
+}
+
+func TestBlocks(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"blocker@example.com", "blocked@example.com", "other@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	if _, err := db.UpdateProfile(1, Profile{Username: "blocker"}); err != nil {
+		t.Fatalf("Couldn't update profile: %v", err)
+	}
+	if err := db.Follow(2, 1); err != nil {
+		t.Fatalf("Couldn't follow: %v", err)
+	}
+	chirp, err := db.CreateChirp(Chirp{Body: "secret plans", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	if _, err := db.CreateChirp(Chirp{Body: "plans of mine", AuthorId: 2, InReplyTo: chirp.ID}); err != nil {
+		t.Fatalf("Couldn't reply: %v", err)
+	}
+
+	if err := db.Block(1, 2); err != nil {
+		t.Fatalf("Couldn't block: %v", err)
+	}
+	if following, _ := db.IsFollowing(2, 1); following {
+		t.Errorf("Expected blocking to end the follow")
+	}
+	if err := db.Follow(2, 1); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Expected ErrBlocked following a blocker, got %v", err)
+	}
+	if err := db.Follow(1, 2); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Expected ErrBlocked following a blocked user, got %v", err)
+	}
+	if _, err := db.CreateChirp(Chirp{Body: "let me in", AuthorId: 2, InReplyTo: chirp.ID}); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist replying to a blocker, got %v", err)
+	}
+	mention, err := db.CreateChirp(Chirp{Body: "hey @blocker", AuthorId: 2})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	if ids := mention.mentionedUserIDs(); len(ids) != 0 {
+		t.Errorf("Expected the mention of a blocker to be dropped, got %v", ids)
+	}
+
+	if _, err := db.GetChirpForViewer(chirp.ID, 2); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist viewing a blocker's chirp, got %v", err)
+	}
+	if _, err := db.GetChirpForViewer(chirp.ID, 3); err != nil {
+		t.Errorf("Expected other users to see the chirp, got %v", err)
+	}
+	page, err := db.QueryChirps(ChirpQuery{ViewerID: 2})
+	if err != nil {
+		t.Fatalf("Couldn't query chirps: %v", err)
+	}
+	if ids := chirpIDs(page.Chirps); !reflect.DeepEqual(ids, []int{2, 3}) {
+		t.Errorf("Expected the blocked user to only see their own chirps, got %v", ids)
+	}
+	if _, err := db.GetThread(chirp.ID, 2); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist reading a blocker's thread, got %v", err)
+	}
+	thread, err := db.GetThread(chirp.ID, 1)
+	if err != nil {
+		t.Fatalf("Couldn't get thread: %v", err)
+	}
+	if len(thread) != 2 || !thread[1].Deleted || thread[1].Body != "" {
+		t.Errorf("Expected the blocked user's reply as a tombstone, got %+v", thread)
+	}
+	search, err := db.SearchChirps(SearchQuery{ViewerID: 2, Text: "plans"})
+	if err != nil {
+		t.Fatalf("Couldn't search chirps: %v", err)
+	}
+	if len(search.Results) != 1 || search.Results[0].Chirp.AuthorId != 2 {
+		t.Errorf("Expected search to hide the blocker's chirp, got %+v", search.Results)
+	}
+	users, err := db.SearchUsers(SearchQuery{ViewerID: 2, Text: "blocker"})
+	if err != nil {
+		t.Fatalf("Couldn't search users: %v", err)
+	}
+	if len(users.Results) != 0 {
+		t.Errorf("Expected user search to hide the blocker, got %+v", users.Results)
+	}
+	if _, err := db.AddReaction(chirp.ID, 2, ReactionLike); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist liking a blocker's chirp, got %v", err)
+	}
+
+	relationship, err := db.GetRelationship(2, 1)
+	if err != nil {
+		t.Fatalf("Couldn't get relationship: %v", err)
+	}
+	if relationship != (Relationship{BlockedBy: true}) {
+		t.Errorf("Expected only blocked_by, got %+v", relationship)
+	}
+	blocks, err := db.GetBlocks(1, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't get blocks: %v", err)
+	}
+	if len(blocks.Entries) != 1 || blocks.Entries[0].UserID != 2 {
+		t.Errorf("Expected to block user 2, got %+v", blocks.Entries)
+	}
+
+	if err := db.Unblock(1, 2); err != nil {
+		t.Fatalf("Couldn't unblock: %v", err)
+	}
+	if _, err := db.GetChirpForViewer(chirp.ID, 2); err != nil {
+		t.Errorf("Expected unblocking to restore access, got %v", err)
+	}
+	if err := db.Follow(2, 1); err != nil {
+		t.Errorf("Expected unblocking to allow follows, got %v", err)
+	}
+}
+
+func TestMutes(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"muter@example.com", "muted@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	if _, err := db.UpdateProfile(1, Profile{Username: "muter"}); err != nil {
+		t.Fatalf("Couldn't update profile: %v", err)
+	}
+	if err := db.Follow(1, 2); err != nil {
+		t.Fatalf("Couldn't follow: %v", err)
+	}
+	if err := db.Mute(1, 2); err != nil {
+		t.Fatalf("Couldn't mute: %v", err)
+	}
+	chirp, err := db.CreateChirp(Chirp{Body: "loud news @muter", AuthorId: 2})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+
+	page, err := db.QueryChirps(ChirpQuery{ViewerID: 1, AuthorIDs: []int{1, 2}})
+	if err != nil {
+		t.Fatalf("Couldn't query chirps: %v", err)
+	}
+	if len(page.Chirps) != 0 {
+		t.Errorf("Expected the timeline to hide muted chirps, got %v", chirpIDs(page.Chirps))
+	}
+	page, err = db.QueryChirps(ChirpQuery{ViewerID: 1, AuthorIDs: []int{2}})
+	if err != nil {
+		t.Fatalf("Couldn't query chirps: %v", err)
+	}
+	if ids := chirpIDs(page.Chirps); !reflect.DeepEqual(ids, []int{chirp.ID}) {
+		t.Errorf("Expected the muted author's own listing to show their chirps, got %v", ids)
+	}
+	search, err := db.SearchChirps(SearchQuery{ViewerID: 1, Text: "news"})
+	if err != nil {
+		t.Fatalf("Couldn't search chirps: %v", err)
+	}
+	if len(search.Results) != 0 {
+		t.Errorf("Expected search to hide muted chirps, got %+v", search.Results)
+	}
+	notifications, err := db.GetNotifications(1, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't get notifications: %v", err)
+	}
+	if len(notifications.Groups) != 0 || notifications.UnreadCount != 0 {
+		t.Errorf("Expected notifications from muted users to be hidden, got %+v", notifications)
+	}
+	if _, err := db.GetChirpForViewer(chirp.ID, 1); err != nil {
+		t.Errorf("Expected muted chirps to stay reachable directly, got %v", err)
+	}
+
+	if err := db.Unmute(1, 2); err != nil {
+		t.Fatalf("Couldn't unmute: %v", err)
+	}
+	notifications, err = db.GetNotifications(1, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't get notifications: %v", err)
+	}
+	if len(notifications.Groups) != 1 {
+		t.Errorf("Expected unmuting to restore the mention, got %+v", notifications.Groups)
+	}
+}
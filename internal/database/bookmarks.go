@@ -0,0 +1,81 @@
+package database
+
+import (
+	"strconv"
+	"time"
+)
+
+// AddBookmark saves a chirp to the user's private bookmarks. Bookmarking
+// twice is a no-op.
+func (db *DB) AddBookmark(userID, chirpID int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		chirp, ok := dbStructure.Chirps[chirpID]
+		if !ok || chirp.Deleted || !dbStructure.canView(userID, chirp) {
+			return ErrNotExist
+		}
+		if _, ok := dbStructure.Bookmarks[userID][chirpID]; ok {
+			return nil
+		}
+		dbStructure.setUserEntry("bookmarks", userID, chirpID, time.Now().UTC())
+		addToIndex(dbStructure.bookmarksByChirp, chirpID, userID)
+		return nil
+	})
+}
+
+// RemoveBookmark undoes AddBookmark. Removing a bookmark that doesn't
+// exist is a no-op.
+func (db *DB) RemoveBookmark(userID, chirpID int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		dbStructure.removeBookmark(userID, chirpID)
+		return nil
+	})
+}
+
+func (dbStructure *DBStructure) removeBookmark(userID, chirpID int) {
+	dbStructure.deleteUserEntry("bookmarks", userID, chirpID)
+	removeFromIndex(dbStructure.bookmarksByChirp, chirpID, userID)
+}
+
+// GetBookmarks returns a page of the user's bookmarked chirps, most
+// recently bookmarked first. Chirps the user can no longer see are
+// skipped.
+func (db *DB) GetBookmarks(userID int, cursor string, limit int) (ChirpPage, error) {
+	limit = clampLimit(limit)
+	scope := "bookmarks:" + strconv.Itoa(userID)
+	page := ChirpPage{Chirps: make([]Chirp, 0, limit)}
+	err := db.view(func(dbStructure *DBStructure) error {
+		entries := dbStructure.userEntryIndex["bookmarks"][userID]
+		end, err := seekUserEntries(entries, scope, cursor)
+		if err != nil {
+			return err
+		}
+		var last UserEntry
+		for i := end - 1; i >= 0; i-- {
+			entry := entries[i]
+			chirp, ok := dbStructure.Chirps[entry.UserID]
+			if !ok || chirp.Deleted || !dbStructure.canView(userID, chirp) {
+				continue
+			}
+			if len(page.Chirps) == limit {
+				page.NextCursor = encodeTimeCursor(scope, last.CreatedAt, last.UserID)
+				break
+			}
+			page.Chirps = append(page.Chirps, chirp)
+			last = entry
+		}
+		return nil
+	})
+	return page, err
+}
+
+// GetBookmarkedChirps reports which of chirpIDs the user has bookmarked.
+func (db *DB) GetBookmarkedChirps(chirpIDs []int, userID int) (map[int]bool, error) {
+	bookmarked := make(map[int]bool, len(chirpIDs))
+	err := db.view(func(dbStructure *DBStructure) error {
+		for _, chirpID := range chirpIDs {
+			_, bookmarked[chirpID] = dbStructure.Bookmarks[userID][chirpID]
+		}
+		return nil
+	})
+	return bookmarked, err
+}
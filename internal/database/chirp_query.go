@@ -0,0 +1,204 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+type ChirpSort string
+
+const (
+	SortIDAsc         ChirpSort = "asc"
+	SortIDDesc        ChirpSort = "desc"
+	SortCreatedAtAsc  ChirpSort = "created_at"
+	SortCreatedAtDesc ChirpSort = "-created_at"
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+func (s ChirpSort) Valid() bool {
+	switch s {
+	case SortIDAsc, SortIDDesc, SortCreatedAtAsc, SortCreatedAtDesc:
+		return true
+	}
+	return false
+}
+
+// descending reports whether s walks from newest to oldest. Chirp IDs are
+// assigned in creation order, so sorting by created_at is the same walk as
+// sorting by ID.
+func (s ChirpSort) descending() bool {
+	return s == SortIDDesc || s == SortCreatedAtDesc
+}
+
+// ChirpQuery selects a page of chirps. An empty AuthorIDs matches every
+// author, an empty Hashtag matches every chirp and zero Since/Until leave
+// that end of the range open.
+//
+// Chirps are read on behalf of ViewerID, zero for anonymous viewers. Chirps
+// the viewer can't see are skipped, as are chirps by authors the viewer
+// mutes unless AuthorIDs asks for that author alone. Unlisted chirps only
+// appear when AuthorIDs is set, or to their author.
+//
+// PinnedID is a chirp to return in Pinned on the first page instead of in
+// its place among Chirps, for profiles that show a pinned chirp first.
+type ChirpQuery struct {
+	ViewerID  int
+	AuthorIDs []int
+	Hashtag   string
+	Since     time.Time
+	Until     time.Time
+	Sort      ChirpSort
+	Limit     int
+	Cursor    string
+	PinnedID  int
+}
+
+type ChirpPage struct {
+	Pinned     *Chirp
+	Chirps     []Chirp
+	NextCursor string
+}
+
+// QueryChirps walks the chirp IDs in sort order starting after the cursor,
+// with Since and Until applied by binary search, so the cost of a page
+// depends on its size rather than on the number of chirps stored.
+func (db *DB) QueryChirps(query ChirpQuery) (ChirpPage, error) {
+	if query.Sort == "" {
+		query.Sort = SortIDAsc
+	}
+	if !query.Sort.Valid() {
+		return ChirpPage{}, ErrInvalidSort
+	}
+	limit := clampLimit(query.Limit)
+
+	after := 0
+	if query.Cursor != "" {
+		var err error
+		after, err = decodeCursor(string(query.Sort), query.Cursor)
+		if err != nil {
+			return ChirpPage{}, err
+		}
+	}
+
+	page := ChirpPage{Chirps: make([]Chirp, 0, limit)}
+	err := db.view(func(dbStructure *DBStructure) error {
+		desc := query.Sort.descending()
+		authors := make(map[int]bool, len(query.AuthorIDs))
+		for _, authorID := range query.AuthorIDs {
+			authors[authorID] = true
+		}
+		var lists [][]int
+		if query.Hashtag != "" {
+			lists = [][]int{dbStructure.chirpsByHashtag[NormalizeHashtag(query.Hashtag)]}
+		} else if len(query.AuthorIDs) > 0 {
+			lists = make([][]int, 0, len(query.AuthorIDs))
+			for _, authorID := range query.AuthorIDs {
+				lists = append(lists, dbStructure.chirpsByAuthor[authorID])
+			}
+		} else {
+			lists = [][]int{dbStructure.chirpIDs}
+		}
+		for i, ids := range lists {
+			lists[i] = dbStructure.chirpsInRange(ids, query.Since, query.Until)
+		}
+		next := mergeIDLists(lists, after, desc)
+
+		if query.PinnedID != 0 && query.Cursor == "" {
+			pinned, ok := dbStructure.Chirps[query.PinnedID]
+			if ok && !pinned.Deleted && (len(authors) == 0 || authors[pinned.AuthorId]) && dbStructure.canView(query.ViewerID, pinned) {
+				page.Pinned = &pinned
+			}
+		}
+
+		for id, ok := next(); ok; id, ok = next() {
+			chirp, exists := dbStructure.Chirps[id]
+			if !exists || chirp.Deleted || id == query.PinnedID {
+				continue
+			}
+			if len(authors) > 0 && !authors[chirp.AuthorId] {
+				continue
+			}
+			if !dbStructure.canView(query.ViewerID, chirp) {
+				continue
+			}
+			if len(authors) == 0 && !chirp.Listed() && chirp.AuthorId != query.ViewerID {
+				continue
+			}
+			if len(authors) != 1 && dbStructure.muted(query.ViewerID, chirp.AuthorId) {
+				continue
+			}
+			if len(page.Chirps) == limit {
+				page.NextCursor = encodeCursor(string(query.Sort), page.Chirps[limit-1].ID)
+				break
+			}
+			page.Chirps = append(page.Chirps, chirp)
+		}
+		return nil
+	})
+	return page, err
+}
+
+// walkChirpIDs returns an iterator over the IDs of every chirp that isn't
+// deleted, strictly after the given ID in walk order.
+func (dbStructure *DBStructure) walkChirpIDs(after int, desc bool) func() (int, bool) {
+	return mergeIDLists([][]int{dbStructure.chirpIDs}, after, desc)
+}
+
+// chirpsInRange narrows an ascending list of chirp IDs to the chirps created
+// between since and until, inclusive, with a binary search at each end.
+// Zero times leave that end open. This relies on createChirp never giving
+// a chirp an earlier CreatedAt than the chirp before it.
+func (dbStructure *DBStructure) chirpsInRange(ids []int, since, until time.Time) []int {
+	if !since.IsZero() {
+		ids = ids[sort.Search(len(ids), func(i int) bool {
+			return !dbStructure.Chirps[ids[i]].CreatedAt.Before(since)
+		}):]
+	}
+	if !until.IsZero() {
+		ids = ids[:sort.Search(len(ids), func(i int) bool {
+			return dbStructure.Chirps[ids[i]].CreatedAt.After(until)
+		})]
+	}
+	return ids
+}
+
+// mergeIDLists returns an iterator over the IDs strictly after the given ID
+// in walk order, merged from several ascending ID lists.
+func mergeIDLists(idLists [][]int, after int, desc bool) func() (int, bool) {
+	lists := make([][]int, 0, len(idLists))
+	for _, ids := range idLists {
+		if after != 0 {
+			if desc {
+				ids = ids[:sort.SearchInts(ids, after)]
+			} else {
+				ids = ids[sort.SearchInts(ids, after+1):]
+			}
+		}
+		if len(ids) > 0 {
+			lists = append(lists, ids)
+		}
+	}
+	return func() (int, bool) {
+		best := -1
+		for i, ids := range lists {
+			if len(ids) == 0 {
+				continue
+			}
+			if best == -1 || (desc && ids[len(ids)-1] > lists[best][len(lists[best])-1]) || (!desc && ids[0] < lists[best][0]) {
+				best = i
+			}
+		}
+		if best == -1 {
+			return 0, false
+		}
+		ids := lists[best]
+		if desc {
+			lists[best] = ids[:len(ids)-1]
+			return ids[len(ids)-1], true
+		}
+		lists[best] = ids[1:]
+		return ids[0], true
+	}
+}
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := NewDB(filepath.Join(t.TempDir(), "database.json"))
+	if err != nil {
+		t.Fatalf("Couldn't create database: %v", err)
+	}
+	return db
+}
+
+func TestQueryChirpsPagination(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < 7; i++ {
+		if _, err := db.CreateChirp(Chirp{Body: "chirp", AuthorId: 1 + i%2}); err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	if err := db.DeleteChirp(3); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		query ChirpQuery
+		pages [][]int
+	}{
+		{
+			name:  "ascending",
+			query: ChirpQuery{Limit: 4},
+			pages: [][]int{{1, 2, 4, 5}, {6, 7}},
+		},
+		{
+			name:  "descending",
+			query: ChirpQuery{Sort: SortIDDesc, Limit: 3},
+			pages: [][]int{{7, 6, 5}, {4, 2, 1}},
+		},
+		{
+			name:  "single author",
+			query: ChirpQuery{AuthorIDs: []int{1}, Limit: 2},
+			pages: [][]int{{1, 5}, {7}},
+		},
+		{
+			name:  "merged authors",
+			query: ChirpQuery{AuthorIDs: []int{1, 2}, Sort: SortCreatedAtDesc, Limit: 5},
+			pages: [][]int{{7, 6, 5, 4, 2}, {1}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			query := c.query
+			for i, want := range c.pages {
+				page, err := db.QueryChirps(query)
+				if err != nil {
+					t.Fatalf("Page %d: unexpected error %v", i, err)
+				}
+				got := make([]int, 0, len(page.Chirps))
+				for _, chirp := range page.Chirps {
+					got = append(got, chirp.ID)
+				}
+				if len(got) != len(want) {
+					t.Fatalf("Page %d: expected %v, got %v", i, want, got)
+				}
+				for j := range want {
+					if got[j] != want[j] {
+						t.Fatalf("Page %d: expected %v, got %v", i, want, got)
+					}
+				}
+				if last := i == len(c.pages)-1; last != (page.NextCursor == "") {
+					t.Fatalf("Page %d: unexpected next cursor %q", i, page.NextCursor)
+				}
+				query.Cursor = page.NextCursor
+			}
+		})
+	}
+}
+
+func TestQueryChirpsRejectsForeignCursor(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.QueryChirps(ChirpQuery{Sort: SortIDDesc, Cursor: encodeCursor(string(SortIDAsc), 1)})
+	if err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestQueryChirpsTimeRange(t *testing.T) {
+	db := newTestDB(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		err := db.update(func(dbStructure *DBStructure) error {
+			_, err := dbStructure.createChirp(Chirp{Body: "chirp", AuthorId: 1 + i%2}, start.Add(time.Duration(i)*time.Hour))
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	if err := db.DeleteChirp(4); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		query ChirpQuery
+		want  []int
+	}{
+		{
+			name:  "since and until",
+			query: ChirpQuery{Since: start.Add(2 * time.Hour), Until: start.Add(4 * time.Hour)},
+			want:  []int{3, 5},
+		},
+		{
+			name:  "since descending",
+			query: ChirpQuery{Since: start.Add(90 * time.Minute), Sort: SortCreatedAtDesc},
+			want:  []int{6, 5, 3},
+		},
+		{
+			name:  "until by author",
+			query: ChirpQuery{AuthorIDs: []int{1}, Until: start.Add(4 * time.Hour)},
+			want:  []int{1, 3, 5},
+		},
+		{
+			name:  "merged authors",
+			query: ChirpQuery{AuthorIDs: []int{1, 2}, Since: start.Add(time.Hour), Until: start.Add(time.Hour), Sort: SortIDDesc},
+			want:  []int{2},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			page, err := db.QueryChirps(c.query)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			got := make([]int, 0, len(page.Chirps))
+			for _, chirp := range page.Chirps {
+				got = append(got, chirp.ID)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("Expected %v, got %v", c.want, got)
+			}
+			for i := range c.want {
+				if got[i] != c.want[i] {
+					t.Fatalf("Expected %v, got %v", c.want, got)
+				}
+			}
+		})
+	}
+
+	var chirp Chirp
+	err := db.update(func(dbStructure *DBStructure) error {
+		var err error
+		chirp, err = dbStructure.createChirp(Chirp{Body: "clock went back", AuthorId: 1}, start)
+		return err
+	})
+	if err != nil || !chirp.CreatedAt.Equal(start.Add(5*time.Hour)) {
+		t.Errorf("Expected a chirp to never be older than the one before it, got %v, %v", chirp.CreatedAt, err)
+	}
+}
+
+func TestUpdateDiscardsFailedChanges(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.CreateUser("author@example.com", "password"); err != nil {
+		t.Fatalf("Couldn't create user: %v", err)
+	}
+	failed := errors.New("failed")
+	err := db.update(func(dbStructure *DBStructure) error {
+		id := dbStructure.nextID("chirps")
+		dbStructure.Chirps[id] = Chirp{ID: id, Body: "half written", AuthorId: 1}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Expected update to return fn's error, got %v", err)
+	}
+	if _, err := db.GetChirp(1); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected the failed chirp to be discarded, got %v", err)
+	}
+	chirp, err := db.CreateChirp(Chirp{Body: "next", AuthorId: 1})
+	if err != nil || chirp.ID != 1 {
+		t.Errorf("Expected the failed chirp to not use up an ID, got %+v, %v", chirp, err)
+	}
+}
+
+func TestFailedChirpLeavesNoTrace(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.CreateUser("author@example.com", "password"); err != nil {
+		t.Fatalf("Couldn't create user: %v", err)
+	}
+	parent, err := db.CreateChirp(Chirp{Body: "parent", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	if _, err := db.CreateChirp(Chirp{Body: "reply", AuthorId: 1, InReplyTo: parent.ID, QuoteOf: 99}); !errors.Is(err, ErrQuotedChirpNotExist) {
+		t.Fatalf("Expected quoting a missing chirp to return ErrQuotedChirpNotExist, got %v", err)
+	}
+	parent, err = db.GetChirp(parent.ID)
+	if err != nil || parent.ReplyCount != 0 {
+		t.Errorf("Expected the failed reply to not be counted, got %+v, %v", parent, err)
+	}
+	chirp, err := db.CreateChirp(Chirp{Body: "next", AuthorId: 1})
+	if err != nil || chirp.ID != 2 {
+		t.Errorf("Expected the failed chirp to not use up an ID, got %+v, %v", chirp, err)
+	}
+}
@@ -1,68 +1,348 @@
 package database
 
+import (
+	"sort"
+	"time"
+)
+
 type Chirp struct {
-	ID       int    `json:"id"`
-	Body     string `json:"body"`
-	AuthorId int    `json:"author_id"`
+	ID           int         `json:"id"`
+	Body         string      `json:"body"`
+	AuthorId     int         `json:"author_id"`
+	InReplyTo    int         `json:"in_reply_to,omitempty"`
+	QuoteOf      int         `json:"quote_of,omitempty"`
+	Quote        *ChirpQuote `json:"quote,omitempty"`
+	ThreadID     int         `json:"thread_id"`
+	Visibility   Visibility  `json:"visibility"`
+	MediaIDs     []int       `json:"media_ids,omitempty"`
+	Poll         *Poll       `json:"poll,omitempty"`
+	Entities     []Entity    `json:"entities,omitempty"`
+	ReplyCount   int         `json:"reply_count"`
+	LikeCount    int         `json:"like_count"`
+	RechirpCount int         `json:"rechirp_count"`
+	QuoteCount   int         `json:"quote_count"`
+	Deleted      bool        `json:"deleted,omitempty"`
+	Hold         *ChirpHold  `json:"hold,omitempty"`
+	Hidden       bool        `json:"hidden,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+	EditedAt     *time.Time  `json:"edited_at"`
+}
+
+type ChirpRevision struct {
+	Body       string    `json:"body"`
+	ReplacedAt time.Time `json:"replaced_at"`
 }
 
-func (db *DB) CreateChirp(body string, authorId int) (Chirp, error) {
-	dbStructure, err := db.loadDB()
+// CreateChirp stores a new chirp built from the body, author, parent, quoted
+// chirp, attachments, visibility, poll and hold of chirp, and parses the
+// entities in its body. Chirps without a visibility are public. Replying to
+// a chirp that doesn't exist or that the author can't see returns
+// ErrNotExist, and quoting one returns ErrQuotedChirpNotExist.
+func (db *DB) CreateChirp(chirp Chirp) (Chirp, error) {
+	var newChirp Chirp
+	err := db.update(func(dbStructure *DBStructure) error {
+		var err error
+		newChirp, err = dbStructure.createChirp(chirp, time.Now().UTC())
+		return err
+	})
 	if err != nil {
 		return Chirp{}, err
 	}
 
-	lastID := 0
-	if len(dbStructure.Chirps) > 0 {
-		lastChirp := dbStructure.Chirps[len(dbStructure.Chirps)]
-		lastID = lastChirp.ID
+	return newChirp, nil
+}
+
+func (dbStructure *DBStructure) createChirp(chirp Chirp, now time.Time) (Chirp, error) {
+	// Chirps are walked in ID order as if it were creation order, so a
+	// chirp is never older than the one before it, even if the clock goes
+	// back.
+	if n := len(dbStructure.chirpIDs); n > 0 {
+		if latest := dbStructure.Chirps[dbStructure.chirpIDs[n-1]].CreatedAt; now.Before(latest) {
+			now = latest
+		}
 	}
 	newChirp := Chirp{
-		Body:     body,
-		ID:       lastID + 1,
-		AuthorId: authorId,
+		Body:       chirp.Body,
+		AuthorId:   chirp.AuthorId,
+		InReplyTo:  chirp.InReplyTo,
+		QuoteOf:    chirp.QuoteOf,
+		MediaIDs:   chirp.MediaIDs,
+		Visibility: chirp.Visibility,
+		Poll:       newPoll(chirp.Poll),
+		Entities:   dbStructure.parseChirpEntities(chirp.AuthorId, chirp.Body),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if newChirp.Visibility == "" {
+		newChirp.Visibility = VisibilityPublic
+	}
+	if chirp.Hold != nil {
+		newChirp.Hold = &ChirpHold{Reason: chirp.Hold.Reason, HeldAt: now}
 	}
 
-	dbStructure.Chirps[newChirp.ID] = newChirp
-	err = db.writeDB(dbStructure)
-
-	if err != nil {
-		return Chirp{}, err
+	if newChirp.InReplyTo != 0 {
+		parent, ok := dbStructure.Chirps[newChirp.InReplyTo]
+		if !ok || parent.Deleted || !dbStructure.canView(newChirp.AuthorId, parent) {
+			return Chirp{}, ErrNotExist
+		}
+		newChirp.ThreadID = parent.ThreadID
+	}
+	// quoteChirp is the last check, so nothing has changed if it fails.
+	if newChirp.QuoteOf != 0 {
+		err := dbStructure.quoteChirp(&newChirp)
+		if err != nil {
+			return Chirp{}, err
+		}
+	}
+	if newChirp.InReplyTo != 0 {
+		parent := dbStructure.Chirps[newChirp.InReplyTo]
+		parent.ReplyCount++
+		dbStructure.Chirps[parent.ID] = parent
 	}
 
+	newChirp.ID = dbStructure.nextID("chirps")
+	if newChirp.ThreadID == 0 {
+		newChirp.ThreadID = newChirp.ID
+	}
+	dbStructure.Chirps[newChirp.ID] = newChirp
+	dbStructure.indexChirp(newChirp)
+	dbStructure.notifyChirp(newChirp)
 	return newChirp, nil
 }
 
 func (db *DB) GetChirps() ([]Chirp, error) {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return nil, err
+	var chirps []Chirp
+	err := db.view(func(dbStructure *DBStructure) error {
+		chirps = make([]Chirp, 0, len(dbStructure.Chirps))
+		for _, val := range dbStructure.Chirps {
+			chirps = append(chirps, val)
+		}
+		return nil
+	})
+	return chirps, err
+}
+
+func (db *DB) GetChirp(id int) (Chirp, error) {
+	var chirp Chirp
+	err := db.view(func(dbStructure *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStructure.Chirps[id]
+		if !ok || chirp.Deleted {
+			return ErrNotExist
+		}
+		return nil
+	})
+	return chirp, err
+}
+
+// DeleteChirp removes a chirp. A chirp that still has replies is replaced by
+// a tombstone so its thread stays connected, and tombstones left without
+// replies are removed along with it.
+func (db *DB) DeleteChirp(id int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		dbStructure.deleteChirp(id)
+		return nil
+	})
+}
+
+func (dbStructure *DBStructure) deleteChirp(id int) {
+	chirp, ok := dbStructure.Chirps[id]
+	if !ok || chirp.Deleted {
+		return
+	}
+	delete(dbStructure.ChirpRevisions, id)
+	dbStructure.deleteUserEntries("likes", id)
+	dbStructure.deleteUserEntries("rechirps", id)
+	delete(dbStructure.PollVotes, id)
+	for _, userID := range append([]int(nil), dbStructure.bookmarksByChirp[id]...) {
+		dbStructure.removeBookmark(userID, id)
 	}
-	chirps := make([]Chirp, 0, len(dbStructure.Chirps))
-	for _, val := range dbStructure.Chirps {
-		chirps = append(chirps, val)
+	dbStructure.unnotifyChirp(chirp)
+	dbStructure.unquoteChirp(chirp)
+	if chirp.QuoteCount > 0 {
+		dbStructure.updateQuotes(id, func(*ChirpQuote) *ChirpQuote { return nil })
+	}
+	if author, ok := dbStructure.Users[chirp.AuthorId]; ok && author.PinnedChirpID == id {
+		author.PinnedChirpID = 0
+		dbStructure.Users[author.ID] = author
+	}
+
+	if chirp.ReplyCount > 0 {
+		tombstone := chirp.tombstone()
+		tombstone.UpdatedAt = time.Now().UTC()
+		dbStructure.unindexChirp(chirp)
+		dbStructure.indexChirp(tombstone)
+		dbStructure.Chirps[id] = tombstone
+		return
+	}
+
+	for {
+		dbStructure.unindexChirp(chirp)
+		delete(dbStructure.Chirps, chirp.ID)
+
+		parent, ok := dbStructure.Chirps[chirp.InReplyTo]
+		if !ok {
+			return
+		}
+		parent.ReplyCount--
+		dbStructure.Chirps[parent.ID] = parent
+		if !parent.Deleted || parent.ReplyCount > 0 {
+			return
+		}
+		chirp = parent
 	}
-	return chirps, nil
 }
 
-func (db *DB) GetChirp(id int) (Chirp, error) {
-	dbStructure, err := db.loadDB()
+// UpdateChirp replaces the body of a chirp, keeping the old body as a
+// revision. hold replaces any hold on the chirp, so an edit that passes
+// moderation releases it.
+func (db *DB) UpdateChirp(id int, body string, hold *ChirpHold) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStructure.Chirps[id]
+		if !ok || chirp.Deleted {
+			return ErrNotExist
+		}
+
+		now := time.Now().UTC()
+		dbStructure.ChirpRevisions[id] = append(dbStructure.ChirpRevisions[id], ChirpRevision{
+			Body:       chirp.Body,
+			ReplacedAt: now,
+		})
+		dbStructure.unindexChirp(chirp)
+		previous := chirp
+		chirp.Body = body
+		chirp.Entities = dbStructure.parseChirpEntities(chirp.AuthorId, body)
+		chirp.EditedAt = &now
+		chirp.UpdatedAt = now
+		chirp.Hold = nil
+		if hold != nil {
+			chirp.Hold = &ChirpHold{Reason: hold.Reason, HeldAt: now}
+		}
+		dbStructure.Chirps[id] = chirp
+		dbStructure.indexChirp(chirp)
+		if previous.Hold != nil && chirp.Hold == nil {
+			dbStructure.notifyChirp(chirp)
+		} else {
+			dbStructure.notifyMentionChanges(previous, chirp)
+		}
+		return nil
+	})
 	if err != nil {
 		return Chirp{}, err
 	}
-	chirp, ok := dbStructure.Chirps[id]
-	if !ok {
-		return Chirp{}, ErrNotExist
-	}
 	return chirp, nil
 }
 
-func (db *DB) DeleteChirp(id int) error {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return err
+func (db *DB) GetChirpRevisions(id, viewerID int) ([]ChirpRevision, error) {
+	var revisions []ChirpRevision
+	err := db.view(func(dbStructure *DBStructure) error {
+		if chirp, ok := dbStructure.Chirps[id]; !ok || chirp.Deleted || !dbStructure.canView(viewerID, chirp) {
+			return ErrNotExist
+		}
+		revisions = append(make([]ChirpRevision, 0), dbStructure.ChirpRevisions[id]...)
+		return nil
+	})
+	return revisions, err
+}
+
+// GetThread returns every chirp in the conversation containing id, including
+// tombstones, ordered by ID so parents come before their replies. Chirps
+// viewerID can't see are replaced by tombstones so the thread stays
+// connected.
+func (db *DB) GetThread(id, viewerID int) ([]Chirp, error) {
+	var thread []Chirp
+	err := db.view(func(dbStructure *DBStructure) error {
+		chirp, ok := dbStructure.Chirps[id]
+		if !ok || !dbStructure.canView(viewerID, chirp) {
+			return ErrNotExist
+		}
+		ids := dbStructure.chirpsByThread[chirp.ThreadID]
+		thread = make([]Chirp, 0, len(ids))
+		for _, threadChirpID := range ids {
+			threadChirp := dbStructure.Chirps[threadChirpID]
+			if !threadChirp.Deleted && !dbStructure.canView(viewerID, threadChirp) {
+				threadChirp = threadChirp.tombstone()
+			}
+			thread = append(thread, threadChirp)
+		}
+		return nil
+	})
+	return thread, err
+}
+
+// tombstone returns chirp stripped of its author and content, keeping only
+// its place in the thread.
+func (chirp Chirp) tombstone() Chirp {
+	return Chirp{
+		ID:         chirp.ID,
+		InReplyTo:  chirp.InReplyTo,
+		ThreadID:   chirp.ThreadID,
+		ReplyCount: chirp.ReplyCount,
+		Deleted:    true,
+		CreatedAt:  chirp.CreatedAt,
+		UpdatedAt:  chirp.UpdatedAt,
+	}
+}
+
+// indexChirp adds chirp to the indexes. Tombstones are only indexed by
+// thread, since they have no author or content left.
+func (dbStructure *DBStructure) indexChirp(chirp Chirp) {
+	addToIndex(dbStructure.chirpsByThread, chirp.ThreadID, chirp.ID)
+	if chirp.Deleted {
+		return
+	}
+	dbStructure.chirpIDs = insertID(dbStructure.chirpIDs, chirp.ID)
+	addToIndex(dbStructure.chirpsByAuthor, chirp.AuthorId, chirp.ID)
+	for _, tag := range chirp.Hashtags() {
+		addToIndex(dbStructure.chirpsByHashtag, tag, chirp.ID)
+	}
+	dbStructure.chirpSearch.add(chirp.ID, chirp.Body)
+}
+
+func (dbStructure *DBStructure) unindexChirp(chirp Chirp) {
+	removeFromIndex(dbStructure.chirpsByThread, chirp.ThreadID, chirp.ID)
+	dbStructure.chirpIDs = removeID(dbStructure.chirpIDs, chirp.ID)
+	removeFromIndex(dbStructure.chirpsByAuthor, chirp.AuthorId, chirp.ID)
+	for _, tag := range chirp.Hashtags() {
+		removeFromIndex(dbStructure.chirpsByHashtag, tag, chirp.ID)
+	}
+	dbStructure.chirpSearch.remove(chirp.ID)
+}
+
+// addToIndex inserts id into the ascending ID list stored under key.
+func addToIndex[K comparable](index map[K][]int, key K, id int) {
+	index[key] = insertID(index[key], id)
+}
+
+func removeFromIndex[K comparable](index map[K][]int, key K, id int) {
+	ids := removeID(index[key], id)
+	if len(ids) == 0 {
+		delete(index, key)
+		return
+	}
+	index[key] = ids
+}
+
+// insertID inserts id into an ascending ID list, unless it is already
+// there.
+func insertID(ids []int, id int) []int {
+	i := sort.SearchInts(ids, id)
+	if i < len(ids) && ids[i] == id {
+		return ids
+	}
+	ids = append(ids, 0)
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+	return ids
+}
+
+func removeID(ids []int, id int) []int {
+	i := sort.SearchInts(ids, id)
+	if i == len(ids) || ids[i] != id {
+		return ids
 	}
-	delete(dbStructure.Chirps, id)
-	db.writeDB(dbStructure)
-	return nil
+	return append(ids[:i], ids[i+1:]...)
 }
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDeleteChirpInThread(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.CreateUser("author@example.com", "password"); err != nil {
+		t.Fatalf("Couldn't create user: %v", err)
+	}
+	// 1 is the root, 2 and 4 reply to it and 3 replies to 2.
+	for _, chirp := range []Chirp{
+		{Body: "root", AuthorId: 1},
+		{Body: "reply", AuthorId: 1, InReplyTo: 1},
+		{Body: "nested reply", AuthorId: 1, InReplyTo: 2},
+		{Body: "second reply", AuthorId: 1, InReplyTo: 1},
+	} {
+		if _, err := db.CreateChirp(chirp); err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	replyCount := func(id int) int {
+		t.Helper()
+		thread, err := db.GetThread(1, 1)
+		if err != nil {
+			t.Fatalf("Couldn't get thread: %v", err)
+		}
+		for _, chirp := range thread {
+			if chirp.ID == id {
+				return chirp.ReplyCount
+			}
+		}
+		t.Fatalf("Expected chirp %d in the thread", id)
+		return 0
+	}
+	threadIDs := func() []int {
+		t.Helper()
+		thread, err := db.GetThread(1, 1)
+		if err != nil {
+			t.Fatalf("Couldn't get thread: %v", err)
+		}
+		return chirpIDs(thread)
+	}
+	if replyCount(1) != 2 || replyCount(2) != 1 || replyCount(3) != 0 {
+		t.Errorf("Expected reply counts 2, 1 and 0, got %d, %d and %d", replyCount(1), replyCount(2), replyCount(3))
+	}
+
+	if err := db.DeleteChirp(2); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+	thread, err := db.GetThread(3, 1)
+	if err != nil || !reflect.DeepEqual(chirpIDs(thread), []int{1, 2, 3, 4}) {
+		t.Fatalf("Expected the thread to stay connected, got %v, %v", chirpIDs(thread), err)
+	}
+	if tombstone := thread[1]; !tombstone.Deleted || tombstone.Body != "" || tombstone.AuthorId != 0 || tombstone.ReplyCount != 1 {
+		t.Errorf("Expected a deleted parent to become a tombstone, got %+v", tombstone)
+	}
+	if replyCount(1) != 2 {
+		t.Errorf("Expected a tombstone to still count as a reply, got %d", replyCount(1))
+	}
+	if _, err := db.GetChirp(2); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected a tombstone to be hidden outside the thread, got %v", err)
+	}
+
+	if err := db.DeleteChirp(3); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+	if ids := threadIDs(); !reflect.DeepEqual(ids, []int{1, 4}) {
+		t.Errorf("Expected the tombstone to go with its last reply, got %v", ids)
+	}
+	if replyCount(1) != 1 {
+		t.Errorf("Expected the root to lose the collapsed reply, got %d", replyCount(1))
+	}
+
+	if err := db.DeleteChirp(1); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+	if ids := threadIDs(); !reflect.DeepEqual(ids, []int{1, 4}) {
+		t.Errorf("Expected the root to become a tombstone, got %v", ids)
+	}
+	if err := db.DeleteChirp(4); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+	if _, err := db.GetThread(1, 1); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected the whole chain to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,325 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MaxConversationParticipants caps group conversations, creator included.
+const MaxConversationParticipants = 10
+
+var ErrInvalidParticipants = errors.New("invalid participants")
+
+// ReadReceipt records the newest message a participant has read.
+type ReadReceipt struct {
+	MessageID int       `json:"message_id"`
+	ReadAt    time.Time `json:"read_at"`
+}
+
+// Conversation is a private exchange of messages between two or more users.
+// ParticipantIDs is sorted and includes the creator. UpdatedAt moves forward
+// with every message, so conversations are listed by recent activity.
+type Conversation struct {
+	ID             int                 `json:"id"`
+	CreatorID      int                 `json:"creator_id"`
+	ParticipantIDs []int               `json:"participant_ids"`
+	LastMessageID  int                 `json:"last_message_id,omitempty"`
+	ReadReceipts   map[int]ReadReceipt `json:"read_receipts"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+type Message struct {
+	ID             int       `json:"id"`
+	ConversationID int       `json:"conversation_id"`
+	SenderID       int       `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ConversationSummary is a conversation as seen by one participant: the
+// newest message they can see and how many messages they haven't read.
+type ConversationSummary struct {
+	Conversation
+	LastMessage *Message
+	UnreadCount int
+}
+
+// ConversationPage is a page of conversations, most recently active first.
+// UnreadCount totals the unread messages across all of the user's
+// conversations, not only the ones on this page.
+type ConversationPage struct {
+	Conversations []ConversationSummary
+	NextCursor    string
+	UnreadCount   int
+}
+
+type MessagePage struct {
+	Messages   []Message
+	NextCursor string
+}
+
+func (conversation Conversation) hasParticipant(userID int) bool {
+	return indexContains(conversation.ParticipantIDs, userID)
+}
+
+// CreateConversation starts a conversation between creatorID and
+// participantIDs. Duplicate IDs are ignored. Users that don't exist return
+// ErrNotExist, and users blocking or blocked by the creator return
+// ErrBlocked. A one-to-one conversation is only created once: asking again
+// returns the existing one with created set to false.
+func (db *DB) CreateConversation(creatorID int, participantIDs []int) (conversation Conversation, created bool, err error) {
+	err = db.update(func(dbStructure *DBStructure) error {
+		ids := append([]int{creatorID}, participantIDs...)
+		sort.Ints(ids)
+		ids = slices.Compact(ids)
+		if len(ids) < 2 || len(ids) > MaxConversationParticipants {
+			return ErrInvalidParticipants
+		}
+		for _, id := range ids {
+			if _, ok := dbStructure.Users[id]; !ok {
+				return ErrNotExist
+			}
+			if dbStructure.blocked(creatorID, id) {
+				return ErrBlocked
+			}
+		}
+
+		if len(ids) == 2 {
+			for _, id := range dbStructure.conversationsByUser[creatorID] {
+				existing := dbStructure.Conversations[id]
+				if len(existing.ParticipantIDs) == 2 && existing.hasParticipant(ids[0]) && existing.hasParticipant(ids[1]) {
+					conversation = existing
+					return nil
+				}
+			}
+		}
+
+		now := time.Now().UTC()
+		conversation = Conversation{
+			ID:             dbStructure.nextID("conversations"),
+			CreatorID:      creatorID,
+			ParticipantIDs: ids,
+			ReadReceipts:   make(map[int]ReadReceipt),
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		dbStructure.Conversations[conversation.ID] = conversation
+		dbStructure.indexConversation(conversation)
+		created = true
+		return nil
+	})
+	return conversation, created, err
+}
+
+// GetConversation returns the conversation as seen by userID. Conversations
+// userID isn't part of return ErrNotExist.
+func (db *DB) GetConversation(id, userID int) (ConversationSummary, error) {
+	var summary ConversationSummary
+	err := db.view(func(dbStructure *DBStructure) error {
+		conversation, ok := dbStructure.Conversations[id]
+		if !ok || !conversation.hasParticipant(userID) {
+			return ErrNotExist
+		}
+		summary = dbStructure.summarizeConversation(conversation, userID)
+		return nil
+	})
+	return summary, err
+}
+
+// GetConversations returns a page of userID's conversations, most recently
+// active first.
+func (db *DB) GetConversations(userID int, cursor string, limit int) (ConversationPage, error) {
+	limit = clampLimit(limit)
+	scope := "conversations:" + strconv.Itoa(userID)
+	var after UserEntry
+	if cursor != "" {
+		var err error
+		after.CreatedAt, after.UserID, err = decodeTimeCursor(scope, cursor)
+		if err != nil {
+			return ConversationPage{}, err
+		}
+	}
+
+	page := ConversationPage{Conversations: make([]ConversationSummary, 0, limit)}
+	err := db.view(func(dbStructure *DBStructure) error {
+		summaries := make([]ConversationSummary, 0)
+		for _, id := range dbStructure.conversationsByUser[userID] {
+			summary := dbStructure.summarizeConversation(dbStructure.Conversations[id], userID)
+			page.UnreadCount += summary.UnreadCount
+			summaries = append(summaries, summary)
+		}
+		// Sort like pageUserEntries does, with the conversation ID in
+		// place of the user ID.
+		sort.Slice(summaries, func(i, j int) bool {
+			return entryBefore(summaries[j].activity(), summaries[i].activity())
+		})
+
+		for _, summary := range summaries {
+			if cursor != "" && !entryBefore(summary.activity(), after) {
+				continue
+			}
+			if len(page.Conversations) == limit {
+				last := page.Conversations[limit-1]
+				page.NextCursor = encodeTimeCursor(scope, last.UpdatedAt, last.ID)
+				break
+			}
+			page.Conversations = append(page.Conversations, summary)
+		}
+		return nil
+	})
+	return page, err
+}
+
+func (conversation Conversation) activity() UserEntry {
+	return UserEntry{UserID: conversation.ID, CreatedAt: conversation.UpdatedAt}
+}
+
+// summarizeConversation skips messages from users blocking or blocked by
+// userID, both as the last message and in the unread count.
+func (dbStructure *DBStructure) summarizeConversation(conversation Conversation, userID int) ConversationSummary {
+	summary := ConversationSummary{Conversation: conversation}
+	readUpTo := conversation.ReadReceipts[userID].MessageID
+	ids := dbStructure.messagesByConversation[conversation.ID]
+	for i := len(ids) - 1; i >= 0; i-- {
+		message := dbStructure.Messages[ids[i]]
+		if dbStructure.blocked(userID, message.SenderID) {
+			continue
+		}
+		if summary.LastMessage == nil {
+			summary.LastMessage = &message
+		}
+		if message.ID <= readUpTo {
+			break
+		}
+		if message.SenderID != userID {
+			summary.UnreadCount++
+		}
+	}
+	return summary
+}
+
+// SendMessage adds a message from senderID to a conversation, which counts as
+// senderID reading it. Senders outside the conversation get ErrNotExist, and
+// senders blocking or blocked by another participant get ErrBlocked.
+func (db *DB) SendMessage(conversationID, senderID int, body string) (Message, Conversation, error) {
+	var message Message
+	var conversation Conversation
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		conversation, ok = dbStructure.Conversations[conversationID]
+		if !ok || !conversation.hasParticipant(senderID) {
+			return ErrNotExist
+		}
+		for _, id := range conversation.ParticipantIDs {
+			if dbStructure.blocked(senderID, id) {
+				return ErrBlocked
+			}
+		}
+
+		message = Message{
+			ID:             dbStructure.nextID("messages"),
+			ConversationID: conversationID,
+			SenderID:       senderID,
+			Body:           body,
+			CreatedAt:      time.Now().UTC(),
+		}
+		dbStructure.Messages[message.ID] = message
+		addToIndex(dbStructure.messagesByConversation, conversationID, message.ID)
+
+		conversation.LastMessageID = message.ID
+		conversation.UpdatedAt = message.CreatedAt
+		conversation.ReadReceipts = copyReadReceipts(conversation.ReadReceipts)
+		conversation.ReadReceipts[senderID] = ReadReceipt{MessageID: message.ID, ReadAt: message.CreatedAt}
+		dbStructure.Conversations[conversationID] = conversation
+		return nil
+	})
+	return message, conversation, err
+}
+
+// GetMessages returns a page of a conversation's messages, newest first.
+// Messages from users blocking or blocked by userID are skipped.
+func (db *DB) GetMessages(conversationID, userID int, cursor string, limit int) (MessagePage, error) {
+	limit = clampLimit(limit)
+	scope := "messages:" + strconv.Itoa(conversationID)
+	after := 0
+	if cursor != "" {
+		var err error
+		after, err = decodeCursor(scope, cursor)
+		if err != nil {
+			return MessagePage{}, err
+		}
+	}
+
+	page := MessagePage{Messages: make([]Message, 0, limit)}
+	err := db.view(func(dbStructure *DBStructure) error {
+		conversation, ok := dbStructure.Conversations[conversationID]
+		if !ok || !conversation.hasParticipant(userID) {
+			return ErrNotExist
+		}
+		ids := dbStructure.messagesByConversation[conversationID]
+		for i := len(ids) - 1; i >= 0; i-- {
+			if after != 0 && ids[i] >= after {
+				continue
+			}
+			message := dbStructure.Messages[ids[i]]
+			if dbStructure.blocked(userID, message.SenderID) {
+				continue
+			}
+			if len(page.Messages) == limit {
+				page.NextCursor = encodeCursor(scope, page.Messages[limit-1].ID)
+				break
+			}
+			page.Messages = append(page.Messages, message)
+		}
+		return nil
+	})
+	return page, err
+}
+
+// MarkConversationRead records that userID has read the conversation up to
+// messageID, or up to its newest message if messageID is zero. Read
+// receipts only move forward, so marking an older message is a no-op.
+func (db *DB) MarkConversationRead(conversationID, userID, messageID int) (Conversation, error) {
+	var conversation Conversation
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		conversation, ok = dbStructure.Conversations[conversationID]
+		if !ok || !conversation.hasParticipant(userID) {
+			return ErrNotExist
+		}
+		if messageID == 0 {
+			messageID = conversation.LastMessageID
+		} else if message, ok := dbStructure.Messages[messageID]; !ok || message.ConversationID != conversationID {
+			return ErrNotExist
+		}
+		if messageID <= conversation.ReadReceipts[userID].MessageID {
+			return nil
+		}
+
+		conversation.ReadReceipts = copyReadReceipts(conversation.ReadReceipts)
+		conversation.ReadReceipts[userID] = ReadReceipt{MessageID: messageID, ReadAt: time.Now().UTC()}
+		dbStructure.Conversations[conversationID] = conversation
+		return nil
+	})
+	return conversation, err
+}
+
+// copyReadReceipts copies receipts before a change, since callers may still
+// hold the conversation returned by an earlier call.
+func copyReadReceipts(receipts map[int]ReadReceipt) map[int]ReadReceipt {
+	copied := make(map[int]ReadReceipt, len(receipts)+1)
+	for userID, receipt := range receipts {
+		copied[userID] = receipt
+	}
+	return copied
+}
+
+func (dbStructure *DBStructure) indexConversation(conversation Conversation) {
+	for _, userID := range conversation.ParticipantIDs {
+		addToIndex(dbStructure.conversationsByUser, userID, conversation.ID)
+	}
+}
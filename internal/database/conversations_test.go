@@ -0,0 +1,115 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestConversations(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+
+	direct, created, err := db.CreateConversation(2, []int{1, 1})
+	if err != nil || !created {
+		t.Fatalf("Couldn't create conversation: %v", err)
+	}
+	if !reflect.DeepEqual(direct.ParticipantIDs, []int{1, 2}) {
+		t.Errorf("Expected participants [1 2], got %v", direct.ParticipantIDs)
+	}
+	again, created, err := db.CreateConversation(1, []int{2})
+	if err != nil || created || again.ID != direct.ID {
+		t.Errorf("Expected the existing conversation %d, got %d created %v err %v", direct.ID, again.ID, created, err)
+	}
+	if _, _, err := db.CreateConversation(1, nil); !errors.Is(err, ErrInvalidParticipants) {
+		t.Errorf("Expected ErrInvalidParticipants talking to yourself, got %v", err)
+	}
+	if _, _, err := db.CreateConversation(1, []int{99}); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist for an unknown user, got %v", err)
+	}
+	group, _, err := db.CreateConversation(1, []int{2, 3})
+	if err != nil {
+		t.Fatalf("Couldn't create group: %v", err)
+	}
+
+	for _, send := range []struct{ conversationID, senderID int }{
+		{direct.ID, 1}, {direct.ID, 2}, {direct.ID, 2}, {group.ID, 3},
+	} {
+		if _, _, err := db.SendMessage(send.conversationID, send.senderID, "hi"); err != nil {
+			t.Fatalf("Couldn't send message: %v", err)
+		}
+	}
+	if _, _, err := db.SendMessage(direct.ID, 3, "let me in"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist sending outside the conversation, got %v", err)
+	}
+	if _, err := db.GetMessages(direct.ID, 4, "", 0); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist reading outside the conversation, got %v", err)
+	}
+
+	page, err := db.GetConversations(1, "", 1)
+	if err != nil {
+		t.Fatalf("Couldn't get conversations: %v", err)
+	}
+	if page.UnreadCount != 3 {
+		t.Errorf("Expected 3 unread messages, got %d", page.UnreadCount)
+	}
+	if len(page.Conversations) != 1 || page.Conversations[0].ID != group.ID || page.NextCursor == "" {
+		t.Fatalf("Expected the group first with a next page, got %+v", page)
+	}
+	page, err = db.GetConversations(1, page.NextCursor, 1)
+	if err != nil {
+		t.Fatalf("Couldn't get conversations: %v", err)
+	}
+	if len(page.Conversations) != 1 || page.Conversations[0].ID != direct.ID || page.Conversations[0].UnreadCount != 2 {
+		t.Errorf("Expected the direct conversation with 2 unread, got %+v", page.Conversations)
+	}
+
+	messages, err := db.GetMessages(direct.ID, 1, "", 2)
+	if err != nil {
+		t.Fatalf("Couldn't get messages: %v", err)
+	}
+	if len(messages.Messages) != 2 || messages.Messages[0].ID != 3 || messages.NextCursor == "" {
+		t.Fatalf("Expected the 2 newest messages and a next page, got %+v", messages)
+	}
+	conversation, err := db.MarkConversationRead(direct.ID, 1, messages.Messages[1].ID)
+	if err != nil {
+		t.Fatalf("Couldn't mark read: %v", err)
+	}
+	if conversation.ReadReceipts[1].MessageID != 2 {
+		t.Errorf("Expected a read receipt for message 2, got %+v", conversation.ReadReceipts)
+	}
+	if _, err := db.MarkConversationRead(direct.ID, 1, 1); err != nil {
+		t.Fatalf("Couldn't mark read: %v", err)
+	}
+	summary, err := db.GetConversation(direct.ID, 1)
+	if err != nil {
+		t.Fatalf("Couldn't get conversation: %v", err)
+	}
+	if summary.UnreadCount != 1 || summary.ReadReceipts[1].MessageID != 2 {
+		t.Errorf("Expected receipts to only move forward, got %+v", summary)
+	}
+	if _, err := db.MarkConversationRead(direct.ID, 1, 4); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist marking another conversation's message, got %v", err)
+	}
+
+	if err := db.Block(2, 1); err != nil {
+		t.Fatalf("Couldn't block: %v", err)
+	}
+	if _, _, err := db.SendMessage(direct.ID, 1, "hello?"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Expected ErrBlocked messaging a blocker, got %v", err)
+	}
+	if _, _, err := db.CreateConversation(1, []int{2, 3}); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Expected ErrBlocked adding a blocker, got %v", err)
+	}
+	summary, err = db.GetConversation(direct.ID, 1)
+	if err != nil {
+		t.Fatalf("Couldn't get conversation: %v", err)
+	}
+	if summary.UnreadCount != 0 || summary.LastMessage == nil || summary.LastMessage.SenderID != 1 {
+		t.Errorf("Expected messages from the blocker to be hidden, got %+v", summary)
+	}
+}
@@ -4,19 +4,69 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
 
+// DB keeps the whole database in memory and writes it back to path after
+// every change. All access goes through view and update so reads never
+// observe a half-applied write.
 type DB struct {
 	path string
 	mux  *sync.RWMutex
+	data DBStructure
 }
 
 type DBStructure struct {
-	Chirps        map[int]Chirp        `json:"chirps"`
-	Users         map[int]User         `json:"users"`
-	RevokedTokens map[string]time.Time `json:"revoked_tokens"`
+	SchemaVersion           int                             `json:"schema_version"`
+	Sequences               map[string]int                  `json:"sequences"`
+	Chirps                  map[int]Chirp                   `json:"chirps"`
+	ChirpRevisions          map[int][]ChirpRevision         `json:"chirp_revisions"`
+	Likes                   map[int]map[int]time.Time       `json:"likes"`
+	Rechirps                map[int]map[int]time.Time       `json:"rechirps"`
+	Users                   map[int]User                    `json:"users"`
+	Following               map[int]map[int]time.Time       `json:"following"`
+	Followers               map[int]map[int]time.Time       `json:"followers"`
+	Blocks                  map[int]map[int]time.Time       `json:"blocks"`
+	Mutes                   map[int]map[int]time.Time       `json:"mutes"`
+	Media                   map[int]Media                   `json:"media"`
+	Notifications           map[int]Notification            `json:"notifications"`
+	NotificationPreferences map[int]NotificationPreferences `json:"notification_preferences"`
+	Conversations           map[int]Conversation            `json:"conversations"`
+	Messages                map[int]Message                 `json:"messages"`
+	Reports                 map[int]Report                  `json:"reports"`
+	ModerationLog           []ModerationLogEntry            `json:"moderation_log"`
+	RevokedTokens           map[string]time.Time            `json:"revoked_tokens"`
+	Drafts                  map[int]Draft                   `json:"drafts"`
+	PollVotes               map[int]map[int]PollVote        `json:"poll_votes"`
+	Bookmarks               map[int]map[int]time.Time       `json:"bookmarks"`
+	Lists                   map[int]List                    `json:"lists"`
+	ListMembers             map[int]map[int]time.Time       `json:"list_members"`
+
+	// Indexes are rebuilt on load and are never written to disk. Chirp ID
+	// lists are kept in ascending order, and hashtags and usernames are
+	// lowercased. chirpIDs holds every chirp that isn't deleted. The search
+	// indexes cover chirp bodies and user profiles. Notifications are indexed
+	// by recipient and by group, and unread ones again by recipient.
+	// Bookmarks are indexed under the chirp, holding the users who saved it.
+	// Conversations are indexed under each participant. userEntryIndex holds
+	// the entries of each collection in userEntryCollections, oldest first.
+	chirpIDs                  []int
+	chirpsByAuthor            map[int][]int
+	chirpsByThread            map[int][]int
+	chirpsByHashtag           map[string][]int
+	usersByUsername           map[string]int
+	notificationsByUser       map[int][]int
+	notificationsByGroup      map[string][]int
+	unreadNotificationsByUser map[int][]int
+	bookmarksByChirp          map[int][]int
+	conversationsByUser       map[int][]int
+	messagesByConversation    map[int][]int
+	userEntryIndex            map[string]map[int][]UserEntry
+	chirpSearch               *searchIndex
+	userSearch                *searchIndex
 }
 
 var ErrNotExist = errors.New("resource does not exist")
@@ -31,30 +81,87 @@ func NewDB(path string) (*DB, error) {
 	return db, err
 }
 
-func (db *DB) createDB() error {
-	structure := DBStructure{
-		Chirps:        make(map[int]Chirp),
-		Users:         make(map[int]User),
-		RevokedTokens: make(map[string]time.Time),
+func newDBStructure() DBStructure {
+	return DBStructure{
+		Sequences:                 make(map[string]int),
+		Chirps:                    make(map[int]Chirp),
+		ChirpRevisions:            make(map[int][]ChirpRevision),
+		Likes:                     make(map[int]map[int]time.Time),
+		Rechirps:                  make(map[int]map[int]time.Time),
+		Users:                     make(map[int]User),
+		Following:                 make(map[int]map[int]time.Time),
+		Followers:                 make(map[int]map[int]time.Time),
+		Blocks:                    make(map[int]map[int]time.Time),
+		Mutes:                     make(map[int]map[int]time.Time),
+		Media:                     make(map[int]Media),
+		Notifications:             make(map[int]Notification),
+		NotificationPreferences:   make(map[int]NotificationPreferences),
+		Conversations:             make(map[int]Conversation),
+		Messages:                  make(map[int]Message),
+		Reports:                   make(map[int]Report),
+		ModerationLog:             make([]ModerationLogEntry, 0),
+		RevokedTokens:             make(map[string]time.Time),
+		Drafts:                    make(map[int]Draft),
+		PollVotes:                 make(map[int]map[int]PollVote),
+		Bookmarks:                 make(map[int]map[int]time.Time),
+		Lists:                     make(map[int]List),
+		ListMembers:               make(map[int]map[int]time.Time),
+		chirpsByAuthor:            make(map[int][]int),
+		chirpsByThread:            make(map[int][]int),
+		chirpsByHashtag:           make(map[string][]int),
+		usersByUsername:           make(map[string]int),
+		notificationsByUser:       make(map[int][]int),
+		notificationsByGroup:      make(map[string][]int),
+		unreadNotificationsByUser: make(map[int][]int),
+		bookmarksByChirp:          make(map[int][]int),
+		conversationsByUser:       make(map[int][]int),
+		messagesByConversation:    make(map[int][]int),
+		userEntryIndex:            make(map[string]map[int][]UserEntry),
+		chirpSearch:               newSearchIndex(),
+		userSearch:                newSearchIndex(),
 	}
+}
+
+func (db *DB) createDB() error {
+	structure := newDBStructure()
+	structure.SchemaVersion = len(migrations)
 	return db.writeDB(structure)
 }
 
 func (db *DB) ensureDB() error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
 	_, err := os.Stat(db.path)
 	if os.IsNotExist(err) {
-		return db.createDB()
+		err = db.createDB()
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	if migrate(&dbStructure) {
+		err = db.writeDB(dbStructure)
+		if err != nil {
+			return err
+		}
+	}
+	dbStructure.buildIndexes()
+	db.data = dbStructure
+	return nil
 }
 
+// loadDB reads the database file. Callers must hold db.mux.
 func (db *DB) loadDB() (DBStructure, error) {
-	db.mux.RLock()
-	defer db.mux.RUnlock()
-
-	dbStructure := DBStructure{}
+	// Start from an initialized structure so collections missing from
+	// older database files are usable without a nil map check.
+	dbStructure := newDBStructure()
 	file, err := os.ReadFile(db.path)
-	if errors.Is(err, os.ErrNotExist) {
+	if err != nil {
 		return dbStructure, err
 	}
 	err = json.Unmarshal(file, &dbStructure)
@@ -65,22 +172,134 @@ func (db *DB) loadDB() (DBStructure, error) {
 	return dbStructure, nil
 }
 
+// writeDB persists dbStructure to the database file. It writes to a
+// temporary file and renames it into place, so a crash mid-write leaves the
+// previous version intact rather than a truncated file. Callers must hold
+// db.mux for writing.
 func (db *DB) writeDB(dbStructure DBStructure) error {
-	db.mux.Lock()
-	defer db.mux.Unlock()
-
 	data, err := json.Marshal(dbStructure)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(db.path, data, 0600)
+	tmp, err := os.CreateTemp(filepath.Dir(db.path), ".database-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), db.path)
+}
+
+// view runs fn with shared access to the database. fn must not modify the
+// structure or keep references to its maps and slices after returning.
+func (db *DB) view(fn func(dbStructure *DBStructure) error) error {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	return fn(&db.data)
+}
+
+// update runs fn with exclusive access to the database and persists the
+// result, so a read-modify-write inside fn is atomic. If fn fails or the
+// write fails, the in-memory state is reloaded from disk.
+func (db *DB) update(fn func(dbStructure *DBStructure) error) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	err := fn(&db.data)
+	if err == nil {
+		err = db.writeDB(db.data)
+		if err == nil {
+			return nil
+		}
+	}
+
+	dbStructure, loadErr := db.loadDB()
+	if loadErr != nil {
+		return errors.Join(err, loadErr)
+	}
+	dbStructure.buildIndexes()
+	db.data = dbStructure
+	return err
 }
 
 func (db *DB) ResetDB() error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
 	err := os.Remove(db.path)
-	if errors.Is(err, os.ErrNotExist) {
-		return nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	err = db.createDB()
+	if err != nil {
+		return err
 	}
-	db.ensureDB()
+	db.data, err = db.loadDB()
+	db.data.buildIndexes()
 	return err
 }
+
+// nextID returns the next unused ID for collection. IDs are never reused,
+// even after the record holding one is deleted.
+func (dbStructure *DBStructure) nextID(collection string) int {
+	dbStructure.Sequences[collection]++
+	return dbStructure.Sequences[collection]
+}
+
+func (dbStructure *DBStructure) buildIndexes() {
+	dbStructure.chirpIDs = nil
+	dbStructure.chirpsByAuthor = make(map[int][]int)
+	dbStructure.chirpsByThread = make(map[int][]int)
+	dbStructure.chirpsByHashtag = make(map[string][]int)
+	dbStructure.usersByUsername = make(map[string]int)
+	dbStructure.notificationsByUser = make(map[int][]int)
+	dbStructure.notificationsByGroup = make(map[string][]int)
+	dbStructure.unreadNotificationsByUser = make(map[int][]int)
+	dbStructure.bookmarksByChirp = make(map[int][]int)
+	dbStructure.conversationsByUser = make(map[int][]int)
+	dbStructure.messagesByConversation = make(map[int][]int)
+	dbStructure.userEntryIndex = make(map[string]map[int][]UserEntry)
+	dbStructure.chirpSearch = newSearchIndex()
+	dbStructure.userSearch = newSearchIndex()
+	for id, user := range dbStructure.Users {
+		if user.Username != "" {
+			dbStructure.usersByUsername[strings.ToLower(user.Username)] = id
+		}
+		dbStructure.indexUser(user)
+	}
+	for id := 1; id <= dbStructure.Sequences["chirps"]; id++ {
+		if chirp, ok := dbStructure.Chirps[id]; ok {
+			dbStructure.indexChirp(chirp)
+		}
+	}
+	for id := 1; id <= dbStructure.Sequences["notifications"]; id++ {
+		if notification, ok := dbStructure.Notifications[id]; ok {
+			dbStructure.indexNotification(notification)
+		}
+	}
+	for userID, bookmarks := range dbStructure.Bookmarks {
+		for chirpID := range bookmarks {
+			addToIndex(dbStructure.bookmarksByChirp, chirpID, userID)
+		}
+	}
+	for _, conversation := range dbStructure.Conversations {
+		dbStructure.indexConversation(conversation)
+	}
+	for id := 1; id <= dbStructure.Sequences["messages"]; id++ {
+		if message, ok := dbStructure.Messages[id]; ok {
+			addToIndex(dbStructure.messagesByConversation, message.ConversationID, id)
+		}
+	}
+	for _, collection := range userEntryCollections {
+		dbStructure.buildUserEntryIndex(collection)
+	}
+}
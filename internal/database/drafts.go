@@ -0,0 +1,290 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Draft is a chirp that hasn't been published. Drafts with a PublishAt are
+// scheduled, and are published by the scheduler once that time passes.
+// Only their author can see drafts.
+type Draft struct {
+	ID         int        `json:"id"`
+	AuthorID   int        `json:"author_id"`
+	Body       string     `json:"body"`
+	InReplyTo  int        `json:"in_reply_to,omitempty"`
+	QuoteOf    int        `json:"quote_of,omitempty"`
+	MediaIDs   []int      `json:"media_ids,omitempty"`
+	Visibility Visibility `json:"visibility"`
+	Poll       *Poll      `json:"poll,omitempty"`
+	PublishAt  *time.Time `json:"publish_at,omitempty"`
+	// Error says why a scheduled chirp couldn't be published. The draft is
+	// unscheduled when that happens, and editing it clears the error.
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ErrDraftChanged is returned when a draft was edited or deleted after it
+// was read, so it wasn't published.
+var ErrDraftChanged = errors.New("draft changed")
+
+func (draft Draft) Scheduled() bool {
+	return draft.PublishAt != nil
+}
+
+type DraftPage struct {
+	Drafts     []Draft
+	NextCursor string
+}
+
+// CreateDraft stores a new draft built from the body, author, parent,
+// quoted chirp, attachments, visibility, poll and publish time of draft.
+func (db *DB) CreateDraft(draft Draft) (Draft, error) {
+	var newDraft Draft
+	err := db.update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[draft.AuthorID]; !ok {
+			return ErrNotExist
+		}
+		now := time.Now().UTC()
+		newDraft = Draft{
+			ID:        dbStructure.nextID("drafts"),
+			AuthorID:  draft.AuthorID,
+			CreatedAt: now,
+		}
+		newDraft.edit(draft, now)
+		dbStructure.Drafts[newDraft.ID] = newDraft
+		return nil
+	})
+	if err != nil {
+		return Draft{}, err
+	}
+	return newDraft, nil
+}
+
+func (draft *Draft) edit(changes Draft, now time.Time) {
+	draft.Body = changes.Body
+	draft.InReplyTo = changes.InReplyTo
+	draft.QuoteOf = changes.QuoteOf
+	draft.MediaIDs = changes.MediaIDs
+	draft.Visibility = changes.Visibility
+	draft.Poll = newPoll(changes.Poll)
+	if draft.Visibility == "" {
+		draft.Visibility = VisibilityPublic
+	}
+	draft.PublishAt = nil
+	if changes.PublishAt != nil {
+		publishAt := changes.PublishAt.UTC()
+		draft.PublishAt = &publishAt
+	}
+	draft.Error = ""
+	draft.UpdatedAt = now
+}
+
+// GetDraft returns one of authorID's drafts. Other users' drafts return
+// ErrNotExist.
+func (db *DB) GetDraft(id, authorID int) (Draft, error) {
+	var draft Draft
+	err := db.view(func(dbStructure *DBStructure) error {
+		var ok bool
+		draft, ok = dbStructure.Drafts[id]
+		if !ok || draft.AuthorID != authorID {
+			return ErrNotExist
+		}
+		return nil
+	})
+	return draft, err
+}
+
+// GetDrafts returns a page of authorID's unscheduled drafts, newest first.
+func (db *DB) GetDrafts(authorID int, cursor string, limit int) (DraftPage, error) {
+	limit = clampLimit(limit)
+	scope := "drafts:" + strconv.Itoa(authorID)
+	before := 0
+	if cursor != "" {
+		var err error
+		before, err = decodeCursor(scope, cursor)
+		if err != nil {
+			return DraftPage{}, err
+		}
+	}
+
+	page := DraftPage{Drafts: make([]Draft, 0, limit)}
+	err := db.view(func(dbStructure *DBStructure) error {
+		start := dbStructure.Sequences["drafts"]
+		if before != 0 {
+			start = before - 1
+		}
+		for id := start; id > 0; id-- {
+			draft, ok := dbStructure.Drafts[id]
+			if !ok || draft.AuthorID != authorID || draft.Scheduled() {
+				continue
+			}
+			if len(page.Drafts) == limit {
+				page.NextCursor = encodeCursor(scope, page.Drafts[limit-1].ID)
+				break
+			}
+			page.Drafts = append(page.Drafts, draft)
+		}
+		return nil
+	})
+	return page, err
+}
+
+// GetScheduledChirps returns a page of authorID's scheduled drafts, the
+// next to be published first.
+func (db *DB) GetScheduledChirps(authorID int, cursor string, limit int) (DraftPage, error) {
+	limit = clampLimit(limit)
+	scope := "scheduled:" + strconv.Itoa(authorID)
+	var afterTime time.Time
+	afterID := 0
+	if cursor != "" {
+		var err error
+		afterTime, afterID, err = decodeTimeCursor(scope, cursor)
+		if err != nil {
+			return DraftPage{}, err
+		}
+	}
+
+	var scheduled []Draft
+	err := db.view(func(dbStructure *DBStructure) error {
+		scheduled = dbStructure.scheduledDrafts(func(draft Draft) bool {
+			return draft.AuthorID == authorID
+		})
+		return nil
+	})
+	if err != nil {
+		return DraftPage{}, err
+	}
+
+	page := DraftPage{Drafts: make([]Draft, 0, limit)}
+	for _, draft := range scheduled {
+		if cursor != "" && !scheduledBefore(Draft{ID: afterID, PublishAt: &afterTime}, draft) {
+			continue
+		}
+		if len(page.Drafts) == limit {
+			last := page.Drafts[limit-1]
+			page.NextCursor = encodeTimeCursor(scope, *last.PublishAt, last.ID)
+			break
+		}
+		page.Drafts = append(page.Drafts, draft)
+	}
+	return page, nil
+}
+
+// GetDueDrafts returns every scheduled draft whose publish time is at or
+// before now, the earliest first.
+func (db *DB) GetDueDrafts(now time.Time) ([]Draft, error) {
+	var due []Draft
+	err := db.view(func(dbStructure *DBStructure) error {
+		due = dbStructure.scheduledDrafts(func(draft Draft) bool {
+			return !draft.PublishAt.After(now)
+		})
+		return nil
+	})
+	return due, err
+}
+
+// scheduledDrafts returns the scheduled drafts that match, ordered by
+// publish time.
+func (dbStructure *DBStructure) scheduledDrafts(match func(Draft) bool) []Draft {
+	drafts := make([]Draft, 0)
+	for _, draft := range dbStructure.Drafts {
+		if draft.Scheduled() && match(draft) {
+			drafts = append(drafts, draft)
+		}
+	}
+	sort.Slice(drafts, func(i, j int) bool {
+		return scheduledBefore(drafts[i], drafts[j])
+	})
+	return drafts
+}
+
+func scheduledBefore(a, b Draft) bool {
+	if !a.PublishAt.Equal(*b.PublishAt) {
+		return a.PublishAt.Before(*b.PublishAt)
+	}
+	return a.ID < b.ID
+}
+
+// UpdateDraft replaces the body, parent, quoted chirp, attachments,
+// visibility, poll and publish time of one of draft.AuthorID's drafts.
+func (db *DB) UpdateDraft(draft Draft) (Draft, error) {
+	var updated Draft
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		updated, ok = dbStructure.Drafts[draft.ID]
+		if !ok || updated.AuthorID != draft.AuthorID {
+			return ErrNotExist
+		}
+		updated.edit(draft, time.Now().UTC())
+		dbStructure.Drafts[draft.ID] = updated
+		return nil
+	})
+	if err != nil {
+		return Draft{}, err
+	}
+	return updated, nil
+}
+
+func (db *DB) DeleteDraft(id, authorID int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		draft, ok := dbStructure.Drafts[id]
+		if !ok || draft.AuthorID != authorID {
+			return ErrNotExist
+		}
+		delete(dbStructure.Drafts, id)
+		return nil
+	})
+}
+
+// PublishDraft creates chirp and deletes draft in a single update, so a
+// draft is never published twice, even across restarts. If the draft was
+// edited or deleted since it was read, nothing happens and ErrDraftChanged
+// is returned. Replying to or quoting a chirp that is gone returns an
+// ErrNotExist, as with CreateChirp.
+func (db *DB) PublishDraft(draft Draft, chirp Chirp) (Chirp, error) {
+	var newChirp Chirp
+	err := db.update(func(dbStructure *DBStructure) error {
+		current, ok := dbStructure.Drafts[draft.ID]
+		if !ok || !current.UpdatedAt.Equal(draft.UpdatedAt) {
+			return ErrDraftChanged
+		}
+		var err error
+		newChirp, err = dbStructure.createChirp(chirp, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		delete(dbStructure.Drafts, draft.ID)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return newChirp, nil
+}
+
+// UnscheduleDraft turns a scheduled draft that couldn't be published back
+// into an unscheduled one, recording why. Like PublishDraft, it returns
+// ErrDraftChanged if the draft changed since it was read.
+func (db *DB) UnscheduleDraft(draft Draft, reason string) (Draft, error) {
+	var updated Draft
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		updated, ok = dbStructure.Drafts[draft.ID]
+		if !ok || !updated.UpdatedAt.Equal(draft.UpdatedAt) {
+			return ErrDraftChanged
+		}
+		updated.PublishAt = nil
+		updated.Error = reason
+		updated.UpdatedAt = time.Now().UTC()
+		dbStructure.Drafts[draft.ID] = updated
+		return nil
+	})
+	if err != nil {
+		return Draft{}, err
+	}
+	return updated, nil
+}
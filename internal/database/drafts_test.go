@@ -0,0 +1,91 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPublishDraft(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "other@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	now := time.Now().UTC()
+	later := now.Add(time.Hour)
+	if _, err := db.CreateDraft(Draft{AuthorID: 1, Body: "draft"}); err != nil {
+		t.Fatalf("Couldn't create draft: %v", err)
+	}
+	due, err := db.CreateDraft(Draft{AuthorID: 1, Body: "due", PublishAt: &now})
+	if err != nil {
+		t.Fatalf("Couldn't create draft: %v", err)
+	}
+	if _, err := db.CreateDraft(Draft{AuthorID: 1, Body: "later", PublishAt: &later}); err != nil {
+		t.Fatalf("Couldn't create draft: %v", err)
+	}
+
+	drafts, err := db.GetDrafts(1, "", 10)
+	if err != nil || len(drafts.Drafts) != 1 || drafts.Drafts[0].Body != "draft" {
+		t.Fatalf("Expected only the unscheduled draft, got %+v, %v", drafts.Drafts, err)
+	}
+	scheduled, err := db.GetScheduledChirps(1, "", 1)
+	if err != nil || len(scheduled.Drafts) != 1 || scheduled.Drafts[0].Body != "due" || scheduled.NextCursor == "" {
+		t.Fatalf("Expected the due chirp first, got %+v, %v", scheduled, err)
+	}
+	scheduled, err = db.GetScheduledChirps(1, scheduled.NextCursor, 1)
+	if err != nil || len(scheduled.Drafts) != 1 || scheduled.Drafts[0].Body != "later" {
+		t.Fatalf("Expected the later chirp next, got %+v, %v", scheduled, err)
+	}
+	if _, err := db.GetDraft(due.ID, 2); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected other users' drafts to not exist, got %v", err)
+	}
+
+	dueDrafts, err := db.GetDueDrafts(now)
+	if err != nil || len(dueDrafts) != 1 || dueDrafts[0].ID != due.ID {
+		t.Fatalf("Expected only the due chirp, got %+v, %v", dueDrafts, err)
+	}
+	chirp, err := db.PublishDraft(due, Chirp{Body: due.Body, AuthorId: 1})
+	if err != nil || chirp.Body != "due" {
+		t.Fatalf("Expected the chirp to be published, got %+v, %v", chirp, err)
+	}
+	if _, err := db.PublishDraft(due, Chirp{Body: due.Body, AuthorId: 1}); !errors.Is(err, ErrDraftChanged) {
+		t.Errorf("Expected publishing twice to return ErrDraftChanged, got %v", err)
+	}
+	chirps, err := db.GetChirps()
+	if err != nil || len(chirps) != 1 {
+		t.Errorf("Expected one chirp, got %+v, %v", chirps, err)
+	}
+}
+
+func TestEditedDraftIsNotPublished(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.CreateUser("author@example.com", "password"); err != nil {
+		t.Fatalf("Couldn't create user: %v", err)
+	}
+	now := time.Now().UTC()
+	draft, err := db.CreateDraft(Draft{AuthorID: 1, Body: "first", PublishAt: &now})
+	if err != nil {
+		t.Fatalf("Couldn't create draft: %v", err)
+	}
+	edited, err := db.UpdateDraft(Draft{ID: draft.ID, AuthorID: 1, Body: "second", PublishAt: &now})
+	if err != nil {
+		t.Fatalf("Couldn't update draft: %v", err)
+	}
+	if _, err := db.PublishDraft(draft, Chirp{Body: draft.Body, AuthorId: 1}); !errors.Is(err, ErrDraftChanged) {
+		t.Errorf("Expected a stale draft to return ErrDraftChanged, got %v", err)
+	}
+	if _, err := db.UnscheduleDraft(draft, "stale"); !errors.Is(err, ErrDraftChanged) {
+		t.Errorf("Expected a stale draft to return ErrDraftChanged, got %v", err)
+	}
+
+	unscheduled, err := db.UnscheduleDraft(edited, "too long")
+	if err != nil || unscheduled.Scheduled() || unscheduled.Error != "too long" {
+		t.Fatalf("Expected the draft to be unscheduled with its error, got %+v, %v", unscheduled, err)
+	}
+	edited, err = db.UpdateDraft(Draft{ID: draft.ID, AuthorID: 1, Body: "third"})
+	if err != nil || edited.Error != "" {
+		t.Errorf("Expected editing to clear the error, got %+v, %v", edited, err)
+	}
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+type EntityType string
+
+const (
+	EntityHashtag EntityType = "hashtag"
+	EntityMention EntityType = "mention"
+	EntityURL     EntityType = "url"
+)
+
+const maxMentionLength = 15
+
+// Entity is a structured span of a chirp body. Start and End are offsets in
+// Unicode code points, End exclusive, and include the # or @ sign. Text is
+// the span without its sign.
+type Entity struct {
+	Type   EntityType `json:"type"`
+	Text   string     `json:"text"`
+	Start  int        `json:"start"`
+	End    int        `json:"end"`
+	UserID int        `json:"user_id,omitempty"`
+}
+
+// ParseEntities finds the hashtags, mentions and URLs in body, in order.
+// Mentions are returned unresolved, with a zero UserID.
+func ParseEntities(body string) []Entity {
+	runes := []rune(body)
+	entities := make([]Entity, 0)
+	for i := 0; i < len(runes); i++ {
+		if i > 0 && isWordRune(runes[i-1]) {
+			continue
+		}
+		switch {
+		case runes[i] == '#':
+			end := scanWhile(runes, i+1, isWordRune)
+			tag := string(runes[i+1 : end])
+			if strings.IndexFunc(tag, unicode.IsLetter) == -1 {
+				continue
+			}
+			entities = append(entities, Entity{Type: EntityHashtag, Text: tag, Start: i, End: end})
+			i = end - 1
+		case runes[i] == '@':
+			end := scanWhile(runes, i+1, isUsernameRune)
+			if end == i+1 || end-i-1 > maxMentionLength || (end < len(runes) && runes[end] == '@') {
+				continue
+			}
+			entities = append(entities, Entity{Type: EntityMention, Text: string(runes[i+1 : end]), Start: i, End: end})
+			i = end - 1
+		case hasURLPrefix(runes[i:]):
+			end := scanWhile(runes, i, func(r rune) bool { return !unicode.IsSpace(r) })
+			for end > i && strings.ContainsRune(".,!?;:)'\"", runes[end-1]) {
+				end--
+			}
+			entities = append(entities, Entity{Type: EntityURL, Text: string(runes[i:end]), Start: i, End: end})
+			i = end - 1
+		}
+	}
+	return entities
+}
+
+// NormalizeHashtag returns the form hashtags are indexed and looked up by.
+func NormalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isUsernameRune(r rune) bool {
+	return r == '_' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+}
+
+func scanWhile(runes []rune, start int, ok func(rune) bool) int {
+	end := start
+	for end < len(runes) && ok(runes[end]) {
+		end++
+	}
+	return end
+}
+
+func hasURLPrefix(runes []rune) bool {
+	for _, prefix := range []string{"http://", "https://"} {
+		if len(runes) > len(prefix) && strings.EqualFold(string(runes[:len(prefix)]), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseChirpEntities parses body and links mentions to existing users.
+// Mentions of unknown users, and of users blocking or blocked by the author,
+// are dropped.
+func (dbStructure *DBStructure) parseChirpEntities(authorID int, body string) []Entity {
+	parsed := ParseEntities(body)
+	entities := make([]Entity, 0, len(parsed))
+	for _, entity := range parsed {
+		if entity.Type == EntityMention {
+			userID, ok := dbStructure.usersByUsername[strings.ToLower(entity.Text)]
+			if !ok || dbStructure.blocked(authorID, userID) {
+				continue
+			}
+			entity.UserID = userID
+		}
+		entities = append(entities, entity)
+	}
+	if len(entities) == 0 {
+		return nil
+	}
+	return entities
+}
+
+// Hashtags returns the distinct normalized hashtags of a chirp.
+func (chirp Chirp) Hashtags() []string {
+	tags := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, entity := range chirp.Entities {
+		tag := NormalizeHashtag(entity.Text)
+		if entity.Type != EntityHashtag || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
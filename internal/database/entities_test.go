@@ -0,0 +1,49 @@
+package database
+
+import "testing"
+
+func TestParseEntities(t *testing.T) {
+	cases := []struct {
+		body     string
+		entities []Entity
+	}{
+		{
+			body: "Loving #golang and #Go2 today",
+			entities: []Entity{
+				{Type: EntityHashtag, Text: "golang", Start: 7, End: 14},
+				{Type: EntityHashtag, Text: "Go2", Start: 19, End: 23},
+			},
+		},
+		{
+			body: "cc @alice, mail me at bob@example.com",
+			entities: []Entity{
+				{Type: EntityMention, Text: "alice", Start: 3, End: 9},
+			},
+		},
+		{
+			body: "Read https://example.com/a?b=c. #1 is not a tag",
+			entities: []Entity{
+				{Type: EntityURL, Text: "https://example.com/a?b=c", Start: 5, End: 30},
+			},
+		},
+		{
+			body: "¡Olé #café!",
+			entities: []Entity{
+				{Type: EntityHashtag, Text: "café", Start: 5, End: 10},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.body, func(t *testing.T) {
+			entities := ParseEntities(c.body)
+			if len(entities) != len(c.entities) {
+				t.Fatalf("Expected %v, got %v", c.entities, entities)
+			}
+			for i := range entities {
+				if entities[i] != c.entities[i] {
+					t.Errorf("Expected %v, got %v", c.entities[i], entities[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"strconv"
+	"time"
+)
+
+type FollowCounts struct {
+	Followers int `json:"follower_count"`
+	Following int `json:"following_count"`
+}
+
+// Follow makes followerID follow followeeID. Following someone twice is a
+// no-op, and following someone when either blocks the other returns
+// ErrBlocked. Both directions are stored so either list can be read directly.
+func (db *DB) Follow(followerID, followeeID int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[followeeID]; !ok {
+			return ErrNotExist
+		}
+		if dbStructure.blocked(followerID, followeeID) {
+			return ErrBlocked
+		}
+		if _, ok := dbStructure.Following[followerID][followeeID]; ok {
+			return nil
+		}
+		now := time.Now().UTC()
+		dbStructure.setUserEntry("following", followerID, followeeID, now)
+		dbStructure.setUserEntry("followers", followeeID, followerID, now)
+		dbStructure.notify(followeeID, NotificationFollow, followerID, 0)
+		return nil
+	})
+}
+
+func (db *DB) Unfollow(followerID, followeeID int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[followeeID]; !ok {
+			return ErrNotExist
+		}
+		dbStructure.removeFollow(followerID, followeeID)
+		return nil
+	})
+}
+
+// removeFollow deletes a follow along with the notification it caused.
+func (dbStructure *DBStructure) removeFollow(followerID, followeeID int) {
+	dbStructure.deleteUserEntry("following", followerID, followeeID)
+	dbStructure.deleteUserEntry("followers", followeeID, followerID)
+	dbStructure.unnotify(followeeID, func(n Notification) bool {
+		return n.Type == NotificationFollow && n.ActorID == followerID
+	})
+}
+
+func (db *DB) GetFollowCounts(userID int) (FollowCounts, error) {
+	var counts FollowCounts
+	err := db.view(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[userID]; !ok {
+			return ErrNotExist
+		}
+		counts.Followers = len(dbStructure.Followers[userID])
+		counts.Following = len(dbStructure.Following[userID])
+		return nil
+	})
+	return counts, err
+}
+
+// GetFollowers lists the users following userID, most recent first.
+func (db *DB) GetFollowers(userID int, cursor string, limit int) (UserPage, error) {
+	return db.pageFollows(userID, "followers", cursor, limit)
+}
+
+// GetFollowing lists the users userID follows, most recent first.
+func (db *DB) GetFollowing(userID int, cursor string, limit int) (UserPage, error) {
+	return db.pageFollows(userID, "following", cursor, limit)
+}
+
+func (db *DB) pageFollows(userID int, direction, cursor string, limit int) (UserPage, error) {
+	var page UserPage
+	err := db.view(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[userID]; !ok {
+			return ErrNotExist
+		}
+		var err error
+		page, err = pageUserEntries(dbStructure.userEntryIndex[direction][userID], direction+":"+strconv.Itoa(userID), cursor, limit)
+		return err
+	})
+	return page, err
+}
+
+func (db *DB) IsFollowing(followerID, followeeID int) (bool, error) {
+	var following bool
+	err := db.view(func(dbStructure *DBStructure) error {
+		_, following = dbStructure.Following[followerID][followeeID]
+		return nil
+	})
+	return following, err
+}
+
+// GetFollowingIDs returns the IDs of every user userID follows.
+func (db *DB) GetFollowingIDs(userID int) ([]int, error) {
+	var ids []int
+	err := db.view(func(dbStructure *DBStructure) error {
+		ids = make([]int, 0, len(dbStructure.Following[userID]))
+		for id := range dbStructure.Following[userID] {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids, err
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"sort"
+	"time"
+)
+
+type HashtagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// TrendingHashtags counts the public chirps using each hashtag since the
+// given time and returns the most used ones. Only chirps an anonymous viewer
+// could see are counted, so held chirps and chirps by suspended, banned or
+// deleted authors don't trend.
+func (db *DB) TrendingHashtags(since time.Time, limit int) ([]HashtagCount, error) {
+	counts := make(map[string]int)
+	err := db.view(func(dbStructure *DBStructure) error {
+		for _, id := range dbStructure.chirpsInRange(dbStructure.chirpIDs, since, time.Time{}) {
+			chirp := dbStructure.Chirps[id]
+			if chirp.Visibility != VisibilityPublic || !dbStructure.canView(0, chirp) {
+				continue
+			}
+			for _, tag := range chirp.Hashtags() {
+				counts[tag]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	trending := make([]HashtagCount, 0, len(counts))
+	for tag, count := range counts {
+		trending = append(trending, HashtagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(trending, func(i, j int) bool {
+		if trending[i].Count != trending[j].Count {
+			return trending[i].Count > trending[j].Count
+		}
+		return trending[i].Tag < trending[j].Tag
+	})
+	limit = clampLimit(limit)
+	if len(trending) > limit {
+		trending = trending[:limit]
+	}
+	return trending, nil
+}
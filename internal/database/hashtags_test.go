@@ -0,0 +1,47 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrendingHashtags(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "suspended@example.com", "moderator@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	since := time.Now()
+	for _, chirp := range []Chirp{
+		{Body: "#go #news", AuthorId: 1},
+		{Body: "#go again", AuthorId: 1},
+		{Body: "#news for followers", AuthorId: 1, Visibility: VisibilityFollowers},
+		{Body: "#held #held", AuthorId: 1, Hold: &ChirpHold{Reason: "Links are reviewed"}},
+		{Body: "#spam #spam", AuthorId: 2},
+		{Body: "#spam", AuthorId: 2},
+	} {
+		if _, err := db.CreateChirp(chirp); err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	until := time.Now().Add(time.Hour)
+	if _, err := db.SetUserStatus(2, 3, UserSuspended, "spam", &until); err != nil {
+		t.Fatalf("Couldn't suspend user: %v", err)
+	}
+
+	trending, err := db.TrendingHashtags(since, 10)
+	if err != nil {
+		t.Fatalf("Couldn't get trending hashtags: %v", err)
+	}
+	want := []HashtagCount{{Tag: "go", Count: 2}, {Tag: "news", Count: 1}}
+	if !reflect.DeepEqual(trending, want) {
+		t.Errorf("Expected %v, got %v", want, trending)
+	}
+
+	trending, err = db.TrendingHashtags(time.Now(), 10)
+	if err != nil || len(trending) != 0 {
+		t.Errorf("Expected nothing to trend since now, got %v, %v", trending, err)
+	}
+}
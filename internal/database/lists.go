@@ -0,0 +1,262 @@
+package database
+
+import (
+	"strconv"
+	"time"
+)
+
+// List is a named group of accounts curated by its owner. Private lists,
+// their members and their timelines are only visible to the owner.
+type List struct {
+	ID          int       `json:"id"`
+	OwnerID     int       `json:"owner_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Private     bool      `json:"private"`
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type ListPage struct {
+	Lists      []List
+	NextCursor string
+}
+
+// canViewList reports whether viewerID may see list. Like chirps, lists of
+// owners who block the viewer, are restricted or are pending deletion are
+// only visible to their owner.
+func (dbStructure *DBStructure) canViewList(viewerID int, list List) bool {
+	if viewerID != 0 && viewerID == list.OwnerID {
+		return true
+	}
+	owner := dbStructure.Users[list.OwnerID]
+	if list.Private || dbStructure.blocked(viewerID, list.OwnerID) || owner.Restricted(time.Now()) || owner.PendingDeletion() {
+		return false
+	}
+	return true
+}
+
+// ownList returns one of ownerID's lists. Lists owned by other users return
+// ErrNotExist.
+func (dbStructure *DBStructure) ownList(id, ownerID int) (List, error) {
+	list, ok := dbStructure.Lists[id]
+	if !ok || list.OwnerID != ownerID {
+		return List{}, ErrNotExist
+	}
+	return list, nil
+}
+
+// CreateList stores a new list built from the owner, name, description and
+// privacy of list.
+func (db *DB) CreateList(list List) (List, error) {
+	var newList List
+	err := db.update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[list.OwnerID]; !ok {
+			return ErrNotExist
+		}
+		now := time.Now().UTC()
+		newList = List{
+			ID:          dbStructure.nextID("lists"),
+			OwnerID:     list.OwnerID,
+			Name:        list.Name,
+			Description: list.Description,
+			Private:     list.Private,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		dbStructure.Lists[newList.ID] = newList
+		return nil
+	})
+	if err != nil {
+		return List{}, err
+	}
+	return newList, nil
+}
+
+// GetList returns a list viewerID may see.
+func (db *DB) GetList(id, viewerID int) (List, error) {
+	var list List
+	err := db.view(func(dbStructure *DBStructure) error {
+		var ok bool
+		list, ok = dbStructure.Lists[id]
+		if !ok || !dbStructure.canViewList(viewerID, list) {
+			return ErrNotExist
+		}
+		return nil
+	})
+	return list, err
+}
+
+// UpdateList replaces the name, description and privacy of one of
+// list.OwnerID's lists.
+func (db *DB) UpdateList(list List) (List, error) {
+	var updated List
+	err := db.update(func(dbStructure *DBStructure) error {
+		var err error
+		updated, err = dbStructure.ownList(list.ID, list.OwnerID)
+		if err != nil {
+			return err
+		}
+		updated.Name = list.Name
+		updated.Description = list.Description
+		updated.Private = list.Private
+		updated.UpdatedAt = time.Now().UTC()
+		dbStructure.Lists[list.ID] = updated
+		return nil
+	})
+	if err != nil {
+		return List{}, err
+	}
+	return updated, nil
+}
+
+func (db *DB) DeleteList(id, ownerID int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		if _, err := dbStructure.ownList(id, ownerID); err != nil {
+			return err
+		}
+		delete(dbStructure.Lists, id)
+		dbStructure.deleteUserEntries("list_members", id)
+		return nil
+	})
+}
+
+// GetLists returns a page of ownerID's lists that viewerID may see, newest
+// first.
+func (db *DB) GetLists(ownerID, viewerID int, cursor string, limit int) (ListPage, error) {
+	limit = clampLimit(limit)
+	scope := "lists:" + strconv.Itoa(ownerID)
+	before := 0
+	if cursor != "" {
+		var err error
+		before, err = decodeCursor(scope, cursor)
+		if err != nil {
+			return ListPage{}, err
+		}
+	}
+
+	page := ListPage{Lists: make([]List, 0, limit)}
+	err := db.view(func(dbStructure *DBStructure) error {
+		start := dbStructure.Sequences["lists"]
+		if before != 0 {
+			start = before - 1
+		}
+		for id := start; id > 0; id-- {
+			list, ok := dbStructure.Lists[id]
+			if !ok || list.OwnerID != ownerID || !dbStructure.canViewList(viewerID, list) {
+				continue
+			}
+			if len(page.Lists) == limit {
+				page.NextCursor = encodeCursor(scope, page.Lists[limit-1].ID)
+				break
+			}
+			page.Lists = append(page.Lists, list)
+		}
+		return nil
+	})
+	return page, err
+}
+
+// AddListMember adds memberID to one of ownerID's lists. Adding a member
+// twice is a no-op, and adding someone when either blocks the other
+// returns ErrBlocked.
+func (db *DB) AddListMember(listID, ownerID, memberID int) (List, error) {
+	var list List
+	err := db.update(func(dbStructure *DBStructure) error {
+		var err error
+		list, err = dbStructure.ownList(listID, ownerID)
+		if err != nil {
+			return err
+		}
+		if _, ok := dbStructure.Users[memberID]; !ok {
+			return ErrNotExist
+		}
+		if dbStructure.blocked(ownerID, memberID) {
+			return ErrBlocked
+		}
+		if _, ok := dbStructure.ListMembers[listID][memberID]; ok {
+			return nil
+		}
+		dbStructure.setUserEntry("list_members", listID, memberID, time.Now().UTC())
+		list.MemberCount = len(dbStructure.ListMembers[listID])
+		dbStructure.Lists[listID] = list
+		return nil
+	})
+	if err != nil {
+		return List{}, err
+	}
+	return list, nil
+}
+
+// RemoveListMember undoes AddListMember. Removing someone who isn't a
+// member is a no-op.
+func (db *DB) RemoveListMember(listID, ownerID, memberID int) (List, error) {
+	var list List
+	err := db.update(func(dbStructure *DBStructure) error {
+		var err error
+		list, err = dbStructure.ownList(listID, ownerID)
+		if err != nil {
+			return err
+		}
+		list = dbStructure.removeListMember(list, memberID)
+		return nil
+	})
+	if err != nil {
+		return List{}, err
+	}
+	return list, nil
+}
+
+func (dbStructure *DBStructure) removeListMember(list List, memberID int) List {
+	if _, ok := dbStructure.ListMembers[list.ID][memberID]; !ok {
+		return list
+	}
+	dbStructure.deleteUserEntry("list_members", list.ID, memberID)
+	list.MemberCount = len(dbStructure.ListMembers[list.ID])
+	dbStructure.Lists[list.ID] = list
+	return list
+}
+
+// removeFromLists takes memberID out of every list ownerID owns.
+func (dbStructure *DBStructure) removeFromLists(ownerID, memberID int) {
+	for _, list := range dbStructure.Lists {
+		if list.OwnerID == ownerID {
+			dbStructure.removeListMember(list, memberID)
+		}
+	}
+}
+
+// GetListMembers lists the members of a list viewerID may see, most
+// recently added first.
+func (db *DB) GetListMembers(listID, viewerID int, cursor string, limit int) (UserPage, error) {
+	var page UserPage
+	err := db.view(func(dbStructure *DBStructure) error {
+		list, ok := dbStructure.Lists[listID]
+		if !ok || !dbStructure.canViewList(viewerID, list) {
+			return ErrNotExist
+		}
+		var err error
+		page, err = pageUserEntries(dbStructure.userEntryIndex["list_members"][listID], "list_members:"+strconv.Itoa(listID), cursor, limit)
+		return err
+	})
+	return page, err
+}
+
+// GetListMemberIDs returns the IDs of every member of a list viewerID may
+// see.
+func (db *DB) GetListMemberIDs(listID, viewerID int) ([]int, error) {
+	var ids []int
+	err := db.view(func(dbStructure *DBStructure) error {
+		list, ok := dbStructure.Lists[listID]
+		if !ok || !dbStructure.canViewList(viewerID, list) {
+			return ErrNotExist
+		}
+		ids = make([]int, 0, len(dbStructure.ListMembers[listID]))
+		for id := range dbStructure.ListMembers[listID] {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids, err
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBookmarks(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "reader@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	for _, body := range []string{"first", "second", "third"} {
+		if _, err := db.CreateChirp(Chirp{Body: body, AuthorId: 1}); err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	private, err := db.CreateChirp(Chirp{Body: "private", AuthorId: 1, Visibility: VisibilityMentioned})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	if err := db.AddBookmark(2, private.ID); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected bookmarking a chirp the user can't see to return ErrNotExist, got %v", err)
+	}
+	for _, id := range []int{2, 1, 3, 1} {
+		if err := db.AddBookmark(2, id); err != nil {
+			t.Fatalf("Couldn't bookmark chirp: %v", err)
+		}
+	}
+
+	page, err := db.GetBookmarks(2, "", 2)
+	if err != nil || len(page.Chirps) != 2 || page.Chirps[0].ID != 3 || page.Chirps[1].ID != 1 || page.NextCursor == "" {
+		t.Fatalf("Expected the most recent bookmarks first, got %+v, %v", page, err)
+	}
+	page, err = db.GetBookmarks(2, page.NextCursor, 2)
+	if err != nil || len(page.Chirps) != 1 || page.Chirps[0].ID != 2 || page.NextCursor != "" {
+		t.Errorf("Expected the oldest bookmark on the last page, got %+v, %v", page, err)
+	}
+
+	if err := db.RemoveBookmark(2, 3); err != nil {
+		t.Fatalf("Couldn't remove bookmark: %v", err)
+	}
+	if err := db.DeleteChirp(1); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+	bookmarked, err := db.GetBookmarkedChirps([]int{1, 2, 3}, 2)
+	if err != nil || bookmarked[1] || !bookmarked[2] || bookmarked[3] {
+		t.Errorf("Expected only chirp 2 to stay bookmarked, got %v, %v", bookmarked, err)
+	}
+}
+
+func TestBookmarksByChirp(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "first@example.com", "second@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	for _, body := range []string{"first", "second"} {
+		if _, err := db.CreateChirp(Chirp{Body: body, AuthorId: 1}); err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	for _, userID := range []int{3, 2} {
+		for _, chirpID := range []int{1, 2} {
+			if err := db.AddBookmark(userID, chirpID); err != nil {
+				t.Fatalf("Couldn't bookmark chirp: %v", err)
+			}
+		}
+	}
+
+	// Reopen the database so the index is rebuilt from the file.
+	db, err := NewDB(db.path)
+	if err != nil {
+		t.Fatalf("Couldn't reopen database: %v", err)
+	}
+	if err := db.DeleteChirp(1); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+	for _, userID := range []int{2, 3} {
+		bookmarked, err := db.GetBookmarkedChirps([]int{1, 2}, userID)
+		if err != nil || bookmarked[1] || !bookmarked[2] {
+			t.Errorf("Expected user %d to keep only chirp 2, got %v, %v", userID, bookmarked, err)
+		}
+	}
+	if err := db.RemoveBookmark(2, 2); err != nil {
+		t.Fatalf("Couldn't remove bookmark: %v", err)
+	}
+	err = db.view(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.bookmarksByChirp[1]; ok || !reflect.DeepEqual(dbStructure.bookmarksByChirp[2], []int{3}) {
+			t.Errorf("Expected only user 3 under chirp 2, got %v", dbStructure.bookmarksByChirp)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Couldn't view database: %v", err)
+	}
+}
+
+func TestLists(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"owner@example.com", "member@example.com", "other@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	public, err := db.CreateList(List{OwnerID: 1, Name: "friends"})
+	if err != nil {
+		t.Fatalf("Couldn't create list: %v", err)
+	}
+	private, err := db.CreateList(List{OwnerID: 1, Name: "secret", Private: true})
+	if err != nil {
+		t.Fatalf("Couldn't create list: %v", err)
+	}
+
+	if _, err := db.GetList(private.ID, 3); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected a private list to be hidden from other users, got %v", err)
+	}
+	if _, err := db.GetList(private.ID, 1); err != nil {
+		t.Errorf("Expected the owner to see their private list, got %v", err)
+	}
+	page, err := db.GetLists(1, 3, "", 10)
+	if err != nil || len(page.Lists) != 1 || page.Lists[0].ID != public.ID {
+		t.Errorf("Expected other users to only see public lists, got %+v, %v", page, err)
+	}
+	page, err = db.GetLists(1, 1, "", 1)
+	if err != nil || len(page.Lists) != 1 || page.Lists[0].ID != private.ID || page.NextCursor == "" {
+		t.Errorf("Expected the owner to see every list, newest first, got %+v, %v", page, err)
+	}
+
+	if _, err := db.AddListMember(public.ID, 3, 2); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected changing another user's list to return ErrNotExist, got %v", err)
+	}
+	for _, memberID := range []int{2, 3, 2} {
+		if _, err := db.AddListMember(public.ID, 1, memberID); err != nil {
+			t.Fatalf("Couldn't add list member: %v", err)
+		}
+	}
+	list, err := db.GetList(public.ID, 0)
+	if err != nil || list.MemberCount != 2 {
+		t.Errorf("Expected the list to have two members, got %+v, %v", list, err)
+	}
+
+	if err := db.Block(3, 1); err != nil {
+		t.Fatalf("Couldn't block user: %v", err)
+	}
+	if _, err := db.AddListMember(public.ID, 1, 3); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Expected adding a blocker to a list to return ErrBlocked, got %v", err)
+	}
+	if _, err := db.GetList(public.ID, 3); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected the list to be hidden from a blocker, got %v", err)
+	}
+	ids, err := db.GetListMemberIDs(public.ID, 1)
+	if err != nil || len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("Expected blocking to remove the member, got %v, %v", ids, err)
+	}
+
+	if err := db.DeleteList(public.ID, 2); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected deleting another user's list to return ErrNotExist, got %v", err)
+	}
+	if err := db.DeleteList(public.ID, 1); err != nil {
+		t.Fatalf("Couldn't delete list: %v", err)
+	}
+	if _, err := db.GetListMembers(public.ID, 1, "", 10); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected the deleted list to be gone, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package database
+
+import "time"
+
+type MediaKind string
+
+const (
+	MediaKindAvatar     MediaKind = "avatar"
+	MediaKindAttachment MediaKind = "attachment"
+)
+
+// Media describes an uploaded file. The file itself lives in a blob store
+// under Key, which is derived from its content.
+type Media struct {
+	ID          int       `json:"id"`
+	OwnerID     int       `json:"owner_id"`
+	Kind        MediaKind `json:"kind"`
+	Key         string    `json:"key"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (db *DB) CreateMedia(media Media) (Media, error) {
+	err := db.update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[media.OwnerID]; !ok {
+			return ErrNotExist
+		}
+		media.ID = dbStructure.nextID("media")
+		media.CreatedAt = time.Now().UTC()
+		dbStructure.Media[media.ID] = media
+		return nil
+	})
+	if err != nil {
+		return Media{}, err
+	}
+	return media, nil
+}
+
+// GetMedia looks up several media records at once. Missing records are
+// left out of the result.
+func (db *DB) GetMedia(ids []int) (map[int]Media, error) {
+	media := make(map[int]Media, len(ids))
+	err := db.view(func(dbStructure *DBStructure) error {
+		for _, id := range ids {
+			if m, ok := dbStructure.Media[id]; ok {
+				media[id] = m
+			}
+		}
+		return nil
+	})
+	return media, err
+}
+
+// SetAvatar points the user's avatar at an uploaded blob. The media records
+// of the avatar it replaces are deleted, and the replaced blob key is
+// returned when nothing refers to it anymore so it can be removed from the
+// blob store.
+func (db *DB) SetAvatar(userID int, key string) (User, string, error) {
+	var user User
+	var replacedKey string
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.Users[userID]
+		if !ok {
+			return ErrNotExist
+		}
+		oldKey := user.Avatar
+		user.Avatar = key
+		user.UpdatedAt = time.Now().UTC()
+		dbStructure.Users[userID] = user
+		if oldKey == "" || oldKey == key {
+			return nil
+		}
+
+		for id, media := range dbStructure.Media {
+			if media.OwnerID == userID && media.Kind == MediaKindAvatar && media.Key == oldKey {
+				delete(dbStructure.Media, id)
+			}
+		}
+		if !dbStructure.blobInUse(oldKey) {
+			replacedKey = oldKey
+		}
+		return nil
+	})
+	if err != nil {
+		return User{}, "", err
+	}
+	return user, replacedKey, nil
+}
+
+// blobInUse reports whether any media record or avatar refers to key. Blobs
+// are named after their content, so several records can share one.
+func (dbStructure *DBStructure) blobInUse(key string) bool {
+	for _, media := range dbStructure.Media {
+		if media.Key == key {
+			return true
+		}
+	}
+	for _, user := range dbStructure.Users {
+		if user.Avatar == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package database
+
+import "testing"
+
+func TestSetAvatarReplacesBlob(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"first@example.com", "second@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	setAvatar := func(userID int, key string) string {
+		t.Helper()
+		if _, err := db.CreateMedia(Media{OwnerID: userID, Kind: MediaKindAvatar, Key: key}); err != nil {
+			t.Fatalf("Couldn't create media: %v", err)
+		}
+		user, replacedKey, err := db.SetAvatar(userID, key)
+		if err != nil || user.Avatar != key {
+			t.Fatalf("Couldn't set avatar: %+v, %v", user, err)
+		}
+		return replacedKey
+	}
+
+	if replacedKey := setAvatar(1, "shared.png"); replacedKey != "" {
+		t.Errorf("Expected a first avatar to replace nothing, got %q", replacedKey)
+	}
+	if replacedKey := setAvatar(1, "shared.png"); replacedKey != "" {
+		t.Errorf("Expected setting the same avatar to keep its blob, got %q", replacedKey)
+	}
+	setAvatar(2, "shared.png")
+	if replacedKey := setAvatar(1, "mine.png"); replacedKey != "" {
+		t.Errorf("Expected an avatar someone else uses to keep its blob, got %q", replacedKey)
+	}
+	if replacedKey := setAvatar(2, "theirs.png"); replacedKey != "shared.png" {
+		t.Errorf("Expected the last use of a blob to release it, got %q", replacedKey)
+	}
+	media, err := db.GetMedia([]int{1, 2, 3})
+	if err != nil || len(media) != 0 {
+		t.Errorf("Expected the replaced avatars' media to be deleted, got %+v, %v", media, err)
+	}
+}
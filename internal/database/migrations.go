@@ -0,0 +1,95 @@
+package database
+
+import "time"
+
+// migrations upgrade a loaded database file in place. A database at schema
+// version N has had the first N migrations applied, so new migrations must
+// only ever be appended.
+var migrations = []func(dbStructure *DBStructure, now time.Time){
+	backfillTimestamps,
+	initSequences,
+	initThreads,
+	backfillEntities,
+	backfillVisibility,
+}
+
+// migrate applies any migrations dbStructure is missing and reports
+// whether it changed.
+func migrate(dbStructure *DBStructure) bool {
+	if dbStructure.SchemaVersion >= len(migrations) {
+		return false
+	}
+
+	now := time.Now().UTC()
+	for _, migration := range migrations[dbStructure.SchemaVersion:] {
+		migration(dbStructure, now)
+	}
+	dbStructure.SchemaVersion = len(migrations)
+	return true
+}
+
+// backfillTimestamps gives records created before timestamps existed the
+// time of the migration, since their real creation time is unknown.
+func backfillTimestamps(dbStructure *DBStructure, now time.Time) {
+	for id, chirp := range dbStructure.Chirps {
+		if chirp.CreatedAt.IsZero() {
+			chirp.CreatedAt = now
+		}
+		if chirp.UpdatedAt.IsZero() {
+			chirp.UpdatedAt = chirp.CreatedAt
+		}
+		dbStructure.Chirps[id] = chirp
+	}
+	for id, user := range dbStructure.Users {
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = now
+		}
+		if user.UpdatedAt.IsZero() {
+			user.UpdatedAt = user.CreatedAt
+		}
+		dbStructure.Users[id] = user
+	}
+}
+
+// initSequences seeds the ID sequences from the highest IDs in use.
+func initSequences(dbStructure *DBStructure, now time.Time) {
+	for id := range dbStructure.Chirps {
+		dbStructure.Sequences["chirps"] = max(dbStructure.Sequences["chirps"], id)
+	}
+	for id := range dbStructure.Users {
+		dbStructure.Sequences["users"] = max(dbStructure.Sequences["users"], id)
+	}
+}
+
+// initThreads makes every existing chirp the root of its own thread.
+func initThreads(dbStructure *DBStructure, now time.Time) {
+	for id, chirp := range dbStructure.Chirps {
+		if chirp.ThreadID == 0 {
+			chirp.ThreadID = chirp.ID
+			dbStructure.Chirps[id] = chirp
+		}
+	}
+}
+
+// backfillEntities parses the entities of chirps posted before they were
+// stored.
+func backfillEntities(dbStructure *DBStructure, now time.Time) {
+	dbStructure.buildIndexes()
+	for id, chirp := range dbStructure.Chirps {
+		if !chirp.Deleted {
+			chirp.Entities = dbStructure.parseChirpEntities(chirp.AuthorId, chirp.Body)
+			dbStructure.Chirps[id] = chirp
+		}
+	}
+}
+
+// backfillVisibility makes chirps posted before visibility levels existed
+// public, which is how they were served.
+func backfillVisibility(dbStructure *DBStructure, now time.Time) {
+	for id, chirp := range dbStructure.Chirps {
+		if chirp.Visibility == "" && !chirp.Deleted {
+			chirp.Visibility = VisibilityPublic
+			dbStructure.Chirps[id] = chirp
+		}
+	}
+}
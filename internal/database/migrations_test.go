@@ -0,0 +1,69 @@
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// legacyDatabase is a database file written before timestamps, sequences,
+// threads, entities and visibility existed.
+const legacyDatabase = `{
+	"chirps": {
+		"1": {"id": 1, "body": "hello #world", "author_id": 1},
+		"2": {"id": 2, "body": "again", "author_id": 1}
+	},
+	"users": {
+		"1": {"id": 1, "email": "legacy@example.com", "password": "hash", "is_chirpy_red": false}
+	},
+	"revoked_tokens": {}
+}`
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	if err := os.WriteFile(path, []byte(legacyDatabase), 0600); err != nil {
+		t.Fatalf("Couldn't write database: %v", err)
+	}
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("Couldn't open database: %v", err)
+	}
+
+	chirps, err := db.GetChirps()
+	if err != nil || len(chirps) != 2 {
+		t.Fatalf("Expected the legacy chirps, got %+v, %v", chirps, err)
+	}
+	for _, chirp := range chirps {
+		if chirp.CreatedAt.IsZero() || !chirp.UpdatedAt.Equal(chirp.CreatedAt) {
+			t.Errorf("Expected chirp %d to be given timestamps, got %v and %v", chirp.ID, chirp.CreatedAt, chirp.UpdatedAt)
+		}
+		if chirp.ThreadID != chirp.ID || chirp.Visibility != VisibilityPublic {
+			t.Errorf("Expected chirp %d to be a public thread root, got %+v", chirp.ID, chirp)
+		}
+	}
+	user, err := db.GetUser(1)
+	if err != nil || user.CreatedAt.IsZero() || !user.UpdatedAt.Equal(user.CreatedAt) {
+		t.Errorf("Expected the user to be given timestamps, got %+v, %v", user, err)
+	}
+	page, err := db.QueryChirps(ChirpQuery{Hashtag: "world"})
+	if err != nil || len(page.Chirps) != 1 || page.Chirps[0].ID != 1 {
+		t.Errorf("Expected the legacy chirp's hashtags to be indexed, got %+v, %v", page, err)
+	}
+
+	chirp, err := db.CreateChirp(Chirp{Body: "new", AuthorId: 1})
+	if err != nil || chirp.ID != 3 {
+		t.Errorf("Expected new chirps to follow the legacy IDs, got %+v, %v", chirp, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Couldn't read database: %v", err)
+	}
+	migrated := struct {
+		SchemaVersion int `json:"schema_version"`
+	}{}
+	if err := json.Unmarshal(data, &migrated); err != nil || migrated.SchemaVersion != len(migrations) {
+		t.Errorf("Expected the migrated schema version to be saved, got %d, %v", migrated.SchemaVersion, err)
+	}
+}
@@ -0,0 +1,60 @@
+package database
+
+import "time"
+
+// ChirpHold keeps a chirp from everyone but its author until a moderator
+// releases it.
+type ChirpHold struct {
+	Reason string    `json:"reason"`
+	HeldAt time.Time `json:"held_at"`
+}
+
+// GetHeldChirps returns a page of the chirps waiting for review, oldest
+// first.
+func (db *DB) GetHeldChirps(cursor string, limit int) (ChirpPage, error) {
+	limit = clampLimit(limit)
+	after := 0
+	if cursor != "" {
+		var err error
+		after, err = decodeCursor("held", cursor)
+		if err != nil {
+			return ChirpPage{}, err
+		}
+	}
+
+	page := ChirpPage{Chirps: make([]Chirp, 0, limit)}
+	err := db.view(func(dbStructure *DBStructure) error {
+		next := dbStructure.walkChirpIDs(after, false)
+		for id, ok := next(); ok; id, ok = next() {
+			chirp, exists := dbStructure.Chirps[id]
+			if !exists || chirp.Deleted || chirp.Hold == nil {
+				continue
+			}
+			if len(page.Chirps) == limit {
+				page.NextCursor = encodeCursor("held", page.Chirps[limit-1].ID)
+				break
+			}
+			page.Chirps = append(page.Chirps, chirp)
+		}
+		return nil
+	})
+	return page, err
+}
+
+// ReleaseChirp lifts the hold on a chirp, sending the notifications that
+// were held back with it.
+func (db *DB) ReleaseChirp(id int) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStructure.Chirps[id]
+		if !ok || chirp.Deleted || chirp.Hold == nil {
+			return ErrNotExist
+		}
+		chirp.Hold = nil
+		dbStructure.Chirps[id] = chirp
+		dbStructure.notifyChirp(chirp)
+		return nil
+	})
+	return chirp, err
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHeldChirps(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "reader@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	if _, err := db.UpdateProfile(2, Profile{Username: "reader"}); err != nil {
+		t.Fatalf("Couldn't update profile: %v", err)
+	}
+
+	chirp, err := db.CreateChirp(Chirp{Body: "look @reader", AuthorId: 1, Hold: &ChirpHold{Reason: "Links are reviewed"}})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	if chirp.Hold == nil || chirp.Hold.HeldAt.IsZero() {
+		t.Fatalf("Expected the chirp to be held, got %+v", chirp.Hold)
+	}
+	if _, err := db.GetChirpForViewer(chirp.ID, 2); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist reading a held chirp, got %v", err)
+	}
+	if _, err := db.GetChirpForViewer(chirp.ID, 1); err != nil {
+		t.Errorf("Expected the author to see their held chirp, got %v", err)
+	}
+	notifications, err := db.GetNotifications(2, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't get notifications: %v", err)
+	}
+	if len(notifications.Groups) != 0 {
+		t.Errorf("Expected mentions in held chirps to wait, got %+v", notifications.Groups)
+	}
+
+	held, err := db.GetHeldChirps("", 0)
+	if err != nil {
+		t.Fatalf("Couldn't get held chirps: %v", err)
+	}
+	if len(held.Chirps) != 1 || held.Chirps[0].ID != chirp.ID {
+		t.Fatalf("Expected the held chirp in the queue, got %+v", held.Chirps)
+	}
+
+	if _, err := db.ReleaseChirp(chirp.ID); err != nil {
+		t.Fatalf("Couldn't release chirp: %v", err)
+	}
+	if _, err := db.ReleaseChirp(chirp.ID); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist releasing twice, got %v", err)
+	}
+	if _, err := db.GetChirpForViewer(chirp.ID, 2); err != nil {
+		t.Errorf("Expected the released chirp to be visible, got %v", err)
+	}
+	notifications, err = db.GetNotifications(2, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't get notifications: %v", err)
+	}
+	if len(notifications.Groups) != 1 || notifications.Groups[0].Type != NotificationMention {
+		t.Errorf("Expected the mention once released, got %+v", notifications.Groups)
+	}
+}
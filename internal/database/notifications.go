@@ -0,0 +1,428 @@
+package database
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+type NotificationType string
+
+const (
+	NotificationMention NotificationType = "mention"
+	NotificationReply   NotificationType = "reply"
+	NotificationFollow  NotificationType = "follow"
+	NotificationLike    NotificationType = "like"
+	NotificationRechirp NotificationType = "rechirp"
+	NotificationQuote   NotificationType = "quote"
+	// NotificationReport tells a reporter that their report was resolved.
+	NotificationReport NotificationType = "report"
+	// NotificationWarning tells a user a moderator warned them. It isn't in
+	// NotificationTypes, so it can't be turned off.
+	NotificationWarning NotificationType = "warning"
+)
+
+// NotificationTypes lists every notification type in a stable order.
+var NotificationTypes = []NotificationType{
+	NotificationMention,
+	NotificationReply,
+	NotificationFollow,
+	NotificationLike,
+	NotificationRechirp,
+	NotificationQuote,
+	NotificationReport,
+}
+
+func (t NotificationType) Valid() bool {
+	for _, notificationType := range NotificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification tells UserID that ActorID did something. ChirpID is the chirp
+// it is about: the liked or rechirped chirp, or the reply, quote or mention
+// itself.
+// Notifications from moderators have no actor, and report notifications set
+// ReportID instead of ChirpID.
+type Notification struct {
+	ID        int              `json:"id"`
+	UserID    int              `json:"user_id"`
+	Type      NotificationType `json:"type"`
+	ActorID   int              `json:"actor_id"`
+	ChirpID   int              `json:"chirp_id,omitempty"`
+	ReportID  int              `json:"report_id,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	ReadAt    *time.Time       `json:"read_at,omitempty"`
+}
+
+// NotificationPreferences says which notification types a user receives.
+// Types missing from the map are enabled.
+type NotificationPreferences map[NotificationType]bool
+
+// NotificationGroup folds notifications about the same thing, such as every
+// like of one chirp, into a single entry. ID is the newest notification in
+// the group and ActorIDs holds up to maxGroupActors of the newest actors.
+type NotificationGroup struct {
+	ID         int
+	Type       NotificationType
+	ChirpID    int
+	ReportID   int
+	ActorIDs   []int
+	ActorCount int
+	Read       bool
+	CreatedAt  time.Time
+}
+
+type NotificationPage struct {
+	Groups      []NotificationGroup
+	NextCursor  string
+	UnreadCount int
+}
+
+const maxGroupActors = 3
+
+// groupKey identifies the group a notification belongs to. Likes and
+// rechirps group by chirp and follows by the UTC day they happened on,
+// while every mention, reply and quote stands on its own.
+func (n Notification) groupKey() string {
+	switch n.Type {
+	case NotificationLike, NotificationRechirp:
+		return string(n.Type) + ":" + strconv.Itoa(n.ChirpID)
+	case NotificationFollow:
+		return string(n.Type) + ":" + n.CreatedAt.UTC().Format(time.DateOnly)
+	}
+	return "notification:" + strconv.Itoa(n.ID)
+}
+
+// notify records a notification unless the actor is the recipient, either
+// blocks the other, the recipient can't see the chirp or the recipient
+// turned that type off.
+func (dbStructure *DBStructure) notify(userID int, notificationType NotificationType, actorID, chirpID int) {
+	if userID == actorID || dbStructure.blocked(userID, actorID) {
+		return
+	}
+	if _, ok := dbStructure.Users[userID]; !ok {
+		return
+	}
+	if chirp, ok := dbStructure.Chirps[chirpID]; ok && !dbStructure.canView(userID, chirp) {
+		return
+	}
+	if enabled, ok := dbStructure.NotificationPreferences[userID][notificationType]; ok && !enabled {
+		return
+	}
+	dbStructure.addNotification(Notification{
+		UserID:  userID,
+		Type:    notificationType,
+		ActorID: actorID,
+		ChirpID: chirpID,
+	})
+}
+
+// notifyReportResolved tells the reporter their report was resolved, unless
+// they turned report notifications off.
+func (dbStructure *DBStructure) notifyReportResolved(report Report) {
+	if _, ok := dbStructure.Users[report.ReporterID]; !ok {
+		return
+	}
+	if enabled, ok := dbStructure.NotificationPreferences[report.ReporterID][NotificationReport]; ok && !enabled {
+		return
+	}
+	dbStructure.addNotification(Notification{
+		UserID:   report.ReporterID,
+		Type:     NotificationReport,
+		ReportID: report.ID,
+	})
+}
+
+func (dbStructure *DBStructure) addNotification(notification Notification) {
+	notification.ID = dbStructure.nextID("notifications")
+	notification.CreatedAt = time.Now().UTC()
+	dbStructure.Notifications[notification.ID] = notification
+	dbStructure.indexNotification(notification)
+}
+
+// groupIndexKey keys notificationsByGroup. Groups belong to one recipient.
+func (n Notification) groupIndexKey() string {
+	return strconv.Itoa(n.UserID) + "/" + n.groupKey()
+}
+
+func (dbStructure *DBStructure) indexNotification(notification Notification) {
+	addToIndex(dbStructure.notificationsByUser, notification.UserID, notification.ID)
+	addToIndex(dbStructure.notificationsByGroup, notification.groupIndexKey(), notification.ID)
+	if notification.ReadAt == nil {
+		addToIndex(dbStructure.unreadNotificationsByUser, notification.UserID, notification.ID)
+	}
+}
+
+func (dbStructure *DBStructure) unindexNotification(notification Notification) {
+	removeFromIndex(dbStructure.notificationsByUser, notification.UserID, notification.ID)
+	removeFromIndex(dbStructure.notificationsByGroup, notification.groupIndexKey(), notification.ID)
+	removeFromIndex(dbStructure.unreadNotificationsByUser, notification.UserID, notification.ID)
+}
+
+// notifyChirp notifies the parent's author of a new reply, the quoted
+// chirp's author of a new quote and the users mentioned in it.
+func (dbStructure *DBStructure) notifyChirp(chirp Chirp) {
+	if parent, ok := dbStructure.Chirps[chirp.InReplyTo]; ok {
+		dbStructure.notify(parent.AuthorId, NotificationReply, chirp.AuthorId, chirp.ID)
+	}
+	if quoted, ok := dbStructure.Chirps[chirp.QuoteOf]; ok && !quoted.Deleted {
+		dbStructure.notify(quoted.AuthorId, NotificationQuote, chirp.AuthorId, chirp.ID)
+	}
+	dbStructure.notifyMentionChanges(Chirp{}, chirp)
+}
+
+// notifyMentionChanges notifies users mentioned in after but not in before,
+// and withdraws the notifications of users no longer mentioned. The author of
+// the chirp being replied to already has a reply notification and is left
+// out.
+func (dbStructure *DBStructure) notifyMentionChanges(before, after Chirp) {
+	skip := make(map[int]bool)
+	if parent, ok := dbStructure.Chirps[after.InReplyTo]; ok {
+		skip[parent.AuthorId] = true
+	}
+	mentioned := make(map[int]bool)
+	for _, userID := range after.mentionedUserIDs() {
+		mentioned[userID] = true
+	}
+	for _, userID := range before.mentionedUserIDs() {
+		if mentioned[userID] {
+			skip[userID] = true
+			continue
+		}
+		dbStructure.unnotify(userID, func(n Notification) bool {
+			return n.Type == NotificationMention && n.ChirpID == after.ID
+		})
+	}
+	for _, userID := range after.mentionedUserIDs() {
+		if skip[userID] {
+			continue
+		}
+		skip[userID] = true
+		dbStructure.notify(userID, NotificationMention, after.AuthorId, after.ID)
+	}
+}
+
+// unnotify deletes userID's notifications that match.
+func (dbStructure *DBStructure) unnotify(userID int, match func(Notification) bool) {
+	for _, id := range append([]int(nil), dbStructure.notificationsByUser[userID]...) {
+		if notification := dbStructure.Notifications[id]; match(notification) {
+			delete(dbStructure.Notifications, id)
+			dbStructure.unindexNotification(notification)
+		}
+	}
+}
+
+// unnotifyChirp deletes every notification about chirp.
+func (dbStructure *DBStructure) unnotifyChirp(chirp Chirp) {
+	recipients := chirp.mentionedUserIDs()
+	recipients = append(recipients, chirp.AuthorId)
+	if parent, ok := dbStructure.Chirps[chirp.InReplyTo]; ok {
+		recipients = append(recipients, parent.AuthorId)
+	}
+	if quoted, ok := dbStructure.Chirps[chirp.QuoteOf]; ok {
+		recipients = append(recipients, quoted.AuthorId)
+	}
+	for _, userID := range recipients {
+		dbStructure.unnotify(userID, func(n Notification) bool {
+			return n.ChirpID == chirp.ID
+		})
+	}
+}
+
+func (chirp Chirp) mentionedUserIDs() []int {
+	userIDs := make([]int, 0)
+	for _, entity := range chirp.Entities {
+		if entity.Type == EntityMention && entity.UserID != 0 {
+			userIDs = append(userIDs, entity.UserID)
+		}
+	}
+	return userIDs
+}
+
+// GetNotifications returns a page of the user's notification groups, newest
+// first, along with how many groups are unread. Groups are ordered by their
+// newest notification, so a page starts from the cursor and only builds the
+// groups whose newest notification falls on it.
+func (db *DB) GetNotifications(userID int, cursor string, limit int) (NotificationPage, error) {
+	limit = clampLimit(limit)
+	scope := "notifications:" + strconv.Itoa(userID)
+	before := 0
+	if cursor != "" {
+		var err error
+		before, err = decodeCursor(scope, cursor)
+		if err != nil {
+			return NotificationPage{}, err
+		}
+	}
+
+	page := NotificationPage{Groups: make([]NotificationGroup, 0, limit)}
+	err := db.view(func(dbStructure *DBStructure) error {
+		page.UnreadCount = dbStructure.unreadNotificationGroups(userID)
+		ids := dbStructure.notificationsByUser[userID]
+		end := len(ids)
+		if before != 0 {
+			end = sort.SearchInts(ids, before)
+		}
+		for i := end - 1; i >= 0; i-- {
+			group, ok := dbStructure.notificationGroup(dbStructure.Notifications[ids[i]])
+			if !ok {
+				continue
+			}
+			if len(page.Groups) == limit {
+				page.NextCursor = encodeCursor(scope, page.Groups[limit-1].ID)
+				break
+			}
+			page.Groups = append(page.Groups, group)
+		}
+		return nil
+	})
+	return page, err
+}
+
+// notificationHidden reports whether notification comes from a user the
+// recipient mutes or blocks. Hidden notifications are kept, so they come
+// back if the user is unmuted.
+func (dbStructure *DBStructure) notificationHidden(notification Notification) bool {
+	return dbStructure.muted(notification.UserID, notification.ActorID) || dbStructure.blocked(notification.UserID, notification.ActorID)
+}
+
+// notificationGroup builds the group headed by notification. It reports
+// false if notification is hidden or isn't the newest visible notification
+// in its group.
+func (dbStructure *DBStructure) notificationGroup(notification Notification) (NotificationGroup, bool) {
+	if dbStructure.notificationHidden(notification) {
+		return NotificationGroup{}, false
+	}
+	ids := dbStructure.notificationsByGroup[notification.groupIndexKey()]
+	group := NotificationGroup{}
+	seenActors := make(map[int]bool)
+	for i := len(ids) - 1; i >= 0; i-- {
+		member := dbStructure.Notifications[ids[i]]
+		if dbStructure.notificationHidden(member) {
+			continue
+		}
+		if group.ID == 0 {
+			if member.ID != notification.ID {
+				return NotificationGroup{}, false
+			}
+			group = NotificationGroup{
+				ID:        member.ID,
+				Type:      member.Type,
+				ChirpID:   member.ChirpID,
+				ReportID:  member.ReportID,
+				ActorIDs:  make([]int, 0, maxGroupActors),
+				Read:      true,
+				CreatedAt: member.CreatedAt,
+			}
+		}
+		if member.ReadAt == nil {
+			group.Read = false
+		}
+		if member.ActorID == 0 || seenActors[member.ActorID] {
+			continue
+		}
+		seenActors[member.ActorID] = true
+		group.ActorCount++
+		if len(group.ActorIDs) < maxGroupActors {
+			group.ActorIDs = append(group.ActorIDs, member.ActorID)
+		}
+	}
+	return group, group.ID != 0
+}
+
+// unreadNotificationGroups counts the user's groups with an unread visible
+// notification.
+func (dbStructure *DBStructure) unreadNotificationGroups(userID int) int {
+	keys := make(map[string]bool)
+	for _, id := range dbStructure.unreadNotificationsByUser[userID] {
+		notification := dbStructure.Notifications[id]
+		if !dbStructure.notificationHidden(notification) {
+			keys[notification.groupKey()] = true
+		}
+	}
+	return len(keys)
+}
+
+// MarkNotificationRead marks every notification in the group whose newest
+// notification is id as read.
+func (db *DB) MarkNotificationRead(userID, id int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		notification, ok := dbStructure.Notifications[id]
+		if !ok || notification.UserID != userID {
+			return ErrNotExist
+		}
+		key := notification.groupIndexKey()
+		dbStructure.markNotificationsRead(userID, func(n Notification) bool {
+			return n.groupIndexKey() == key
+		})
+		return nil
+	})
+}
+
+func (db *DB) MarkAllNotificationsRead(userID int) error {
+	return db.update(func(dbStructure *DBStructure) error {
+		dbStructure.markNotificationsRead(userID, func(Notification) bool {
+			return true
+		})
+		return nil
+	})
+}
+
+func (dbStructure *DBStructure) markNotificationsRead(userID int, match func(Notification) bool) {
+	now := time.Now().UTC()
+	for _, id := range append([]int(nil), dbStructure.unreadNotificationsByUser[userID]...) {
+		notification := dbStructure.Notifications[id]
+		if !match(notification) {
+			continue
+		}
+		notification.ReadAt = &now
+		dbStructure.Notifications[id] = notification
+		removeFromIndex(dbStructure.unreadNotificationsByUser, userID, id)
+	}
+}
+
+// GetNotificationPreferences returns the user's preferences with every type
+// filled in.
+func (db *DB) GetNotificationPreferences(userID int) (NotificationPreferences, error) {
+	var preferences NotificationPreferences
+	err := db.view(func(dbStructure *DBStructure) error {
+		preferences = dbStructure.notificationPreferences(userID)
+		return nil
+	})
+	return preferences, err
+}
+
+// UpdateNotificationPreferences changes the types present in changes and
+// leaves the rest alone.
+func (db *DB) UpdateNotificationPreferences(userID int, changes NotificationPreferences) (NotificationPreferences, error) {
+	var preferences NotificationPreferences
+	err := db.update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[userID]; !ok {
+			return ErrNotExist
+		}
+		preferences = dbStructure.notificationPreferences(userID)
+		for notificationType, enabled := range changes {
+			preferences[notificationType] = enabled
+		}
+		dbStructure.NotificationPreferences[userID] = preferences
+		preferences = dbStructure.notificationPreferences(userID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+func (dbStructure *DBStructure) notificationPreferences(userID int) NotificationPreferences {
+	preferences := make(NotificationPreferences, len(NotificationTypes))
+	for _, notificationType := range NotificationTypes {
+		enabled, ok := dbStructure.NotificationPreferences[userID][notificationType]
+		preferences[notificationType] = enabled || !ok
+	}
+	return preferences
+}
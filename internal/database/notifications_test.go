@@ -0,0 +1,193 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestNotifications(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "a@example.com", "b@example.com", "c@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	if _, err := db.UpdateProfile(1, Profile{Username: "author"}); err != nil {
+		t.Fatalf("Couldn't update profile: %v", err)
+	}
+
+	chirp, err := db.CreateChirp(Chirp{Body: "hello", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	for _, userID := range []int{1, 2, 3, 4, 2} {
+		if _, err := db.AddReaction(chirp.ID, userID, ReactionLike); err != nil {
+			t.Fatalf("Couldn't like chirp: %v", err)
+		}
+	}
+	if _, err := db.CreateChirp(Chirp{Body: "@author hi", AuthorId: 2, InReplyTo: chirp.ID}); err != nil {
+		t.Fatalf("Couldn't reply: %v", err)
+	}
+	if _, err := db.CreateChirp(Chirp{Body: "hey @author", AuthorId: 3}); err != nil {
+		t.Fatalf("Couldn't mention: %v", err)
+	}
+	if _, err := db.UpdateNotificationPreferences(1, NotificationPreferences{NotificationFollow: false}); err != nil {
+		t.Fatalf("Couldn't update preferences: %v", err)
+	}
+	if err := db.Follow(2, 1); err != nil {
+		t.Fatalf("Couldn't follow: %v", err)
+	}
+
+	page, err := db.GetNotifications(1, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't get notifications: %v", err)
+	}
+	types := make([]NotificationType, 0)
+	for _, group := range page.Groups {
+		types = append(types, group.Type)
+	}
+	wantTypes := []NotificationType{NotificationMention, NotificationReply, NotificationLike}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Fatalf("Expected groups %v, got %v", wantTypes, types)
+	}
+	likes := page.Groups[2]
+	if likes.ActorCount != 3 || !reflect.DeepEqual(likes.ActorIDs, []int{4, 3, 2}) {
+		t.Errorf("Expected likes from 4, 3 and 2, got %d %v", likes.ActorCount, likes.ActorIDs)
+	}
+	if page.UnreadCount != 3 {
+		t.Errorf("Expected 3 unread groups, got %d", page.UnreadCount)
+	}
+
+	if err := db.MarkNotificationRead(1, likes.ID); err != nil {
+		t.Fatalf("Couldn't mark read: %v", err)
+	}
+	if err := db.MarkNotificationRead(2, page.Groups[0].ID); err != ErrNotExist {
+		t.Errorf("Expected ErrNotExist marking another user's notification, got %v", err)
+	}
+	if _, err := db.RemoveReaction(chirp.ID, 4, ReactionLike); err != nil {
+		t.Fatalf("Couldn't unlike chirp: %v", err)
+	}
+	page, err = db.GetNotifications(1, "", 2)
+	if err != nil {
+		t.Fatalf("Couldn't get notifications: %v", err)
+	}
+	if page.UnreadCount != 2 || page.NextCursor == "" {
+		t.Errorf("Expected 2 unread groups and a next page, got %d %q", page.UnreadCount, page.NextCursor)
+	}
+	page, err = db.GetNotifications(1, page.NextCursor, 2)
+	if err != nil {
+		t.Fatalf("Couldn't get notifications: %v", err)
+	}
+	if len(page.Groups) != 1 || !page.Groups[0].Read || page.Groups[0].ActorCount != 2 {
+		t.Errorf("Expected the read like group from 2 users, got %+v", page.Groups)
+	}
+
+	if err := db.MarkAllNotificationsRead(1); err != nil {
+		t.Fatalf("Couldn't mark all read: %v", err)
+	}
+	if err := db.DeleteChirp(chirp.ID); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+	page, err = db.GetNotifications(1, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't get notifications: %v", err)
+	}
+	if page.UnreadCount != 0 || len(page.Groups) != 2 {
+		t.Errorf("Expected the like group to go with its chirp, got %+v", page)
+	}
+}
+
+func TestNotificationGroupsAcrossPages(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < 5; i++ {
+		if _, err := db.CreateUser(fmt.Sprintf("user%d@example.com", i), "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	first, err := db.CreateChirp(Chirp{Body: "first", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	second, err := db.CreateChirp(Chirp{Body: "second", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	// Likes on the two chirps interleave, so each group has notifications
+	// on both pages.
+	for _, like := range []struct{ chirpID, userID int }{
+		{first.ID, 2}, {second.ID, 2}, {first.ID, 3}, {second.ID, 3}, {first.ID, 4}, {second.ID, 5},
+	} {
+		if _, err := db.AddReaction(like.chirpID, like.userID, ReactionLike); err != nil {
+			t.Fatalf("Couldn't like chirp: %v", err)
+		}
+	}
+
+	page, err := db.GetNotifications(1, "", 1)
+	if err != nil || len(page.Groups) != 1 || page.NextCursor == "" || page.UnreadCount != 2 {
+		t.Fatalf("Expected one of two unread groups and a next page, got %+v, %v", page, err)
+	}
+	if group := page.Groups[0]; group.ChirpID != second.ID || group.ActorCount != 3 || !reflect.DeepEqual(group.ActorIDs, []int{5, 3, 2}) {
+		t.Errorf("Expected the newest group to hold all of its likes, got %+v", group)
+	}
+	page, err = db.GetNotifications(1, page.NextCursor, 1)
+	if err != nil || len(page.Groups) != 1 || page.NextCursor != "" {
+		t.Fatalf("Expected the last group on the second page, got %+v, %v", page, err)
+	}
+	if group := page.Groups[0]; group.ChirpID != first.ID || group.ActorCount != 3 {
+		t.Errorf("Expected the older group to hold all of its likes, got %+v", group)
+	}
+
+	if err := db.Mute(1, 5); err != nil {
+		t.Fatalf("Couldn't mute: %v", err)
+	}
+	page, err = db.GetNotifications(1, "", 0)
+	if err != nil || len(page.Groups) != 2 {
+		t.Fatalf("Expected both groups, got %+v, %v", page, err)
+	}
+	if group := page.Groups[0]; group.ChirpID != first.ID || !reflect.DeepEqual(page.Groups[1].ActorIDs, []int{3, 2}) {
+		t.Errorf("Expected muting the newest liker to reorder the groups, got %+v", page.Groups)
+	}
+
+	if err := db.MarkNotificationRead(1, page.Groups[0].ID); err != nil {
+		t.Fatalf("Couldn't mark read: %v", err)
+	}
+	page, err = db.GetNotifications(1, "", 0)
+	if err != nil || page.UnreadCount != 1 || !page.Groups[0].Read || page.Groups[1].Read {
+		t.Errorf("Expected only the marked group to be read, got %+v, %v", page, err)
+	}
+}
+
+func TestFollowNotificationsGroupByDay(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < 4; i++ {
+		if _, err := db.CreateUser(fmt.Sprintf("user%d@example.com", i), "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	for _, followerID := range []int{2, 3, 4} {
+		if err := db.Follow(followerID, 1); err != nil {
+			t.Fatalf("Couldn't follow: %v", err)
+		}
+	}
+	// Move the first follow to the day before.
+	err := db.update(func(dbStructure *DBStructure) error {
+		notification := dbStructure.Notifications[1]
+		dbStructure.unindexNotification(notification)
+		notification.CreatedAt = notification.CreatedAt.AddDate(0, 0, -1)
+		dbStructure.Notifications[notification.ID] = notification
+		dbStructure.indexNotification(notification)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Couldn't backdate follow: %v", err)
+	}
+
+	page, err := db.GetNotifications(1, "", 0)
+	if err != nil || len(page.Groups) != 2 || page.UnreadCount != 2 {
+		t.Fatalf("Expected a group for each day, got %+v, %v", page, err)
+	}
+	if !reflect.DeepEqual(page.Groups[0].ActorIDs, []int{4, 3}) || !reflect.DeepEqual(page.Groups[1].ActorIDs, []int{2}) {
+		t.Errorf("Expected each day's followers in their own group, got %+v", page.Groups)
+	}
+}
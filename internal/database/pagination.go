@@ -0,0 +1,230 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// UserEntry records when a user did something, such as liking a chirp or
+// following another user.
+type UserEntry struct {
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserPage struct {
+	Entries    []UserEntry
+	NextCursor string
+}
+
+// pageUserEntries returns one page of entries, newest first. entries must
+// be sorted oldest first, as userEntryIndex keeps them.
+func pageUserEntries(entries []UserEntry, scope, cursor string, limit int) (UserPage, error) {
+	limit = clampLimit(limit)
+	end, err := seekUserEntries(entries, scope, cursor)
+	if err != nil {
+		return UserPage{}, err
+	}
+
+	page := UserPage{Entries: make([]UserEntry, 0, min(limit, end))}
+	for i := end - 1; i >= 0; i-- {
+		if len(page.Entries) == limit {
+			last := page.Entries[limit-1]
+			page.NextCursor = encodeTimeCursor(scope, last.CreatedAt, last.UserID)
+			break
+		}
+		page.Entries = append(page.Entries, entries[i])
+	}
+	return page, nil
+}
+
+// seekUserEntries returns how many of entries, sorted oldest first, come
+// before cursor. Without a cursor that is all of them.
+func seekUserEntries(entries []UserEntry, scope, cursor string) (int, error) {
+	if cursor == "" {
+		return len(entries), nil
+	}
+	var after UserEntry
+	var err error
+	after.CreatedAt, after.UserID, err = decodeTimeCursor(scope, cursor)
+	if err != nil {
+		return 0, err
+	}
+	return sort.Search(len(entries), func(i int) bool {
+		return !entryBefore(entries[i], after)
+	}), nil
+}
+
+// userEntryCollections names the key -> userID -> time collections by their
+// JSON names. Each is indexed in userEntryIndex.
+var userEntryCollections = []string{"likes", "rechirps", "following", "followers", "blocks", "mutes", "bookmarks", "list_members"}
+
+// userEntries returns the collection named collection.
+func (dbStructure *DBStructure) userEntries(collection string) map[int]map[int]time.Time {
+	switch collection {
+	case "likes":
+		return dbStructure.Likes
+	case "rechirps":
+		return dbStructure.Rechirps
+	case "following":
+		return dbStructure.Following
+	case "followers":
+		return dbStructure.Followers
+	case "blocks":
+		return dbStructure.Blocks
+	case "mutes":
+		return dbStructure.Mutes
+	case "bookmarks":
+		return dbStructure.Bookmarks
+	case "list_members":
+		return dbStructure.ListMembers
+	}
+	panic("database: unknown collection " + collection)
+}
+
+// sortedUserEntries returns a copy of the indexed entries under key in
+// collection, oldest first.
+func (dbStructure *DBStructure) sortedUserEntries(collection string, key int) []UserEntry {
+	return append([]UserEntry{}, dbStructure.userEntryIndex[collection][key]...)
+}
+
+// setUserEntry records that userID did something at t in collection, under
+// key.
+func (dbStructure *DBStructure) setUserEntry(collection string, key, userID int, t time.Time) {
+	dbStructure.deleteUserEntry(collection, key, userID)
+	entries := dbStructure.userEntries(collection)
+	if entries[key] == nil {
+		entries[key] = make(map[int]time.Time)
+	}
+	entries[key][userID] = t
+	dbStructure.indexUserEntry(collection, key, UserEntry{UserID: userID, CreatedAt: t})
+}
+
+func (dbStructure *DBStructure) indexUserEntry(collection string, key int, entry UserEntry) {
+	index := dbStructure.userEntryIndex[collection]
+	if index == nil {
+		index = make(map[int][]UserEntry)
+		dbStructure.userEntryIndex[collection] = index
+	}
+	entries := index[key]
+	i := sort.Search(len(entries), func(i int) bool {
+		return !entryBefore(entries[i], entry)
+	})
+	entries = append(entries, UserEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	index[key] = entries
+}
+
+// deleteUserEntry undoes setUserEntry.
+func (dbStructure *DBStructure) deleteUserEntry(collection string, key, userID int) {
+	entries := dbStructure.userEntries(collection)
+	t, ok := entries[key][userID]
+	if !ok {
+		return
+	}
+	delete(entries[key], userID)
+	if len(entries[key]) == 0 {
+		delete(entries, key)
+	}
+
+	index := dbStructure.userEntryIndex[collection]
+	indexed := index[key]
+	entry := UserEntry{UserID: userID, CreatedAt: t}
+	i := sort.Search(len(indexed), func(i int) bool {
+		return !entryBefore(indexed[i], entry)
+	})
+	if i < len(indexed) && indexed[i].UserID == userID {
+		indexed = append(indexed[:i], indexed[i+1:]...)
+	}
+	if len(indexed) == 0 {
+		delete(index, key)
+	} else {
+		index[key] = indexed
+	}
+}
+
+// deleteUserEntries deletes every entry under key in collection.
+func (dbStructure *DBStructure) deleteUserEntries(collection string, key int) {
+	delete(dbStructure.userEntries(collection), key)
+	delete(dbStructure.userEntryIndex[collection], key)
+}
+
+// buildUserEntryIndex indexes every entry of collection.
+func (dbStructure *DBStructure) buildUserEntryIndex(collection string) {
+	index := make(map[int][]UserEntry)
+	for key, users := range dbStructure.userEntries(collection) {
+		entries := make([]UserEntry, 0, len(users))
+		for userID, t := range users {
+			entries = append(entries, UserEntry{UserID: userID, CreatedAt: t})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entryBefore(entries[i], entries[j])
+		})
+		index[key] = entries
+	}
+	dbStructure.userEntryIndex[collection] = index
+}
+
+func entryBefore(a, b UserEntry) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.UserID < b.UserID
+}
+
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	return min(limit, MaxPageSize)
+}
+
+// encodeCursor makes an opaque cursor pointing after id. The scope ties a
+// cursor to the listing and ordering it came from.
+func encodeCursor(scope string, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", scope, id)))
+}
+
+// encodeTimeCursor makes an opaque cursor for listings ordered by time, using
+// id to break ties between entries with the same time.
+func encodeTimeCursor(scope string, t time.Time, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d:%d", scope, t.UnixNano(), id)))
+}
+
+func decodeTimeCursor(scope, cursor string) (time.Time, int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	var nanos int64
+	var id int
+	_, err = fmt.Sscanf(string(data), scope+":%d:%d", &nanos, &id)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return time.Unix(0, nanos).UTC(), id, nil
+}
+
+func decodeCursor(scope, cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	var id int
+	_, err = fmt.Sscanf(string(data), scope+":%d", &id)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
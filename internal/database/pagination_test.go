@@ -0,0 +1,63 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPageUserEntries(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < 6; i++ {
+		if _, err := db.CreateUser(fmt.Sprintf("user%d@example.com", i), "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	// Followers 3 and 4 follow at the same time, so their IDs break the
+	// tie.
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := db.update(func(dbStructure *DBStructure) error {
+		for _, follow := range []struct{ followerID, hour int }{{2, 0}, {3, 1}, {4, 1}, {5, 2}, {6, 3}} {
+			dbStructure.setUserEntry("followers", 1, follow.followerID, start.Add(time.Duration(follow.hour)*time.Hour))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Couldn't add followers: %v", err)
+	}
+	if err := db.Unfollow(5, 1); err != nil {
+		t.Fatalf("Couldn't unfollow: %v", err)
+	}
+
+	pageIDs := func(db *DB) []int {
+		t.Helper()
+		var ids []int
+		cursor := ""
+		for {
+			page, err := db.GetFollowers(1, cursor, 2)
+			if err != nil {
+				t.Fatalf("Couldn't get followers: %v", err)
+			}
+			for _, entry := range page.Entries {
+				ids = append(ids, entry.UserID)
+			}
+			if page.NextCursor == "" {
+				return ids
+			}
+			cursor = page.NextCursor
+		}
+	}
+	want := []int{6, 4, 3, 2}
+	if ids := pageIDs(db); !reflect.DeepEqual(ids, want) {
+		t.Errorf("Expected followers %v newest first, got %v", want, ids)
+	}
+
+	reopened, err := NewDB(db.path)
+	if err != nil {
+		t.Fatalf("Couldn't reopen database: %v", err)
+	}
+	if ids := pageIDs(reopened); !reflect.DeepEqual(ids, want) {
+		t.Errorf("Expected the rebuilt index to page the same, got %v", ids)
+	}
+}
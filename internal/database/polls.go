@@ -0,0 +1,170 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Poll is a set of options attached to a chirp. Users vote until ClosesAt,
+// and the counts change in the same update as the vote so concurrent votes
+// can't lose a count.
+type Poll struct {
+	Options    []PollOption `json:"options"`
+	Multiple   bool         `json:"multiple"`
+	ClosesAt   time.Time    `json:"closes_at"`
+	VoterCount int          `json:"voter_count"`
+}
+
+type PollOption struct {
+	Text  string `json:"text"`
+	Votes int    `json:"votes"`
+}
+
+// PollVote is the options a user chose in a poll, by index.
+type PollVote struct {
+	Choices   []int     `json:"choices"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var ErrPollClosed = errors.New("poll is closed")
+var ErrInvalidVote = errors.New("invalid vote")
+
+// Closed reports whether the poll has stopped taking votes at now.
+func (poll Poll) Closed(now time.Time) bool {
+	return !now.Before(poll.ClosesAt)
+}
+
+// newPoll copies the options, choice and closing time of poll, with no
+// votes.
+func newPoll(poll *Poll) *Poll {
+	if poll == nil {
+		return nil
+	}
+	options := make([]PollOption, 0, len(poll.Options))
+	for _, option := range poll.Options {
+		options = append(options, PollOption{Text: option.Text})
+	}
+	return &Poll{
+		Options:  options,
+		Multiple: poll.Multiple,
+		ClosesAt: poll.ClosesAt.UTC(),
+	}
+}
+
+// Vote records userID's choices in the poll on chirpID. Each user votes
+// once, returning ErrAlreadyExist after that, and votes after the poll
+// closes return ErrPollClosed. Choices that aren't distinct options, or
+// more than one in a single choice poll, return ErrInvalidVote.
+func (db *DB) Vote(chirpID, userID int, choices []int) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStructure.Chirps[chirpID]
+		if !ok || chirp.Deleted || chirp.Poll == nil || !dbStructure.canView(userID, chirp) {
+			return ErrNotExist
+		}
+		now := time.Now().UTC()
+		if chirp.Poll.Closed(now) {
+			return ErrPollClosed
+		}
+		if _, ok := dbStructure.PollVotes[chirpID][userID]; ok {
+			return ErrAlreadyExist
+		}
+		if len(choices) == 0 || (len(choices) > 1 && !chirp.Poll.Multiple) {
+			return ErrInvalidVote
+		}
+		chosen := make(map[int]bool, len(choices))
+		for _, choice := range choices {
+			if choice < 0 || choice >= len(chirp.Poll.Options) || chosen[choice] {
+				return ErrInvalidVote
+			}
+			chosen[choice] = true
+		}
+
+		poll := copyPoll(*chirp.Poll)
+		for _, choice := range choices {
+			poll.Options[choice].Votes++
+		}
+		poll.VoterCount++
+		chirp.Poll = &poll
+		if dbStructure.PollVotes[chirpID] == nil {
+			dbStructure.PollVotes[chirpID] = make(map[int]PollVote)
+		}
+		dbStructure.PollVotes[chirpID][userID] = PollVote{
+			Choices:   append([]int(nil), choices...),
+			CreatedAt: now,
+		}
+		dbStructure.Chirps[chirpID] = chirp
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// copyPoll copies poll so its counts can change without touching chirps
+// that share its options.
+func copyPoll(poll Poll) Poll {
+	poll.Options = append([]PollOption(nil), poll.Options...)
+	return poll
+}
+
+// removeVote takes userID's vote out of the poll on chirpID, if they voted.
+func (dbStructure *DBStructure) removeVote(chirpID, userID int) {
+	vote, ok := dbStructure.PollVotes[chirpID][userID]
+	if !ok {
+		return
+	}
+	delete(dbStructure.PollVotes[chirpID], userID)
+	if len(dbStructure.PollVotes[chirpID]) == 0 {
+		delete(dbStructure.PollVotes, chirpID)
+	}
+	chirp, ok := dbStructure.Chirps[chirpID]
+	if !ok || chirp.Poll == nil {
+		return
+	}
+	poll := copyPoll(*chirp.Poll)
+	for _, choice := range vote.Choices {
+		poll.Options[choice].Votes--
+	}
+	poll.VoterCount--
+	chirp.Poll = &poll
+	dbStructure.Chirps[chirpID] = chirp
+}
+
+// GetPollVotes returns userID's votes in the polls on chirpIDs, leaving out
+// the polls they haven't voted in.
+func (db *DB) GetPollVotes(chirpIDs []int, userID int) (map[int]PollVote, error) {
+	votes := make(map[int]PollVote)
+	err := db.view(func(dbStructure *DBStructure) error {
+		for _, chirpID := range chirpIDs {
+			if vote, ok := dbStructure.PollVotes[chirpID][userID]; ok {
+				votes[chirpID] = vote
+			}
+		}
+		return nil
+	})
+	return votes, err
+}
+
+type ExportedPollVote struct {
+	ChirpID int `json:"chirp_id"`
+	PollVote
+}
+
+// exportPollVotes returns userID's poll votes, oldest first.
+func (dbStructure *DBStructure) exportPollVotes(userID int) []ExportedPollVote {
+	votes := make([]ExportedPollVote, 0)
+	for chirpID, voters := range dbStructure.PollVotes {
+		if vote, ok := voters[userID]; ok {
+			votes = append(votes, ExportedPollVote{ChirpID: chirpID, PollVote: vote})
+		}
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		a, b := votes[i], votes[j]
+		return entryBefore(UserEntry{UserID: a.ChirpID, CreatedAt: a.CreatedAt}, UserEntry{UserID: b.ChirpID, CreatedAt: b.CreatedAt})
+	})
+	return votes
+}
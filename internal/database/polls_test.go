@@ -0,0 +1,110 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVote(t *testing.T) {
+	db := newTestDB(t)
+	const voters = 20
+	for i := 1; i <= voters; i++ {
+		if _, err := db.CreateUser(fmt.Sprintf("user%d@example.com", i), "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	chirp, err := db.CreateChirp(Chirp{Body: "which?", AuthorId: 1, Poll: &Poll{
+		Options:  []PollOption{{Text: "a"}, {Text: "b"}, {Text: "c", Votes: 100}},
+		Multiple: true,
+		ClosesAt: time.Now().Add(time.Hour),
+	}})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	if chirp.Poll.Options[2].Votes != 0 || chirp.Poll.VoterCount != 0 {
+		t.Fatalf("Expected a new poll to have no votes, got %+v", chirp.Poll)
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= voters; i++ {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			if _, err := db.Vote(chirp.ID, userID, []int{0, 2}); err != nil {
+				t.Errorf("Couldn't vote: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	chirp, err = db.GetChirp(chirp.ID)
+	if err != nil {
+		t.Fatalf("Couldn't get chirp: %v", err)
+	}
+	if chirp.Poll.VoterCount != voters || chirp.Poll.Options[0].Votes != voters || chirp.Poll.Options[1].Votes != 0 || chirp.Poll.Options[2].Votes != voters {
+		t.Errorf("Expected every concurrent vote to count, got %+v", chirp.Poll)
+	}
+
+	if _, err := db.Vote(chirp.ID, 1, []int{1}); !errors.Is(err, ErrAlreadyExist) {
+		t.Errorf("Expected a second vote to return ErrAlreadyExist, got %v", err)
+	}
+	votes, err := db.GetPollVotes([]int{chirp.ID}, 1)
+	if err != nil || len(votes[chirp.ID].Choices) != 2 {
+		t.Errorf("Expected the user's vote, got %+v, %v", votes, err)
+	}
+}
+
+func TestVoteRules(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "voter@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	single, err := db.CreateChirp(Chirp{Body: "one", AuthorId: 1, Poll: &Poll{
+		Options:  []PollOption{{Text: "a"}, {Text: "b"}},
+		ClosesAt: time.Now().Add(time.Hour),
+	}})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	closed, err := db.CreateChirp(Chirp{Body: "closed", AuthorId: 1, Poll: &Poll{
+		Options:  []PollOption{{Text: "a"}, {Text: "b"}},
+		ClosesAt: time.Now().Add(-time.Minute),
+	}})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	plain, err := db.CreateChirp(Chirp{Body: "no poll", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+
+	for _, choices := range [][]int{nil, {0, 1}, {2}, {-1}} {
+		if _, err := db.Vote(single.ID, 2, choices); !errors.Is(err, ErrInvalidVote) {
+			t.Errorf("Expected choices %v to return ErrInvalidVote, got %v", choices, err)
+		}
+	}
+	if _, err := db.Vote(closed.ID, 2, []int{0}); !errors.Is(err, ErrPollClosed) {
+		t.Errorf("Expected voting in a closed poll to return ErrPollClosed, got %v", err)
+	}
+	if _, err := db.Vote(plain.ID, 2, []int{0}); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected voting on a chirp without a poll to return ErrNotExist, got %v", err)
+	}
+
+	if _, err := db.Vote(single.ID, 2, []int{1}); err != nil {
+		t.Fatalf("Couldn't vote: %v", err)
+	}
+	if _, err := db.RequestDeletion(2, 0); err != nil {
+		t.Fatalf("Couldn't request deletion: %v", err)
+	}
+	if _, err := db.PurgeDeletedUsers(time.Now().Add(time.Second), DeletionAnonymize); err != nil {
+		t.Fatalf("Couldn't purge users: %v", err)
+	}
+	single, err = db.GetChirp(single.ID)
+	if err != nil || single.Poll.VoterCount != 0 || single.Poll.Options[1].Votes != 0 {
+		t.Errorf("Expected purging the voter to remove their vote, got %+v, %v", single.Poll, err)
+	}
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChirpQuote is a snapshot of a quoted chirp, taken when it was quoted so
+// later edits don't change what the quote was about. It is cleared when
+// the quoted chirp is deleted.
+type ChirpQuote struct {
+	AuthorID  int       `json:"author_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ErrQuotedChirpNotExist is returned when quoting a chirp that doesn't
+// exist or that the author can't see.
+var ErrQuotedChirpNotExist = fmt.Errorf("quoted chirp: %w", ErrNotExist)
+
+// quoteChirp snapshots the chirp newChirp quotes and counts the quote on
+// it.
+func (dbStructure *DBStructure) quoteChirp(newChirp *Chirp) error {
+	quoted, ok := dbStructure.Chirps[newChirp.QuoteOf]
+	if !ok || quoted.Deleted || !dbStructure.canView(newChirp.AuthorId, quoted) {
+		return ErrQuotedChirpNotExist
+	}
+	newChirp.Quote = &ChirpQuote{
+		AuthorID:  quoted.AuthorId,
+		Body:      quoted.Body,
+		CreatedAt: quoted.CreatedAt,
+	}
+	quoted.QuoteCount++
+	dbStructure.Chirps[quoted.ID] = quoted
+	return nil
+}
+
+// unquoteChirp takes a deleted chirp's quote off the count of the chirp it
+// quoted.
+func (dbStructure *DBStructure) unquoteChirp(chirp Chirp) {
+	quoted, ok := dbStructure.Chirps[chirp.QuoteOf]
+	if !ok || quoted.QuoteCount == 0 {
+		return
+	}
+	quoted.QuoteCount--
+	dbStructure.Chirps[quoted.ID] = quoted
+}
+
+// updateQuotes applies change to the snapshot in every chirp quoting
+// quotedID.
+func (dbStructure *DBStructure) updateQuotes(quotedID int, change func(quote *ChirpQuote) *ChirpQuote) {
+	for id, chirp := range dbStructure.Chirps {
+		if chirp.QuoteOf != quotedID || chirp.Quote == nil {
+			continue
+		}
+		quote := *chirp.Quote
+		chirp.Quote = change(&quote)
+		dbStructure.Chirps[id] = chirp
+	}
+}
+
+// GetVisibleChirps reports which of chirpIDs exist and viewerID can see.
+func (db *DB) GetVisibleChirps(chirpIDs []int, viewerID int) (map[int]bool, error) {
+	visible := make(map[int]bool, len(chirpIDs))
+	err := db.view(func(dbStructure *DBStructure) error {
+		for _, chirpID := range chirpIDs {
+			chirp, ok := dbStructure.Chirps[chirpID]
+			visible[chirpID] = ok && !chirp.Deleted && dbStructure.canView(viewerID, chirp)
+		}
+		return nil
+	})
+	return visible, err
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuoteChirp(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "quoter@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	original, err := db.CreateChirp(Chirp{Body: "original", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	private, err := db.CreateChirp(Chirp{Body: "private", AuthorId: 1, Visibility: VisibilityMentioned})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	if _, err := db.CreateChirp(Chirp{Body: "quote", AuthorId: 2, QuoteOf: private.ID}); !errors.Is(err, ErrQuotedChirpNotExist) || !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected quoting a chirp the author can't see to return ErrQuotedChirpNotExist, got %v", err)
+	}
+
+	quote, err := db.CreateChirp(Chirp{Body: "look at this", AuthorId: 2, QuoteOf: original.ID})
+	if err != nil {
+		t.Fatalf("Couldn't quote chirp: %v", err)
+	}
+	if quote.Quote == nil || quote.Quote.Body != "original" || quote.Quote.AuthorID != 1 {
+		t.Errorf("Expected a snapshot of the quoted chirp, got %+v", quote.Quote)
+	}
+	if _, err := db.UpdateChirp(original.ID, "edited", nil); err != nil {
+		t.Fatalf("Couldn't edit chirp: %v", err)
+	}
+	quote, err = db.GetChirp(quote.ID)
+	if err != nil || quote.Quote.Body != "original" {
+		t.Errorf("Expected the snapshot to keep the quoted body, got %+v, %v", quote.Quote, err)
+	}
+	original, err = db.GetChirp(original.ID)
+	if err != nil || original.QuoteCount != 1 {
+		t.Errorf("Expected the quote to be counted, got %+v, %v", original, err)
+	}
+	page, err := db.GetNotifications(1, "", 10)
+	if err != nil || len(page.Groups) != 1 || page.Groups[0].Type != NotificationQuote || page.Groups[0].ChirpID != quote.ID {
+		t.Errorf("Expected the author to be notified of the quote, got %+v, %v", page.Groups, err)
+	}
+
+	if err := db.DeleteChirp(original.ID); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+	quote, err = db.GetChirp(quote.ID)
+	if err != nil || quote.Quote != nil || quote.QuoteOf != original.ID {
+		t.Errorf("Expected deleting the quoted chirp to clear the snapshot, got %+v, %v", quote, err)
+	}
+}
+
+func TestPinChirp(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "other@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	for _, body := range []string{"first", "second", "third", "fourth"} {
+		if _, err := db.CreateChirp(Chirp{Body: body, AuthorId: 1}); err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	if _, err := db.PinChirp(2, 1); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected pinning another user's chirp to return ErrNotExist, got %v", err)
+	}
+	user, err := db.PinChirp(1, 1)
+	if err != nil || user.PinnedChirpID != 1 {
+		t.Fatalf("Expected the chirp to be pinned, got %+v, %v", user, err)
+	}
+
+	query := ChirpQuery{AuthorIDs: []int{1}, Sort: SortIDDesc, Limit: 2, PinnedID: user.PinnedChirpID}
+	page, err := db.QueryChirps(query)
+	if err != nil || page.Pinned == nil || page.Pinned.ID != 1 || len(page.Chirps) != 2 || page.Chirps[0].ID != 4 || page.Chirps[1].ID != 3 {
+		t.Fatalf("Expected the pinned chirp first and left out of the listing, got %+v, %v", page, err)
+	}
+	query.Cursor = page.NextCursor
+	page, err = db.QueryChirps(query)
+	if err != nil || page.Pinned != nil || len(page.Chirps) != 1 || page.Chirps[0].ID != 2 {
+		t.Errorf("Expected later pages without the pinned chirp, got %+v, %v", page, err)
+	}
+
+	if err := db.DeleteChirp(1); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+	user, err = db.GetUser(1)
+	if err != nil || user.PinnedChirpID != 0 {
+		t.Errorf("Expected deleting the chirp to unpin it, got %+v, %v", user, err)
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"strconv"
+	"time"
+)
+
+type Reaction string
+
+const (
+	ReactionLike    Reaction = "like"
+	ReactionRechirp Reaction = "rechirp"
+)
+
+// reactions returns the name of the chirpID -> userID -> time collection
+// for reaction and a pointer to the counter it keeps on chirp.
+func reactions(reaction Reaction, chirp *Chirp) (string, *int) {
+	if reaction == ReactionRechirp {
+		return "rechirps", &chirp.RechirpCount
+	}
+	return "likes", &chirp.LikeCount
+}
+
+// AddReaction records the user's reaction to a chirp. Reacting twice is a
+// no-op, and the chirp's counter changes in the same update as the reaction
+// so concurrent requests can't lose a count.
+func (db *DB) AddReaction(chirpID, userID int, reaction Reaction) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStructure.Chirps[chirpID]
+		if !ok || chirp.Deleted || !dbStructure.canView(userID, chirp) {
+			return ErrNotExist
+		}
+		collection, counter := reactions(reaction, &chirp)
+		users := dbStructure.userEntries(collection)
+		if _, ok := users[chirpID][userID]; ok {
+			return nil
+		}
+		dbStructure.setUserEntry(collection, chirpID, userID, time.Now().UTC())
+		*counter = len(users[chirpID])
+		dbStructure.Chirps[chirpID] = chirp
+		dbStructure.notify(chirp.AuthorId, NotificationType(reaction), userID, chirpID)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// RemoveReaction undoes AddReaction. Removing a reaction that doesn't exist
+// is a no-op.
+func (db *DB) RemoveReaction(chirpID, userID int, reaction Reaction) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStructure.Chirps[chirpID]
+		if !ok || chirp.Deleted {
+			return ErrNotExist
+		}
+		collection, counter := reactions(reaction, &chirp)
+		users := dbStructure.userEntries(collection)
+		if _, ok := users[chirpID][userID]; !ok {
+			return nil
+		}
+		dbStructure.deleteUserEntry(collection, chirpID, userID)
+		*counter = len(users[chirpID])
+		dbStructure.Chirps[chirpID] = chirp
+		dbStructure.unnotify(chirp.AuthorId, func(n Notification) bool {
+			return n.Type == NotificationType(reaction) && n.ChirpID == chirpID && n.ActorID == userID
+		})
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// GetReactedChirps reports which of chirpIDs the user has reacted to.
+func (db *DB) GetReactedChirps(chirpIDs []int, userID int, reaction Reaction) (map[int]bool, error) {
+	reacted := make(map[int]bool, len(chirpIDs))
+	err := db.view(func(dbStructure *DBStructure) error {
+		collection, _ := reactions(reaction, &Chirp{})
+		users := dbStructure.userEntries(collection)
+		for _, chirpID := range chirpIDs {
+			_, reacted[chirpID] = users[chirpID][userID]
+		}
+		return nil
+	})
+	return reacted, err
+}
+
+// GetReactions lists the users who reacted to a chirp, newest first.
+func (db *DB) GetReactions(chirpID, viewerID int, reaction Reaction, cursor string, limit int) (UserPage, error) {
+	var page UserPage
+	err := db.view(func(dbStructure *DBStructure) error {
+		chirp, ok := dbStructure.Chirps[chirpID]
+		if !ok || chirp.Deleted || !dbStructure.canView(viewerID, chirp) {
+			return ErrNotExist
+		}
+		collection, _ := reactions(reaction, &chirp)
+		scope := string(reaction) + "s:" + strconv.Itoa(chirpID)
+		var err error
+		page, err = pageUserEntries(dbStructure.userEntryIndex[collection][chirpID], scope, cursor, limit)
+		return err
+	})
+	return page, err
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestReactionsConcurrently(t *testing.T) {
+	db := newTestDB(t)
+	const users = 20
+	for i := 1; i <= users; i++ {
+		if _, err := db.CreateUser(fmt.Sprintf("user%d@example.com", i), "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	chirp, err := db.CreateChirp(Chirp{Body: "like me", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= users; i++ {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			if _, err := db.AddReaction(chirp.ID, userID, ReactionLike); err != nil {
+				t.Errorf("Couldn't like: %v", err)
+			}
+			if userID%2 == 0 {
+				if _, err := db.RemoveReaction(chirp.ID, userID, ReactionLike); err != nil {
+					t.Errorf("Couldn't unlike: %v", err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	chirp, err = db.GetChirp(chirp.ID)
+	if err != nil {
+		t.Fatalf("Couldn't get chirp: %v", err)
+	}
+	if chirp.LikeCount != users/2 {
+		t.Errorf("Expected %d likes, got %d", users/2, chirp.LikeCount)
+	}
+	page, err := db.GetReactions(chirp.ID, 0, ReactionLike, "", 100)
+	if err != nil || len(page.Entries) != users/2 {
+		t.Errorf("Expected %d users to have liked the chirp, got %+v, %v", users/2, page.Entries, err)
+	}
+}
+
+func TestReactionsAreIdempotent(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "fan@example.com", "other@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	chirp, err := db.CreateChirp(Chirp{Body: "twice", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+
+	for _, reaction := range []Reaction{ReactionLike, ReactionRechirp} {
+		for i := 0; i < 2; i++ {
+			if chirp, err = db.AddReaction(chirp.ID, 2, reaction); err != nil {
+				t.Fatalf("Couldn't add %s: %v", reaction, err)
+			}
+		}
+	}
+	if chirp.LikeCount != 1 || chirp.RechirpCount != 1 {
+		t.Errorf("Expected repeated reactions to count once, got %d likes and %d rechirps", chirp.LikeCount, chirp.RechirpCount)
+	}
+	notifications, err := db.GetNotifications(1, "", 10)
+	if err != nil || len(notifications.Groups) != 2 || notifications.UnreadCount != 2 {
+		t.Errorf("Expected one notification per reaction, got %+v, %v", notifications, err)
+	}
+
+	liked, err := db.GetReactedChirps([]int{chirp.ID}, 2, ReactionLike)
+	if err != nil || !liked[chirp.ID] {
+		t.Errorf("Expected the fan to have liked the chirp, got %v, %v", liked, err)
+	}
+	liked, err = db.GetReactedChirps([]int{chirp.ID}, 3, ReactionLike)
+	if err != nil || liked[chirp.ID] {
+		t.Errorf("Expected another user not to have liked the chirp, got %v, %v", liked, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if chirp, err = db.RemoveReaction(chirp.ID, 2, ReactionRechirp); err != nil {
+			t.Fatalf("Couldn't remove rechirp: %v", err)
+		}
+	}
+	if chirp.LikeCount != 1 || chirp.RechirpCount != 0 {
+		t.Errorf("Expected only the rechirp to be removed, got %d likes and %d rechirps", chirp.LikeCount, chirp.RechirpCount)
+	}
+	rechirped, err := db.GetReactedChirps([]int{chirp.ID}, 2, ReactionRechirp)
+	if err != nil || rechirped[chirp.ID] {
+		t.Errorf("Expected the rechirp to be gone, got %v, %v", rechirped, err)
+	}
+}
@@ -0,0 +1,384 @@
+package database
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidReport  = errors.New("invalid report")
+	ErrReportClaimed  = errors.New("report is claimed by another moderator")
+	ErrReportResolved = errors.New("report is already resolved")
+)
+
+type ReportReason string
+
+const (
+	ReportSpam          ReportReason = "spam"
+	ReportHarassment    ReportReason = "harassment"
+	ReportHate          ReportReason = "hate"
+	ReportViolence      ReportReason = "violence"
+	ReportImpersonation ReportReason = "impersonation"
+	ReportOther         ReportReason = "other"
+)
+
+// ReportReasons lists every report reason in a stable order.
+var ReportReasons = []ReportReason{
+	ReportSpam,
+	ReportHarassment,
+	ReportHate,
+	ReportViolence,
+	ReportImpersonation,
+	ReportOther,
+}
+
+func (r ReportReason) Valid() bool {
+	for _, reason := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+type ReportStatus string
+
+const (
+	ReportOpen     ReportStatus = "open"
+	ReportClaimed  ReportStatus = "claimed"
+	ReportResolved ReportStatus = "resolved"
+)
+
+// ModerationAction is something a moderator did. Every action but claiming
+// resolves a report.
+type ModerationAction string
+
+const (
+	ModerationClaim       ModerationAction = "claim"
+	ModerationDismiss     ModerationAction = "dismiss"
+	ModerationHideChirp   ModerationAction = "hide_chirp"
+	ModerationDeleteChirp ModerationAction = "delete_chirp"
+	ModerationWarn        ModerationAction = "warn"
+	ModerationSuspend     ModerationAction = "suspend"
+	ModerationBan         ModerationAction = "ban"
+	// ModerationReinstate lifts a suspension or ban.
+	ModerationReinstate ModerationAction = "reinstate"
+)
+
+// ChangesStatus reports whether a changes the status of the user it is
+// taken against.
+func (a ModerationAction) ChangesStatus() bool {
+	switch a {
+	case ModerationSuspend, ModerationBan, ModerationReinstate:
+		return true
+	}
+	return false
+}
+
+// Resolves reports whether a resolves a report.
+func (a ModerationAction) Resolves() bool {
+	switch a {
+	case ModerationDismiss, ModerationHideChirp, ModerationDeleteChirp, ModerationWarn, ModerationSuspend, ModerationBan:
+		return true
+	}
+	return false
+}
+
+// Report flags a chirp or a user for moderators. UserID is the reported
+// user, which for a chirp report is the chirp's author. ModeratorID is the
+// moderator who claimed it.
+type Report struct {
+	ID          int               `json:"id"`
+	ReporterID  int               `json:"reporter_id"`
+	ChirpID     int               `json:"chirp_id,omitempty"`
+	UserID      int               `json:"user_id"`
+	Reason      ReportReason      `json:"reason"`
+	Comment     string            `json:"comment,omitempty"`
+	Status      ReportStatus      `json:"status"`
+	ModeratorID int               `json:"moderator_id,omitempty"`
+	ClaimedAt   *time.Time        `json:"claimed_at,omitempty"`
+	Resolution  *ReportResolution `json:"resolution,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// ReportResolution is how a moderator closed a report. SuspendedUntil is
+// only set for suspensions.
+type ReportResolution struct {
+	Action         ModerationAction `json:"action"`
+	Note           string           `json:"note,omitempty"`
+	SuspendedUntil *time.Time       `json:"suspended_until,omitempty"`
+	ResolvedAt     time.Time        `json:"resolved_at"`
+}
+
+type ReportPage struct {
+	Reports    []Report
+	NextCursor string
+}
+
+// ModerationLogEntry records one moderator action. The log is append-only:
+// nothing updates or deletes its entries.
+type ModerationLogEntry struct {
+	ID             int              `json:"id"`
+	ModeratorID    int              `json:"moderator_id"`
+	Action         ModerationAction `json:"action"`
+	ReportID       int              `json:"report_id,omitempty"`
+	UserID         int              `json:"user_id,omitempty"`
+	ChirpID        int              `json:"chirp_id,omitempty"`
+	Note           string           `json:"note,omitempty"`
+	SuspendedUntil *time.Time       `json:"suspended_until,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+type ModerationLogPage struct {
+	Entries    []ModerationLogEntry
+	NextCursor string
+}
+
+// CreateReport files report. Reporting a chirp the reporter can't see
+// returns ErrNotExist, reporting yourself or with an unknown reason returns
+// ErrInvalidReport, and reporting the same thing twice while the first
+// report is unresolved returns ErrAlreadyExist.
+func (db *DB) CreateReport(report Report) (Report, error) {
+	var newReport Report
+	err := db.update(func(dbStructure *DBStructure) error {
+		if !report.Reason.Valid() {
+			return ErrInvalidReport
+		}
+		userID := report.UserID
+		if report.ChirpID != 0 {
+			chirp, ok := dbStructure.Chirps[report.ChirpID]
+			if !ok || chirp.Deleted || !dbStructure.canView(report.ReporterID, chirp) {
+				return ErrNotExist
+			}
+			userID = chirp.AuthorId
+		} else if _, ok := dbStructure.Users[userID]; !ok || dbStructure.blocked(report.ReporterID, userID) {
+			return ErrNotExist
+		}
+		if userID == report.ReporterID {
+			return ErrInvalidReport
+		}
+		for _, existing := range dbStructure.Reports {
+			if existing.ReporterID == report.ReporterID && existing.ChirpID == report.ChirpID &&
+				existing.UserID == userID && existing.Status != ReportResolved {
+				return ErrAlreadyExist
+			}
+		}
+
+		now := time.Now().UTC()
+		newReport = Report{
+			ID:         dbStructure.nextID("reports"),
+			ReporterID: report.ReporterID,
+			ChirpID:    report.ChirpID,
+			UserID:     userID,
+			Reason:     report.Reason,
+			Comment:    report.Comment,
+			Status:     ReportOpen,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		dbStructure.Reports[newReport.ID] = newReport
+		return nil
+	})
+	return newReport, err
+}
+
+func (db *DB) GetReport(id int) (Report, error) {
+	var report Report
+	err := db.view(func(dbStructure *DBStructure) error {
+		var ok bool
+		report, ok = dbStructure.Reports[id]
+		if !ok {
+			return ErrNotExist
+		}
+		return nil
+	})
+	return report, err
+}
+
+// GetReports returns a page of the reports with status, oldest first so the
+// queue is worked in order. An empty status returns every unresolved
+// report.
+func (db *DB) GetReports(status ReportStatus, cursor string, limit int) (ReportPage, error) {
+	limit = clampLimit(limit)
+	scope := "reports:" + string(status)
+	after := 0
+	if cursor != "" {
+		var err error
+		after, err = decodeCursor(scope, cursor)
+		if err != nil {
+			return ReportPage{}, err
+		}
+	}
+
+	page := ReportPage{Reports: make([]Report, 0, limit)}
+	err := db.view(func(dbStructure *DBStructure) error {
+		for id := after + 1; id <= dbStructure.Sequences["reports"]; id++ {
+			report, ok := dbStructure.Reports[id]
+			if !ok {
+				continue
+			}
+			if status == "" && report.Status == ReportResolved || status != "" && report.Status != status {
+				continue
+			}
+			if len(page.Reports) == limit {
+				page.NextCursor = encodeCursor(scope, page.Reports[limit-1].ID)
+				break
+			}
+			page.Reports = append(page.Reports, report)
+		}
+		return nil
+	})
+	return page, err
+}
+
+// ClaimReport assigns an unresolved report to moderatorID so other
+// moderators leave it alone. Claiming a report you already hold is a no-op.
+func (db *DB) ClaimReport(id, moderatorID int) (Report, error) {
+	var report Report
+	err := db.update(func(dbStructure *DBStructure) error {
+		var err error
+		report, err = dbStructure.claimableReport(id, moderatorID)
+		if err != nil || report.Status == ReportClaimed {
+			return err
+		}
+		now := time.Now().UTC()
+		report.Status = ReportClaimed
+		report.ModeratorID = moderatorID
+		report.ClaimedAt = &now
+		report.UpdatedAt = now
+		dbStructure.Reports[id] = report
+		dbStructure.logModeration(ModerationLogEntry{
+			ModeratorID: moderatorID,
+			Action:      ModerationClaim,
+			ReportID:    id,
+			UserID:      report.UserID,
+			ChirpID:     report.ChirpID,
+		})
+		return nil
+	})
+	return report, err
+}
+
+// ResolveReport closes a report by taking resolution's action against the
+// reported chirp or user, and lets the reporter know. Unclaimed reports are
+// claimed by moderatorID on the way. Suspensions need a SuspendedUntil in
+// the future, and chirp actions need a chirp report.
+func (db *DB) ResolveReport(id, moderatorID int, resolution ReportResolution) (Report, error) {
+	var report Report
+	err := db.update(func(dbStructure *DBStructure) error {
+		var err error
+		report, err = dbStructure.claimableReport(id, moderatorID)
+		if err != nil {
+			return err
+		}
+		if !resolution.Action.Resolves() {
+			return ErrInvalidReport
+		}
+		now := time.Now().UTC()
+		switch resolution.Action {
+		case ModerationHideChirp, ModerationDeleteChirp:
+			if report.ChirpID == 0 {
+				return ErrInvalidReport
+			}
+		case ModerationSuspend:
+			if resolution.SuspendedUntil == nil || !resolution.SuspendedUntil.After(now) {
+				return ErrInvalidReport
+			}
+		default:
+			resolution.SuspendedUntil = nil
+		}
+
+		switch resolution.Action {
+		case ModerationHideChirp:
+			if chirp, ok := dbStructure.Chirps[report.ChirpID]; ok && !chirp.Deleted {
+				chirp.Hidden = true
+				chirp.UpdatedAt = now
+				dbStructure.Chirps[chirp.ID] = chirp
+			}
+		case ModerationDeleteChirp:
+			dbStructure.deleteChirp(report.ChirpID)
+		case ModerationWarn:
+			dbStructure.notify(report.UserID, NotificationWarning, 0, report.ChirpID)
+		case ModerationSuspend:
+			dbStructure.setUserStatus(report.UserID, UserSuspended, resolution.Note, resolution.SuspendedUntil, now)
+		case ModerationBan:
+			dbStructure.setUserStatus(report.UserID, UserBanned, resolution.Note, nil, now)
+		}
+
+		resolution.ResolvedAt = now
+		if report.Status == ReportOpen {
+			report.ModeratorID = moderatorID
+			report.ClaimedAt = &now
+		}
+		report.Status = ReportResolved
+		report.Resolution = &resolution
+		report.UpdatedAt = now
+		dbStructure.Reports[id] = report
+		dbStructure.logModeration(ModerationLogEntry{
+			ModeratorID:    moderatorID,
+			Action:         resolution.Action,
+			ReportID:       id,
+			UserID:         report.UserID,
+			ChirpID:        report.ChirpID,
+			Note:           resolution.Note,
+			SuspendedUntil: resolution.SuspendedUntil,
+		})
+		dbStructure.notifyReportResolved(report)
+		return nil
+	})
+	return report, err
+}
+
+// claimableReport returns the report with id if moderatorID may act on it.
+func (dbStructure *DBStructure) claimableReport(id, moderatorID int) (Report, error) {
+	report, ok := dbStructure.Reports[id]
+	switch {
+	case !ok:
+		return Report{}, ErrNotExist
+	case report.Status == ReportResolved:
+		return Report{}, ErrReportResolved
+	case report.Status == ReportClaimed && report.ModeratorID != moderatorID:
+		return Report{}, ErrReportClaimed
+	}
+	return report, nil
+}
+
+func (dbStructure *DBStructure) logModeration(entry ModerationLogEntry) {
+	entry.ID = dbStructure.nextID("moderation_log")
+	entry.CreatedAt = time.Now().UTC()
+	dbStructure.ModerationLog = append(dbStructure.ModerationLog, entry)
+}
+
+// GetModerationLog returns a page of the moderation log, newest first. A
+// non-zero userID only returns actions taken against that user.
+func (db *DB) GetModerationLog(userID int, cursor string, limit int) (ModerationLogPage, error) {
+	limit = clampLimit(limit)
+	scope := "moderation_log:" + strconv.Itoa(userID)
+	before := 0
+	if cursor != "" {
+		var err error
+		before, err = decodeCursor(scope, cursor)
+		if err != nil {
+			return ModerationLogPage{}, err
+		}
+	}
+
+	page := ModerationLogPage{Entries: make([]ModerationLogEntry, 0, limit)}
+	err := db.view(func(dbStructure *DBStructure) error {
+		for i := len(dbStructure.ModerationLog) - 1; i >= 0; i-- {
+			entry := dbStructure.ModerationLog[i]
+			if before != 0 && entry.ID >= before || userID != 0 && entry.UserID != userID {
+				continue
+			}
+			if len(page.Entries) == limit {
+				page.NextCursor = encodeCursor(scope, page.Entries[limit-1].ID)
+				break
+			}
+			page.Entries = append(page.Entries, entry)
+		}
+		return nil
+	})
+	return page, err
+}
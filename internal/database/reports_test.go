@@ -0,0 +1,177 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReports(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "reporter@example.com", "mod@example.com", "other@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	chirp, err := db.CreateChirp(Chirp{Body: "buy my stuff", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+
+	report, err := db.CreateReport(Report{ReporterID: 2, ChirpID: chirp.ID, Reason: ReportSpam, Comment: "ads"})
+	if err != nil {
+		t.Fatalf("Couldn't create report: %v", err)
+	}
+	if report.UserID != 1 || report.Status != ReportOpen {
+		t.Errorf("Expected an open report against the author, got %+v", report)
+	}
+	if _, err := db.CreateReport(Report{ReporterID: 2, ChirpID: chirp.ID, Reason: ReportHate}); !errors.Is(err, ErrAlreadyExist) {
+		t.Errorf("Expected ErrAlreadyExist reporting twice, got %v", err)
+	}
+	if _, err := db.CreateReport(Report{ReporterID: 1, ChirpID: chirp.ID, Reason: ReportSpam}); !errors.Is(err, ErrInvalidReport) {
+		t.Errorf("Expected ErrInvalidReport reporting yourself, got %v", err)
+	}
+	if _, err := db.CreateReport(Report{ReporterID: 2, UserID: 1, Reason: "rude"}); !errors.Is(err, ErrInvalidReport) {
+		t.Errorf("Expected ErrInvalidReport for an unknown reason, got %v", err)
+	}
+	if _, err := db.CreateReport(Report{ReporterID: 2, UserID: 99, Reason: ReportSpam}); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist reporting a missing user, got %v", err)
+	}
+	userReport, err := db.CreateReport(Report{ReporterID: 4, UserID: 1, Reason: ReportHarassment})
+	if err != nil {
+		t.Fatalf("Couldn't create user report: %v", err)
+	}
+
+	if _, err := db.ClaimReport(report.ID, 3); err != nil {
+		t.Fatalf("Couldn't claim report: %v", err)
+	}
+	if _, err := db.ClaimReport(report.ID, 4); !errors.Is(err, ErrReportClaimed) {
+		t.Errorf("Expected ErrReportClaimed, got %v", err)
+	}
+	if _, err := db.ResolveReport(report.ID, 4, ReportResolution{Action: ModerationDismiss}); !errors.Is(err, ErrReportClaimed) {
+		t.Errorf("Expected ErrReportClaimed resolving another moderator's report, got %v", err)
+	}
+	if _, err := db.ResolveReport(userReport.ID, 3, ReportResolution{Action: ModerationHideChirp}); !errors.Is(err, ErrInvalidReport) {
+		t.Errorf("Expected ErrInvalidReport hiding a chirp from a user report, got %v", err)
+	}
+	if _, err := db.ResolveReport(userReport.ID, 3, ReportResolution{Action: ModerationSuspend}); !errors.Is(err, ErrInvalidReport) {
+		t.Errorf("Expected ErrInvalidReport suspending without an end, got %v", err)
+	}
+
+	open, err := db.GetReports("", "", 1)
+	if err != nil {
+		t.Fatalf("Couldn't get reports: %v", err)
+	}
+	if len(open.Reports) != 1 || open.Reports[0].ID != report.ID || open.NextCursor == "" {
+		t.Fatalf("Expected the oldest report and a cursor, got %+v", open)
+	}
+	open, err = db.GetReports("", open.NextCursor, 1)
+	if err != nil || len(open.Reports) != 1 || open.Reports[0].ID != userReport.ID {
+		t.Errorf("Expected the user report on the next page, got %+v %v", open, err)
+	}
+
+	resolved, err := db.ResolveReport(report.ID, 3, ReportResolution{Action: ModerationHideChirp, Note: "spam"})
+	if err != nil {
+		t.Fatalf("Couldn't resolve report: %v", err)
+	}
+	if resolved.Status != ReportResolved || resolved.Resolution.Action != ModerationHideChirp {
+		t.Errorf("Expected a resolved report, got %+v", resolved)
+	}
+	if _, err := db.ResolveReport(report.ID, 3, ReportResolution{Action: ModerationBan}); !errors.Is(err, ErrReportResolved) {
+		t.Errorf("Expected ErrReportResolved, got %v", err)
+	}
+	if _, err := db.GetChirpForViewer(chirp.ID, 2); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected the hidden chirp to be gone for readers, got %v", err)
+	}
+	if _, err := db.GetChirpForViewer(chirp.ID, 1); err != nil {
+		t.Errorf("Expected the author to still see their hidden chirp, got %v", err)
+	}
+	notifications, err := db.GetNotifications(2, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't get notifications: %v", err)
+	}
+	if len(notifications.Groups) != 1 || notifications.Groups[0].Type != NotificationReport || notifications.Groups[0].ReportID != report.ID {
+		t.Errorf("Expected the reporter to hear back, got %+v", notifications.Groups)
+	}
+
+	until := time.Now().UTC().Add(time.Hour)
+	if _, err := db.ResolveReport(userReport.ID, 3, ReportResolution{Action: ModerationSuspend, Note: "cool off", SuspendedUntil: &until}); err != nil {
+		t.Fatalf("Couldn't suspend user: %v", err)
+	}
+	user, err := db.GetUser(1)
+	if err != nil {
+		t.Fatalf("Couldn't get user: %v", err)
+	}
+	if user.Status != UserSuspended || user.StatusReason != "cool off" || !user.SuspendedUntil.Equal(until) {
+		t.Errorf("Expected a suspended user, got %+v", user)
+	}
+
+	log, err := db.GetModerationLog(0, "", 0)
+	if err != nil {
+		t.Fatalf("Couldn't get moderation log: %v", err)
+	}
+	actions := make([]ModerationAction, 0, len(log.Entries))
+	for _, entry := range log.Entries {
+		actions = append(actions, entry.Action)
+	}
+	if len(actions) != 3 || actions[0] != ModerationSuspend || actions[1] != ModerationHideChirp || actions[2] != ModerationClaim {
+		t.Errorf("Expected the log newest first, got %v", actions)
+	}
+}
+
+func TestUserStatus(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "reader@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	chirp, err := db.CreateChirp(Chirp{Body: "hello", AuthorId: 1})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+
+	if _, err := db.SetUserStatus(1, 2, UserSuspended, "cool off", nil); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("Expected ErrInvalidStatus suspending without an end, got %v", err)
+	}
+	until := time.Now().UTC().Add(time.Hour)
+	user, err := db.SetUserStatus(1, 2, UserSuspended, "cool off", &until)
+	if err != nil {
+		t.Fatalf("Couldn't suspend user: %v", err)
+	}
+	if user.StatusAt(time.Now()) != UserSuspended || user.StatusAt(until) != UserActive {
+		t.Errorf("Expected a suspension that ends at %v, got %+v", until, user)
+	}
+	if _, err := db.GetChirpForViewer(chirp.ID, 2); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected a suspended user's chirps to be hidden, got %v", err)
+	}
+
+	if _, err := db.SetUserStatus(1, 2, UserBanned, "spam", &until); err != nil {
+		t.Fatalf("Couldn't ban user: %v", err)
+	}
+	user, err = db.SetUserStatus(1, 2, UserActive, "appeal", nil)
+	if err != nil {
+		t.Fatalf("Couldn't reinstate user: %v", err)
+	}
+	if user.Restricted(time.Now()) || user.Status != "" || user.StatusReason != "" || user.SuspendedUntil != nil {
+		t.Errorf("Expected an active user, got %+v", user)
+	}
+	if _, err := db.GetChirpForViewer(chirp.ID, 2); err != nil {
+		t.Errorf("Expected the chirp to be back, got %v", err)
+	}
+
+	history, err := db.GetStatusHistory(1)
+	if err != nil {
+		t.Fatalf("Couldn't get status history: %v", err)
+	}
+	actions := make([]ModerationAction, 0, len(history))
+	for _, entry := range history {
+		actions = append(actions, entry.Action)
+	}
+	if len(actions) != 3 || actions[0] != ModerationReinstate || actions[1] != ModerationBan || actions[2] != ModerationSuspend {
+		t.Errorf("Expected the status history newest first, got %v", actions)
+	}
+	if history[1].SuspendedUntil != nil {
+		t.Errorf("Expected bans to have no end, got %v", history[1].SuspendedUntil)
+	}
+}
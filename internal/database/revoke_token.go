@@ -3,22 +3,17 @@ package database
 import "time"
 
 func (db *DB) GetTokenIsRevoked(token string) (bool, error) {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return false, err
-	}
-	if _, ok := dbStructure.RevokedTokens[token]; ok {
-		return true, nil
-	}
-	return false, nil
+	isRevoked := false
+	err := db.view(func(dbStructure *DBStructure) error {
+		_, isRevoked = dbStructure.RevokedTokens[token]
+		return nil
+	})
+	return isRevoked, err
 }
 
 func (db *DB) AddRevokedToken(token string) error {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return err
-	}
-	dbStructure.RevokedTokens[token] = time.Now()
-	db.writeDB(dbStructure)
-	return nil
+	return db.update(func(dbStructure *DBStructure) error {
+		dbStructure.RevokedTokens[token] = time.Now()
+		return nil
+	})
 }
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"slices"
+	"time"
+)
+
+// Role grants a user permissions beyond those of a regular user.
+type Role string
+
+const (
+	RoleAdmin     Role = "admin"
+	RoleModerator Role = "moderator"
+)
+
+// Roles lists every role in a stable order.
+var Roles = []Role{RoleAdmin, RoleModerator}
+
+func (r Role) Valid() bool {
+	return slices.Contains(Roles, r)
+}
+
+func (user User) HasRole(role Role) bool {
+	return slices.Contains(user.Roles, role)
+}
+
+// GrantRole gives the user role. Granting a role the user has is a no-op.
+func (db *DB) GrantRole(userID int, role Role) (User, error) {
+	return db.updateRoles(userID, func(user *User) {
+		if !user.HasRole(role) {
+			user.Roles = append(user.Roles, role)
+			slices.Sort(user.Roles)
+		}
+	})
+}
+
+// RevokeRole takes role away from the user. Revoking a role the user
+// doesn't have is a no-op.
+func (db *DB) RevokeRole(userID int, role Role) (User, error) {
+	return db.updateRoles(userID, func(user *User) {
+		user.Roles = slices.DeleteFunc(user.Roles, func(r Role) bool {
+			return r == role
+		})
+		if len(user.Roles) == 0 {
+			user.Roles = nil
+		}
+	})
+}
+
+func (db *DB) updateRoles(userID int, change func(user *User)) (User, error) {
+	var user User
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.Users[userID]
+		if !ok {
+			return ErrNotExist
+		}
+		user.Roles = slices.Clone(user.Roles)
+		change(&user)
+		user.UpdatedAt = time.Now().UTC()
+		dbStructure.Users[userID] = user
+		return nil
+	})
+	return user, err
+}
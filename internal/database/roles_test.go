@@ -0,0 +1,38 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRoles(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.CreateUser("user@example.com", "password"); err != nil {
+		t.Fatalf("Couldn't create user: %v", err)
+	}
+
+	for _, role := range []Role{RoleModerator, RoleAdmin, RoleModerator} {
+		if _, err := db.GrantRole(1, role); err != nil {
+			t.Fatalf("Couldn't grant %s: %v", role, err)
+		}
+	}
+	user, err := db.GetUser(1)
+	if err != nil {
+		t.Fatalf("Couldn't get user: %v", err)
+	}
+	if !reflect.DeepEqual(user.Roles, []Role{RoleAdmin, RoleModerator}) {
+		t.Errorf("Expected each role once, got %v", user.Roles)
+	}
+
+	user, err = db.RevokeRole(1, RoleAdmin)
+	if err != nil {
+		t.Fatalf("Couldn't revoke role: %v", err)
+	}
+	if user.HasRole(RoleAdmin) || !user.HasRole(RoleModerator) {
+		t.Errorf("Expected only the moderator role, got %v", user.Roles)
+	}
+	if _, err := db.GrantRole(2, RoleAdmin); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist for a missing user, got %v", err)
+	}
+}
@@ -0,0 +1,411 @@
+package database
+
+import (
+	"errors"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// SearchQuery searches chirp bodies, or usernames, display names and bios
+// for users. Text supports plain terms, "quoted phrases" and prefix* terms,
+// all of which must match. The other filters only apply to chirps.
+//
+// Results are read on behalf of ViewerID like a ChirpQuery, except that
+// unlisted chirps are only found by their author. Users who block or are blocked by the
+// viewer are left out of user results.
+type SearchQuery struct {
+	ViewerID int
+	Text     string
+	AuthorID int
+	Hashtag  string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+	Cursor   string
+}
+
+type ChirpSearchResult struct {
+	Chirp   Chirp
+	Score   float64
+	Snippet string
+}
+
+type ChirpSearchPage struct {
+	Results    []ChirpSearchResult
+	NextCursor string
+}
+
+type UserSearchResult struct {
+	User    User
+	Score   float64
+	Snippet string
+}
+
+type UserSearchPage struct {
+	Results    []UserSearchResult
+	NextCursor string
+}
+
+// searchToken is a case-folded word and where it was found, in code points.
+type searchToken struct {
+	term  string
+	start int
+	end   int
+}
+
+// tokenize splits text into words of letters, digits and underscores,
+// folding case so matching is case-insensitive.
+func tokenize(text string) []searchToken {
+	tokens := make([]searchToken, 0)
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if !isWordRune(runes[i]) {
+			continue
+		}
+		end := scanWhile(runes, i, isWordRune)
+		tokens = append(tokens, searchToken{
+			term:  strings.ToLower(string(runes[i:end])),
+			start: i,
+			end:   end,
+		})
+		i = end
+	}
+	return tokens
+}
+
+// searchClause is one part of a parsed query. A clause with several terms
+// is a phrase, and a prefix clause matches any term starting with its term.
+type searchClause struct {
+	terms  []string
+	prefix bool
+}
+
+func parseSearchQuery(text string) ([]searchClause, error) {
+	clauses := make([]searchClause, 0)
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if unicode.IsSpace(runes[i]) {
+			continue
+		}
+		var word string
+		prefix := false
+		if runes[i] == '"' {
+			end := scanWhile(runes, i+1, func(r rune) bool { return r != '"' })
+			word = string(runes[i+1 : end])
+			i = end
+		} else {
+			end := scanWhile(runes, i, func(r rune) bool { return !unicode.IsSpace(r) })
+			word = string(runes[i:end])
+			prefix = strings.HasSuffix(word, "*")
+			i = end
+		}
+
+		tokens := tokenize(word)
+		if len(tokens) == 0 {
+			continue
+		}
+		clause := searchClause{prefix: prefix && len(tokens) == 1}
+		for _, token := range tokens {
+			clause.terms = append(clause.terms, token.term)
+		}
+		clauses = append(clauses, clause)
+	}
+	if len(clauses) == 0 {
+		return nil, ErrInvalidQuery
+	}
+	return clauses, nil
+}
+
+// searchIndex is an inverted index from terms to the documents containing
+// them and the token positions they appear at.
+type searchIndex struct {
+	postings map[string]map[int][]int
+	docTerms map[int][]string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[int][]int),
+		docTerms: make(map[int][]string),
+	}
+}
+
+func (idx *searchIndex) add(docID int, text string) {
+	idx.remove(docID)
+	for position, token := range tokenize(text) {
+		docs, ok := idx.postings[token.term]
+		if !ok {
+			docs = make(map[int][]int)
+			idx.postings[token.term] = docs
+		}
+		if len(docs[docID]) == 0 {
+			idx.docTerms[docID] = append(idx.docTerms[docID], token.term)
+		}
+		docs[docID] = append(docs[docID], position)
+	}
+}
+
+func (idx *searchIndex) remove(docID int) {
+	for _, term := range idx.docTerms[docID] {
+		delete(idx.postings[term], docID)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.docTerms, docID)
+}
+
+// search returns the score of every document matching all clauses. Terms
+// are weighted by how often they appear in a document and by how rare they
+// are across documents.
+func (idx *searchIndex) search(clauses []searchClause) map[int]float64 {
+	var scores map[int]float64
+	for _, clause := range clauses {
+		matches := idx.matchClause(clause)
+		if scores == nil {
+			scores = matches
+			continue
+		}
+		for docID, score := range scores {
+			if match, ok := matches[docID]; ok {
+				scores[docID] = score + match
+			} else {
+				delete(scores, docID)
+			}
+		}
+	}
+	return scores
+}
+
+func (idx *searchIndex) idf(term string) float64 {
+	return math.Log(1 + float64(len(idx.docTerms))/float64(1+len(idx.postings[term])))
+}
+
+func (idx *searchIndex) matchClause(clause searchClause) map[int]float64 {
+	matches := make(map[int]float64)
+	switch {
+	case clause.prefix:
+		for term, docs := range idx.postings {
+			if !strings.HasPrefix(term, clause.terms[0]) {
+				continue
+			}
+			idf := idx.idf(term)
+			for docID, positions := range docs {
+				matches[docID] += float64(len(positions)) * idf
+			}
+		}
+	case len(clause.terms) == 1:
+		idf := idx.idf(clause.terms[0])
+		for docID, positions := range idx.postings[clause.terms[0]] {
+			matches[docID] = float64(len(positions)) * idf
+		}
+	default:
+		idf := 0.0
+		for _, term := range clause.terms {
+			idf += idx.idf(term)
+		}
+		for docID, positions := range idx.postings[clause.terms[0]] {
+			count := 0
+			for _, start := range positions {
+				if idx.hasPhraseAt(docID, clause.terms[1:], start+1) {
+					count++
+				}
+			}
+			if count > 0 {
+				matches[docID] = float64(count) * idf
+			}
+		}
+	}
+	return matches
+}
+
+func (idx *searchIndex) hasPhraseAt(docID int, terms []string, position int) bool {
+	for i, term := range terms {
+		positions := idx.postings[term][docID]
+		j := sort.SearchInts(positions, position+i)
+		if j == len(positions) || positions[j] != position+i {
+			return false
+		}
+	}
+	return true
+}
+
+// highlight HTML-escapes text and wraps the words matching the query in
+// <mark> tags.
+func highlight(text string, clauses []searchClause) string {
+	runes := []rune(text)
+	var b strings.Builder
+	last := 0
+	for _, token := range tokenize(text) {
+		if !clausesMatchTerm(clauses, token.term) {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[last:token.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[token.start:token.end])))
+		b.WriteString("</mark>")
+		last = token.end
+	}
+	b.WriteString(html.EscapeString(string(runes[last:])))
+	return b.String()
+}
+
+func clausesMatchTerm(clauses []searchClause, term string) bool {
+	for _, clause := range clauses {
+		for _, clauseTerm := range clause.terms {
+			if term == clauseTerm || (clause.prefix && strings.HasPrefix(term, clauseTerm)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rankedIDs orders matching documents by score, newest first on ties.
+func rankedIDs(scores map[int]float64) []int {
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] > ids[j]
+	})
+	return ids
+}
+
+// searchOffset decodes a search cursor, which is the number of ranked
+// results already returned.
+func searchOffset(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	return decodeCursor("search", cursor)
+}
+
+func (db *DB) SearchChirps(query SearchQuery) (ChirpSearchPage, error) {
+	clauses, err := parseSearchQuery(query.Text)
+	if err != nil {
+		return ChirpSearchPage{}, err
+	}
+	offset, err := searchOffset(query.Cursor)
+	if err != nil {
+		return ChirpSearchPage{}, err
+	}
+	limit := clampLimit(query.Limit)
+	hashtag := NormalizeHashtag(query.Hashtag)
+
+	page := ChirpSearchPage{Results: make([]ChirpSearchResult, 0, limit)}
+	err = db.view(func(dbStructure *DBStructure) error {
+		scores := dbStructure.chirpSearch.search(clauses)
+		skipped := 0
+		for _, id := range rankedIDs(scores) {
+			chirp := dbStructure.Chirps[id]
+			if query.AuthorID != 0 && chirp.AuthorId != query.AuthorID {
+				continue
+			}
+			if !dbStructure.canView(query.ViewerID, chirp) {
+				continue
+			}
+			if !chirp.Listed() && chirp.AuthorId != query.ViewerID {
+				continue
+			}
+			if query.AuthorID == 0 && dbStructure.muted(query.ViewerID, chirp.AuthorId) {
+				continue
+			}
+			if hashtag != "" && !indexContains(dbStructure.chirpsByHashtag[hashtag], id) {
+				continue
+			}
+			if !query.Since.IsZero() && chirp.CreatedAt.Before(query.Since) {
+				continue
+			}
+			if !query.Until.IsZero() && chirp.CreatedAt.After(query.Until) {
+				continue
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if len(page.Results) == limit {
+				page.NextCursor = encodeCursor("search", offset+limit)
+				break
+			}
+			page.Results = append(page.Results, ChirpSearchResult{
+				Chirp:   chirp,
+				Score:   scores[id],
+				Snippet: highlight(chirp.Body, clauses),
+			})
+		}
+		return nil
+	})
+	return page, err
+}
+
+// SearchUsers ranks user profiles against query.Text. Users on either side
+// of a block with the viewer, users the viewer mutes, and suspended, banned
+// or deleted users are left out.
+func (db *DB) SearchUsers(query SearchQuery) (UserSearchPage, error) {
+	clauses, err := parseSearchQuery(query.Text)
+	if err != nil {
+		return UserSearchPage{}, err
+	}
+	offset, err := searchOffset(query.Cursor)
+	if err != nil {
+		return UserSearchPage{}, err
+	}
+	limit := clampLimit(query.Limit)
+
+	page := UserSearchPage{Results: make([]UserSearchResult, 0, limit)}
+	err = db.view(func(dbStructure *DBStructure) error {
+		scores := dbStructure.userSearch.search(clauses)
+		now := time.Now()
+		skipped := 0
+		for _, id := range rankedIDs(scores) {
+			user := dbStructure.Users[id]
+			if dbStructure.blocked(query.ViewerID, id) || dbStructure.muted(query.ViewerID, id) {
+				continue
+			}
+			if id != query.ViewerID && (user.Restricted(now) || user.PendingDeletion()) {
+				continue
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if len(page.Results) == limit {
+				page.NextCursor = encodeCursor("search", offset+limit)
+				break
+			}
+			page.Results = append(page.Results, UserSearchResult{
+				User:    user,
+				Score:   scores[user.ID],
+				Snippet: highlight(userSearchText(user), clauses),
+			})
+		}
+		return nil
+	})
+	return page, err
+}
+
+// userSearchText is the searchable text of a user. Emails are never
+// indexed.
+func userSearchText(user User) string {
+	return strings.Join([]string{user.Username, user.DisplayName, user.Bio}, " ")
+}
+
+func (dbStructure *DBStructure) indexUser(user User) {
+	dbStructure.userSearch.add(user.ID, userSearchText(user))
+}
+
+func indexContains(ids []int, id int) bool {
+	i := sort.SearchInts(ids, id)
+	return i < len(ids) && ids[i] == id
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSearchChirps(t *testing.T) {
+	db := newTestDB(t)
+	bodies := []string{
+		"Learning Go is fun",
+		"go go GO, the best language",
+		"Going to the beach with #golang friends",
+		"Fun at the beach",
+		"the fun language of go",
+	}
+	for i, body := range bodies {
+		if _, err := db.CreateChirp(Chirp{Body: body, AuthorId: 1 + i%2}); err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+	}
+	if _, err := db.UpdateChirp(4, "Sunset at the lake", nil); err != nil {
+		t.Fatalf("Couldn't update chirp: %v", err)
+	}
+	if err := db.DeleteChirp(5); err != nil {
+		t.Fatalf("Couldn't delete chirp: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		query SearchQuery
+		ids   []int
+	}{
+		{name: "case folded and ranked", query: SearchQuery{Text: "go"}, ids: []int{2, 1}},
+		{name: "all terms", query: SearchQuery{Text: "fun go"}, ids: []int{1}},
+		{name: "phrase", query: SearchQuery{Text: `"best language"`}, ids: []int{2}},
+		{name: "phrase out of order", query: SearchQuery{Text: `"language best"`}, ids: []int{}},
+		{name: "prefix", query: SearchQuery{Text: "go*"}, ids: []int{2, 3, 1}},
+		{name: "edited body", query: SearchQuery{Text: "beach"}, ids: []int{3}},
+		{name: "author", query: SearchQuery{Text: "go*", AuthorID: 1}, ids: []int{3, 1}},
+		{name: "hashtag", query: SearchQuery{Text: "go*", Hashtag: "#GoLang"}, ids: []int{3}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			page, err := db.SearchChirps(c.query)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			ids := make([]int, 0)
+			for _, result := range page.Results {
+				ids = append(ids, result.Chirp.ID)
+			}
+			if !reflect.DeepEqual(ids, c.ids) {
+				t.Errorf("Expected %v, got %v", c.ids, ids)
+			}
+		})
+	}
+
+	page, err := db.SearchChirps(SearchQuery{Text: "go*", Limit: 2})
+	if err != nil || page.NextCursor == "" || len(page.Results) != 2 {
+		t.Fatalf("Expected a full first page with a cursor, got %+v, %v", page, err)
+	}
+	page, err = db.SearchChirps(SearchQuery{Text: "go*", Limit: 2, Cursor: page.NextCursor})
+	if err != nil || page.NextCursor != "" || len(page.Results) != 1 || page.Results[0].Chirp.ID != 1 {
+		t.Fatalf("Expected the last result on the second page, got %+v, %v", page, err)
+	}
+
+	if _, err := db.SearchChirps(SearchQuery{Text: `" * "`}); err != ErrInvalidQuery {
+		t.Errorf("Expected ErrInvalidQuery for an empty query, got %v", err)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	clauses, err := parseSearchQuery(`"the beach" fri*`)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	got := highlight("To The beach with <b>friends</b>", clauses)
+	want := "To <mark>The</mark> <mark>beach</mark> with &lt;b&gt;<mark>friends</mark>&lt;/b&gt;"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestSearchUsersHidesUnavailableUsers(t *testing.T) {
+	db := newTestDB(t)
+	names := []string{"viewer_bird", "active_bird", "banned_bird", "leaving_bird", "muted_bird", "blocked_bird"}
+	for _, name := range names {
+		if _, err := db.CreateUser(name+"@example.com", "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	for i, name := range names {
+		if _, err := db.UpdateProfile(i+1, Profile{Username: name, DisplayName: "Bird"}); err != nil {
+			t.Fatalf("Couldn't update profile: %v", err)
+		}
+	}
+	if _, err := db.SetUserStatus(3, 1, UserBanned, "spam", nil); err != nil {
+		t.Fatalf("Couldn't ban user: %v", err)
+	}
+	if _, err := db.RequestDeletion(4, time.Hour); err != nil {
+		t.Fatalf("Couldn't request deletion: %v", err)
+	}
+	if err := db.Mute(1, 5); err != nil {
+		t.Fatalf("Couldn't mute: %v", err)
+	}
+	if err := db.Block(6, 1); err != nil {
+		t.Fatalf("Couldn't block: %v", err)
+	}
+
+	search := func(viewerID int) []int {
+		t.Helper()
+		page, err := db.SearchUsers(SearchQuery{Text: "bird", ViewerID: viewerID})
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		ids := make([]int, 0)
+		for _, result := range page.Results {
+			ids = append(ids, result.User.ID)
+		}
+		sort.Ints(ids)
+		return ids
+	}
+	if ids := search(1); !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("Expected only the viewer and the active user, got %v", ids)
+	}
+	if ids := search(0); !reflect.DeepEqual(ids, []int{1, 2, 5, 6}) {
+		t.Errorf("Expected anonymous viewers to miss only unavailable users, got %v", ids)
+	}
+	if ids := search(3); !reflect.DeepEqual(ids, []int{1, 2, 3, 5, 6}) {
+		t.Errorf("Expected a banned user to still find themself, got %v", ids)
+	}
+}
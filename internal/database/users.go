@@ -3,42 +3,94 @@ package database
 import (
 	"errors"
 	"log"
+	"strings"
+	"time"
 )
 
 type User struct {
-	ID          int    `json:"id"`
-	Email       string `json:"email"`
-	Password    string `json:"password,omitempty"`
-	IsChirpyRed bool   `json:"is_chirpy_red"`
+	ID          int       `json:"id"`
+	Email       string    `json:"email"`
+	Password    string    `json:"password,omitempty"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Username    string    `json:"username,omitempty"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Bio         string    `json:"bio,omitempty"`
+	Website     string    `json:"website,omitempty"`
+	Avatar      string    `json:"avatar,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	PinnedChirpID int `json:"pinned_chirp_id,omitempty"`
+
+	Roles          []Role     `json:"roles,omitempty"`
+	Status         UserStatus `json:"status,omitempty"`
+	StatusReason   string     `json:"status_reason,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	TokensValidAfter    *time.Time `json:"tokens_valid_after,omitempty"`
 }
 
-func (db *DB) CreateUser(email, password string) (User, error) {
-	if _, err := db.GetUserByEmail(email); !errors.Is(err, ErrNotExist) {
-		return User{}, ErrAlreadyExist
-	}
+// UserStatus is a user's standing with the moderators. Active users are
+// stored with an empty Status, and a suspension ends by itself once
+// SuspendedUntil passes, so read it with StatusAt.
+type UserStatus string
 
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		log.Printf("LOAD DB ERROR, %v", err)
-		return User{}, err
-	}
+const (
+	UserActive    UserStatus = "active"
+	UserSuspended UserStatus = "suspended"
+	UserBanned    UserStatus = "banned"
+)
 
-	lastID := 0
-	if len(dbStructure.Users) > 0 {
-		lastUser := dbStructure.Users[len(dbStructure.Users)]
-		lastID = lastUser.ID
+var ErrInvalidStatus = errors.New("invalid status")
+
+func (s UserStatus) Valid() bool {
+	switch s {
+	case UserActive, UserSuspended, UserBanned:
+		return true
 	}
-	newUser := User{
-		Email:       email,
-		Password:    password,
-		ID:          lastID + 1,
-		IsChirpyRed: false,
+	return false
+}
+
+// StatusAt returns the user's status at now.
+func (user User) StatusAt(now time.Time) UserStatus {
+	switch {
+	case user.Status == UserBanned:
+		return UserBanned
+	case user.Status == UserSuspended && user.SuspendedUntil != nil && now.Before(*user.SuspendedUntil):
+		return UserSuspended
 	}
-	dbStructure.Users[newUser.ID] = newUser
+	return UserActive
+}
+
+// Restricted reports whether the user is suspended or banned at now.
+func (user User) Restricted(now time.Time) bool {
+	return user.StatusAt(now) != UserActive
+}
 
-	err = db.writeDB(dbStructure)
+func (db *DB) CreateUser(email, password string) (User, error) {
+	var newUser User
+	err := db.update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.userByEmail(email); ok {
+			return ErrAlreadyExist
+		}
+
+		now := time.Now().UTC()
+		newUser = User{
+			Email:       email,
+			Password:    password,
+			ID:          dbStructure.nextID("users"),
+			IsChirpyRed: false,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		dbStructure.Users[newUser.ID] = newUser
+		return nil
+	})
 	if err != nil {
-		log.Printf("WRITE DB ERROR, %v", err)
+		if !errors.Is(err, ErrAlreadyExist) {
+			log.Printf("WRITE DB ERROR, %v", err)
+		}
 		return User{}, err
 	}
 
@@ -46,67 +98,262 @@ func (db *DB) CreateUser(email, password string) (User, error) {
 }
 
 func (db *DB) GetUserByEmail(email string) (User, error) {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return User{}, err
-	}
-
-	for _, user := range dbStructure.Users {
-		if user.Email == email {
-			return user, nil
+	var user User
+	err := db.view(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.userByEmail(email)
+		if !ok {
+			return ErrNotExist
 		}
-	}
-
-	return User{}, ErrNotExist
+		return nil
+	})
+	return user, err
 }
 
 func (db *DB) GetUser(id int) (User, error) {
-	dbStructure, err := db.loadDB()
+	var user User
+	err := db.view(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.Users[id]
+		if !ok {
+			return ErrNotExist
+		}
+		return nil
+	})
+	return user, err
+}
+
+func (db *DB) UpdateUser(id int, email, password string) (User, error) {
+	var user User
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.Users[id]
+		if !ok {
+			return ErrNotExist
+		}
+
+		user.Email = email
+		user.Password = password
+		user.UpdatedAt = time.Now().UTC()
+		dbStructure.Users[id] = user
+		return nil
+	})
 	if err != nil {
 		return User{}, err
 	}
+	return user, nil
+}
 
-	user, ok := dbStructure.Users[id]
-	if !ok {
-		return User{}, ErrNotExist
-	}
+// Profile holds the public, user-editable fields of a User.
+type Profile struct {
+	Username    string
+	DisplayName string
+	Bio         string
+	Website     string
+}
+
+// UpdateProfile replaces the user's profile. Usernames are unique ignoring
+// case, and taking one that is in use returns ErrAlreadyExist.
+func (db *DB) UpdateProfile(id int, profile Profile) (User, error) {
+	var user User
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.Users[id]
+		if !ok {
+			return ErrNotExist
+		}
+		key := strings.ToLower(profile.Username)
+		if ownerID, taken := dbStructure.usersByUsername[key]; taken && ownerID != id {
+			return ErrAlreadyExist
+		}
 
+		delete(dbStructure.usersByUsername, strings.ToLower(user.Username))
+		if key != "" {
+			dbStructure.usersByUsername[key] = id
+		}
+		user.Username = profile.Username
+		user.DisplayName = profile.DisplayName
+		user.Bio = profile.Bio
+		user.Website = profile.Website
+		user.UpdatedAt = time.Now().UTC()
+		dbStructure.Users[id] = user
+		dbStructure.indexUser(user)
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
 	return user, nil
 }
 
-func (db *DB) UpdateUser(id int, email, password string) (User, error) {
-	dbStructure, err := db.loadDB()
+// PinChirp pins one of the user's chirps to their profile, replacing any
+// chirp pinned before. Chirps by other users return ErrNotExist.
+func (db *DB) PinChirp(userID, chirpID int) (User, error) {
+	var user User
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.Users[userID]
+		if !ok {
+			return ErrNotExist
+		}
+		chirp, ok := dbStructure.Chirps[chirpID]
+		if !ok || chirp.Deleted || chirp.AuthorId != userID {
+			return ErrNotExist
+		}
+		user.PinnedChirpID = chirpID
+		dbStructure.Users[userID] = user
+		return nil
+	})
 	if err != nil {
 		return User{}, err
 	}
+	return user, nil
+}
 
-	user, ok := dbStructure.Users[id]
-	if !ok {
-		return User{}, ErrNotExist
+// UnpinChirp unpins chirpID from the user's profile. Unpinning a chirp
+// that isn't pinned is a no-op.
+func (db *DB) UnpinChirp(userID, chirpID int) (User, error) {
+	var user User
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.Users[userID]
+		if !ok {
+			return ErrNotExist
+		}
+		if user.PinnedChirpID == chirpID {
+			user.PinnedChirpID = 0
+			dbStructure.Users[userID] = user
+		}
+		return nil
+	})
+	if err != nil {
+		return User{}, err
 	}
+	return user, nil
+}
 
-	user.Email = email
-	user.Password = password
-	dbStructure.Users[id] = user
-	db.writeDB(dbStructure)
+func (db *DB) GetUserByUsername(username string) (User, error) {
+	var user User
+	err := db.view(func(dbStructure *DBStructure) error {
+		id, ok := dbStructure.usersByUsername[strings.ToLower(username)]
+		if !ok {
+			return ErrNotExist
+		}
+		user = dbStructure.Users[id]
+		return nil
+	})
+	return user, err
+}
 
-	return user, nil
+// GetUsers looks up several users at once. Missing users are left out of
+// the result.
+func (db *DB) GetUsers(ids []int) (map[int]User, error) {
+	users := make(map[int]User, len(ids))
+	err := db.view(func(dbStructure *DBStructure) error {
+		for _, id := range ids {
+			if user, ok := dbStructure.Users[id]; ok {
+				users[id] = user
+			}
+		}
+		return nil
+	})
+	return users, err
 }
 
 func (db *DB) UpgradeUser(id int) error {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return err
-	}
+	return db.update(func(dbStructure *DBStructure) error {
+		user, ok := dbStructure.Users[id]
+		if !ok {
+			return ErrNotExist
+		}
+
+		user.IsChirpyRed = true
+		user.UpdatedAt = time.Now().UTC()
+		dbStructure.Users[id] = user
+		return nil
+	})
+}
 
+// SetUserStatus changes a user's status outside of a report and records it
+// in the moderation log. Suspensions need a suspendedUntil in the future,
+// which is ignored for the other statuses.
+func (db *DB) SetUserStatus(id, moderatorID int, status UserStatus, reason string, suspendedUntil *time.Time) (User, error) {
+	var user User
+	err := db.update(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.Users[id]
+		if !ok {
+			return ErrNotExist
+		}
+		now := time.Now().UTC()
+		action := ModerationReinstate
+		switch status {
+		case UserSuspended:
+			if suspendedUntil == nil || !suspendedUntil.After(now) {
+				return ErrInvalidStatus
+			}
+			action = ModerationSuspend
+		case UserBanned:
+			action = ModerationBan
+			suspendedUntil = nil
+		case UserActive:
+			suspendedUntil = nil
+		default:
+			return ErrInvalidStatus
+		}
+		dbStructure.setUserStatus(id, status, reason, suspendedUntil, now)
+		dbStructure.logModeration(ModerationLogEntry{
+			ModeratorID:    moderatorID,
+			Action:         action,
+			UserID:         id,
+			Note:           reason,
+			SuspendedUntil: suspendedUntil,
+		})
+		user = dbStructure.Users[id]
+		return nil
+	})
+	return user, err
+}
+
+// GetStatusHistory returns every status change of the user, newest first.
+func (db *DB) GetStatusHistory(id int) ([]ModerationLogEntry, error) {
+	history := make([]ModerationLogEntry, 0)
+	err := db.view(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[id]; !ok {
+			return ErrNotExist
+		}
+		for i := len(dbStructure.ModerationLog) - 1; i >= 0; i-- {
+			entry := dbStructure.ModerationLog[i]
+			if entry.UserID == id && entry.Action.ChangesStatus() {
+				history = append(history, entry)
+			}
+		}
+		return nil
+	})
+	return history, err
+}
+
+func (dbStructure *DBStructure) setUserStatus(id int, status UserStatus, reason string, suspendedUntil *time.Time, now time.Time) {
 	user, ok := dbStructure.Users[id]
 	if !ok {
-		return ErrNotExist
+		return
 	}
-
-	user.IsChirpyRed = true
+	if status == UserActive {
+		status = ""
+		reason = ""
+	}
+	user.Status = status
+	user.StatusReason = reason
+	user.SuspendedUntil = suspendedUntil
+	user.UpdatedAt = now
 	dbStructure.Users[id] = user
-	db.writeDB(dbStructure)
+}
 
-	return nil
+func (dbStructure *DBStructure) userByEmail(email string) (User, bool) {
+	for _, user := range dbStructure.Users {
+		if user.Email == email {
+			return user, true
+		}
+	}
+	return User{}, false
 }
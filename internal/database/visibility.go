@@ -0,0 +1,86 @@
+package database
+
+import "time"
+
+// Visibility controls who can read a chirp.
+type Visibility string
+
+const (
+	// VisibilityPublic chirps can be read by anyone and appear in every
+	// listing.
+	VisibilityPublic Visibility = "public"
+	// VisibilityFollowers chirps can be read by the author's followers and
+	// the users mentioned in them.
+	VisibilityFollowers Visibility = "followers"
+	// VisibilityUnlisted chirps can be read by anyone with a link, but are
+	// left out of the global listing, hashtags, search and trending. They
+	// still appear on the author's profile and in home timelines.
+	VisibilityUnlisted Visibility = "unlisted"
+	// VisibilityMentioned chirps can only be read by the users mentioned in
+	// them.
+	VisibilityMentioned Visibility = "mentioned"
+)
+
+func (v Visibility) Valid() bool {
+	switch v {
+	case VisibilityPublic, VisibilityFollowers, VisibilityUnlisted, VisibilityMentioned:
+		return true
+	}
+	return false
+}
+
+// Listed reports whether chirp belongs in listings that aren't scoped to
+// particular authors, such as hashtags and search.
+func (chirp Chirp) Listed() bool {
+	return chirp.Visibility != VisibilityUnlisted
+}
+
+// canView reports whether viewerID may see chirp. Zero is an anonymous
+// viewer. Authors can always see their own chirps, while held chirps,
+// chirps hidden by a moderator and chirps by suspended or banned users or
+// users pending deletion are only visible to them.
+func (dbStructure *DBStructure) canView(viewerID int, chirp Chirp) bool {
+	if dbStructure.blocked(viewerID, chirp.AuthorId) {
+		return false
+	}
+	if viewerID != 0 && viewerID == chirp.AuthorId {
+		return true
+	}
+	author := dbStructure.Users[chirp.AuthorId]
+	if chirp.Hold != nil || chirp.Hidden || author.Restricted(time.Now()) || author.PendingDeletion() {
+		return false
+	}
+	switch chirp.Visibility {
+	case VisibilityFollowers:
+		if _, following := dbStructure.Following[viewerID][chirp.AuthorId]; following {
+			return true
+		}
+		return chirp.mentions(viewerID)
+	case VisibilityMentioned:
+		return chirp.mentions(viewerID)
+	}
+	return true
+}
+
+func (chirp Chirp) mentions(userID int) bool {
+	if userID == 0 {
+		return false
+	}
+	for _, mentionedID := range chirp.mentionedUserIDs() {
+		if mentionedID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// CanView reports whether viewerID may see chirp, for chirps that were read
+// without a viewer, such as those sent to streams.
+func (db *DB) CanView(viewerID int, chirp Chirp) (bool, error) {
+	var ok bool
+	err := db.view(func(dbStructure *DBStructure) error {
+		ok = dbStructure.canView(viewerID, chirp)
+		return nil
+	})
+	return ok, err
+}
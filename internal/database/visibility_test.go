@@ -0,0 +1,107 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestChirpVisibility(t *testing.T) {
+	db := newTestDB(t)
+	for _, email := range []string{"author@example.com", "follower@example.com", "friend@example.com", "stranger@example.com"} {
+		if _, err := db.CreateUser(email, "password"); err != nil {
+			t.Fatalf("Couldn't create user: %v", err)
+		}
+	}
+	if _, err := db.UpdateProfile(3, Profile{Username: "friend"}); err != nil {
+		t.Fatalf("Couldn't update profile: %v", err)
+	}
+	if err := db.Follow(2, 1); err != nil {
+		t.Fatalf("Couldn't follow: %v", err)
+	}
+
+	chirps := make(map[Visibility]Chirp)
+	for _, visibility := range []Visibility{"", VisibilityFollowers, VisibilityUnlisted, VisibilityMentioned} {
+		chirp, err := db.CreateChirp(Chirp{Body: "news for @friend", AuthorId: 1, Visibility: visibility})
+		if err != nil {
+			t.Fatalf("Couldn't create chirp: %v", err)
+		}
+		chirps[visibility] = chirp
+	}
+	if chirps[""].Visibility != VisibilityPublic {
+		t.Errorf("Expected chirps to be public by default, got %q", chirps[""].Visibility)
+	}
+
+	const anonymous, author, follower, friend, stranger = 0, 1, 2, 3, 4
+	tests := []struct {
+		visibility Visibility
+		visibleTo  []int
+	}{
+		{"", []int{anonymous, author, follower, friend, stranger}},
+		{VisibilityFollowers, []int{author, follower, friend}},
+		{VisibilityUnlisted, []int{anonymous, author, follower, friend, stranger}},
+		{VisibilityMentioned, []int{author, friend}},
+	}
+	for _, tt := range tests {
+		visibleTo := make([]int, 0)
+		for _, viewerID := range []int{anonymous, author, follower, friend, stranger} {
+			_, err := db.GetChirpForViewer(chirps[tt.visibility].ID, viewerID)
+			if err == nil {
+				visibleTo = append(visibleTo, viewerID)
+			} else if !errors.Is(err, ErrNotExist) {
+				t.Fatalf("Couldn't get chirp: %v", err)
+			}
+		}
+		if !reflect.DeepEqual(visibleTo, tt.visibleTo) {
+			t.Errorf("Expected %q chirps to be visible to %v, got %v", tt.visibility, tt.visibleTo, visibleTo)
+		}
+	}
+
+	listings := []struct {
+		name  string
+		query ChirpQuery
+		want  []int
+	}{
+		{"global for strangers", ChirpQuery{ViewerID: stranger}, []int{1}},
+		{"global for followers", ChirpQuery{ViewerID: follower}, []int{1, 2}},
+		{"global for the author", ChirpQuery{ViewerID: author}, []int{1, 2, 3, 4}},
+		{"profile for strangers", ChirpQuery{ViewerID: stranger, AuthorIDs: []int{author}}, []int{1, 3}},
+		{"profile for friends", ChirpQuery{ViewerID: friend, AuthorIDs: []int{author}}, []int{1, 2, 3, 4}},
+	}
+	for _, listing := range listings {
+		page, err := db.QueryChirps(listing.query)
+		if err != nil {
+			t.Fatalf("Couldn't query chirps: %v", err)
+		}
+		if ids := chirpIDs(page.Chirps); !reflect.DeepEqual(ids, listing.want) {
+			t.Errorf("Expected %s to list %v, got %v", listing.name, listing.want, ids)
+		}
+	}
+
+	search, err := db.SearchChirps(SearchQuery{ViewerID: friend, Text: "news"})
+	if err != nil {
+		t.Fatalf("Couldn't search chirps: %v", err)
+	}
+	found := make([]int, 0)
+	for _, result := range search.Results {
+		found = append(found, result.Chirp.ID)
+	}
+	if !reflect.DeepEqual(found, []int{4, 2, 1}) {
+		t.Errorf("Expected search to find every listed chirp the viewer can see, got %v", found)
+	}
+
+	reply, err := db.CreateChirp(Chirp{Body: "just us", AuthorId: author, InReplyTo: chirps[""].ID, Visibility: VisibilityMentioned})
+	if err != nil {
+		t.Fatalf("Couldn't reply: %v", err)
+	}
+	thread, err := db.GetThread(chirps[""].ID, stranger)
+	if err != nil {
+		t.Fatalf("Couldn't get thread: %v", err)
+	}
+	if len(thread) != 2 || thread[1].ID != reply.ID || !thread[1].Deleted {
+		t.Errorf("Expected the hidden reply as a tombstone, got %+v", thread)
+	}
+	if _, err := db.CreateChirp(Chirp{Body: "me too", AuthorId: stranger, InReplyTo: chirps[VisibilityMentioned].ID}); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected ErrNotExist replying to a hidden chirp, got %v", err)
+	}
+}
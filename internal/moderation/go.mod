@@ -0,0 +1,5 @@
+module moderation
+
+go 1.21.1
+
+require golang.org/x/text v0.13.0
@@ -0,0 +1,196 @@
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Action is what should happen to moderated content.
+type Action string
+
+const (
+	ActionAllow Action = "allow"
+	// ActionMask publishes the content with the offending words hidden.
+	ActionMask Action = "mask"
+	// ActionHold stores the content but keeps it from everyone but its
+	// author until a moderator reviews it.
+	ActionHold   Action = "hold"
+	ActionReject Action = "reject"
+)
+
+// mask replaces each masked range of the original text.
+const mask = "****"
+
+func (a Action) Valid() bool {
+	return a.severity() > 0
+}
+
+// severity orders actions from the most lenient to the strictest.
+func (a Action) severity() int {
+	switch a {
+	case ActionAllow:
+		return 1
+	case ActionMask:
+		return 2
+	case ActionHold:
+		return 3
+	case ActionReject:
+		return 4
+	}
+	return 0
+}
+
+// Verdict is a filter's opinion of a text. Masks are byte ranges of the
+// original text to hide, and are only set with ActionMask.
+type Verdict struct {
+	Action Action
+	Reason string
+	Masks  [][2]int
+}
+
+var allow = Verdict{Action: ActionAllow}
+
+// Moderator is a filter, or a chain of them.
+type Moderator interface {
+	Moderate(text *Text) Verdict
+}
+
+// Chain runs moderators in order and settles on the strictest verdict.
+// Masks from every moderator are kept, and a reject ends the chain early.
+type Chain []Moderator
+
+func (c Chain) Moderate(text *Text) Verdict {
+	result := allow
+	for _, moderator := range c {
+		verdict := moderator.Moderate(text)
+		result.Masks = append(result.Masks, verdict.Masks...)
+		if verdict.Action.severity() > result.Action.severity() {
+			result.Action = verdict.Action
+			result.Reason = verdict.Reason
+		}
+		if result.Action == ActionReject {
+			break
+		}
+	}
+	return result
+}
+
+// Decision is the outcome of moderating a body. Body has the masks applied.
+type Decision struct {
+	Action Action `json:"action"`
+	Reason string `json:"reason,omitempty"`
+	Body   string `json:"body"`
+}
+
+// Moderate runs moderator over body.
+func Moderate(moderator Moderator, body string) Decision {
+	verdict := moderator.Moderate(NewText(body))
+	return Decision{
+		Action: verdict.Action,
+		Reason: verdict.Reason,
+		Body:   applyMasks(body, verdict.Masks),
+	}
+}
+
+// applyMasks replaces each range of body with a mask, merging ranges that
+// overlap.
+func applyMasks(body string, masks [][2]int) string {
+	if len(masks) == 0 {
+		return body
+	}
+	sorted := append([][2]int(nil), masks...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+
+	var masked strings.Builder
+	written := 0
+	for _, span := range sorted {
+		if span[1] <= written {
+			continue
+		}
+		if span[0] >= written {
+			masked.WriteString(body[written:span[0]])
+			masked.WriteString(mask)
+		}
+		written = span[1]
+	}
+	masked.WriteString(body[written:])
+	return masked.String()
+}
+
+// WordList matches whole words, after folding, against a list.
+type WordList struct {
+	action Action
+	reason string
+	words  map[string]bool
+}
+
+func NewWordList(action Action, reason string, words []string) *WordList {
+	list := &WordList{action: action, reason: reason, words: make(map[string]bool, len(words))}
+	for _, word := range words {
+		if word = normalizeWord(word); word != "" {
+			list.words[word] = true
+		}
+	}
+	return list
+}
+
+func (l *WordList) Moderate(text *Text) Verdict {
+	var masks [][2]int
+	for _, word := range text.Words() {
+		for _, variant := range word.variants() {
+			if l.words[variant.Text] {
+				start, end := text.Span(variant.Start, variant.End)
+				masks = append(masks, [2]int{start, end})
+				break
+			}
+		}
+	}
+	return verdictFor(l.action, l.reason, masks)
+}
+
+// Patterns matches regular expressions against the folded text, so they
+// should be written in lowercase and plain letters.
+type Patterns struct {
+	action   Action
+	reason   string
+	patterns []*regexp.Regexp
+}
+
+func NewPatterns(action Action, reason string, patterns []string) (*Patterns, error) {
+	p := &Patterns{action: action, reason: reason}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: pattern %q: %v", ErrInvalidFilter, pattern, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+	return p, nil
+}
+
+func (p *Patterns) Moderate(text *Text) Verdict {
+	var masks [][2]int
+	for _, re := range p.patterns {
+		for _, match := range re.FindAllStringIndex(text.Folded, -1) {
+			if match[0] == match[1] {
+				continue
+			}
+			start, end := text.Span(match[0], match[1])
+			masks = append(masks, [2]int{start, end})
+		}
+	}
+	return verdictFor(p.action, p.reason, masks)
+}
+
+func verdictFor(action Action, reason string, masks [][2]int) Verdict {
+	if len(masks) == 0 {
+		return allow
+	}
+	verdict := Verdict{Action: action, Reason: reason}
+	if action == ActionMask {
+		verdict.Masks = masks
+	}
+	return verdict
+}
@@ -0,0 +1,148 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultConfigMasksProfanity(t *testing.T) {
+	chain, err := DefaultConfig().Build()
+	if err != nil {
+		t.Fatalf("Couldn't build default config: %v", err)
+	}
+	cases := []struct{ body, cleaned string }{
+		{
+			body:    "Today is such a kerfuffle day",
+			cleaned: "Today is such a **** day",
+		},
+		{
+			body:    "I like turtles",
+			cleaned: "I like turtles",
+		},
+		{
+			body:    "Everybody keeps telling me to say sharbert and fornax today, but I won't",
+			cleaned: "Everybody keeps telling me to say **** and **** today, but I won't",
+		},
+		{
+			body:    "What a Kerfuffle!",
+			cleaned: "What a ****!",
+		},
+		{
+			body:    "tabs\tkerfuffle\tand\nnewlines",
+			cleaned: "tabs\t****\tand\nnewlines",
+		},
+		{
+			body:    "k3rfuffl3 and $harbert and f0rn@x",
+			cleaned: "**** and **** and ****",
+		},
+		{
+			body:    "k\u0435rfuffl\u0435 in Cyrillic",
+			cleaned: "**** in Cyrillic",
+		},
+		{
+			body:    "ｆｏｒｎａｘ wide and fórnax accented",
+			cleaned: "**** wide and **** accented",
+		},
+		{
+			body:    "ker\u200bfuffle hidden",
+			cleaned: "**** hidden",
+		},
+		{
+			body:    "kerfuffles and fornaxian are other words",
+			cleaned: "kerfuffles and fornaxian are other words",
+		},
+	}
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			decision := Moderate(chain, c.body)
+			if decision.Body != c.cleaned {
+				t.Errorf("Expected %q, got %q", c.cleaned, decision.Body)
+			}
+		})
+	}
+}
+
+func TestChainSettlesOnStrictestVerdict(t *testing.T) {
+	chain, err := Config{Filters: []FilterConfig{
+		{Name: "profanity", Type: FilterWords, Action: ActionMask, Words: []string{"fornax"}},
+		{Name: "links", Type: FilterRegex, Action: ActionHold, Reason: "Links are reviewed", Patterns: []string{`https?://\S+`}},
+		{Name: "slurs", Type: FilterWords, Action: ActionReject, Words: []string{"sharbert"}},
+	}}.Build()
+	if err != nil {
+		t.Fatalf("Couldn't build config: %v", err)
+	}
+
+	cases := []struct {
+		body   string
+		action Action
+		reason string
+		masked string
+	}{
+		{"hello there", ActionAllow, "", "hello there"},
+		{"fornax", ActionMask, "matched the profanity filter", "****"},
+		{"fornax at HTTPS://example.com", ActionHold, "Links are reviewed", "**** at HTTPS://example.com"},
+		{"fornax sharbert http://example.com", ActionReject, "matched the slurs filter", "**** sharbert http://example.com"},
+	}
+	for _, c := range cases {
+		decision := Moderate(chain, c.body)
+		if decision.Action != c.action || decision.Reason != c.reason || decision.Body != c.masked {
+			t.Errorf("Expected %s %q %q for %q, got %+v", c.action, c.reason, c.masked, c.body, decision)
+		}
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	configs := []Config{
+		{Filters: []FilterConfig{{Type: FilterWords, Action: ActionMask}}},
+		{Filters: []FilterConfig{{Name: "a", Type: "magic", Action: ActionMask}}},
+		{Filters: []FilterConfig{{Name: "a", Type: FilterWords, Action: "delete"}}},
+		{Filters: []FilterConfig{{Name: "a", Type: FilterRegex, Action: ActionMask, Patterns: []string{"("}}}},
+		{Filters: []FilterConfig{
+			{Name: "a", Type: FilterWords, Action: ActionMask},
+			{Name: "a", Type: FilterWords, Action: ActionHold},
+		}},
+	}
+	for i, config := range configs {
+		if _, err := config.Build(); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter for config %d, got %v", i, err)
+		}
+	}
+}
+
+func TestPipelineUpdatesAreSaved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moderation.json")
+	pipeline, err := NewPipeline(path)
+	if err != nil {
+		t.Fatalf("Couldn't create pipeline: %v", err)
+	}
+
+	if _, err := pipeline.AddWords("profanity", []string{"Sharbert", "frell"}); err != nil {
+		t.Fatalf("Couldn't add words: %v", err)
+	}
+	if _, err := pipeline.RemoveWords("profanity", []string{"KERFUFFLE"}); err != nil {
+		t.Fatalf("Couldn't remove words: %v", err)
+	}
+	if _, err := pipeline.PutFilter(FilterConfig{Name: "bad", Type: FilterRegex, Action: ActionHold, Patterns: []string{"("}}); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Expected ErrInvalidFilter for a broken pattern, got %v", err)
+	}
+	if _, err := pipeline.AddWords("missing", []string{"word"}); !errors.Is(err, ErrFilterNotFound) {
+		t.Errorf("Expected ErrFilterNotFound, got %v", err)
+	}
+
+	reloaded, err := NewPipeline(path)
+	if err != nil {
+		t.Fatalf("Couldn't reload pipeline: %v", err)
+	}
+	filter, err := reloaded.Filter("profanity")
+	if err != nil {
+		t.Fatalf("Couldn't get filter: %v", err)
+	}
+	if fmt.Sprint(filter.Words) != "[sharbert fornax frell]" {
+		t.Errorf("Expected the saved word list, got %v", filter.Words)
+	}
+	if decision := reloaded.Moderate("kerfuffle frell"); decision.Body != "kerfuffle ****" {
+		t.Errorf("Expected the reloaded list to apply, got %q", decision.Body)
+	}
+}
@@ -0,0 +1,266 @@
+package moderation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	ErrInvalidFilter  = errors.New("invalid filter")
+	ErrFilterNotFound = errors.New("filter not found")
+)
+
+type FilterType string
+
+const (
+	FilterWords FilterType = "words"
+	FilterRegex FilterType = "regex"
+)
+
+// FilterConfig describes a filter. Reason is shown to authors whose content
+// is held or rejected, and defaults to naming the filter.
+type FilterConfig struct {
+	Name     string     `json:"name"`
+	Type     FilterType `json:"type"`
+	Action   Action     `json:"action"`
+	Reason   string     `json:"reason,omitempty"`
+	Words    []string   `json:"words,omitempty"`
+	Patterns []string   `json:"patterns,omitempty"`
+}
+
+type Config struct {
+	Filters []FilterConfig `json:"filters"`
+}
+
+// DefaultConfig masks the words chirpy has always masked.
+func DefaultConfig() Config {
+	return Config{Filters: []FilterConfig{{
+		Name:   "profanity",
+		Type:   FilterWords,
+		Action: ActionMask,
+		Words:  []string{"kerfuffle", "sharbert", "fornax"},
+	}}}
+}
+
+func (f FilterConfig) Build() (Moderator, error) {
+	if f.Name == "" {
+		return nil, fmt.Errorf("%w: missing name", ErrInvalidFilter)
+	}
+	if !f.Action.Valid() {
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidFilter, f.Action)
+	}
+	reason := f.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("matched the %s filter", f.Name)
+	}
+	switch f.Type {
+	case FilterWords:
+		return NewWordList(f.Action, reason, f.Words), nil
+	case FilterRegex:
+		return NewPatterns(f.Action, reason, f.Patterns)
+	}
+	return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidFilter, f.Type)
+}
+
+func (c Config) Build() (Chain, error) {
+	chain := make(Chain, 0, len(c.Filters))
+	names := make(map[string]bool, len(c.Filters))
+	for _, filter := range c.Filters {
+		if names[filter.Name] {
+			return nil, fmt.Errorf("%w: duplicate name %q", ErrInvalidFilter, filter.Name)
+		}
+		names[filter.Name] = true
+		moderator, err := filter.Build()
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, moderator)
+	}
+	return chain, nil
+}
+
+// Pipeline is a chain of filters built from a config that can be changed
+// while the server runs. Changes are saved to the config file, if it has
+// one.
+type Pipeline struct {
+	path   string
+	mux    sync.RWMutex
+	config Config
+	chain  Chain
+}
+
+// NewPipeline loads the config at path, or uses DefaultConfig if path is
+// empty or the file doesn't exist yet.
+func NewPipeline(path string) (*Pipeline, error) {
+	config := DefaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			config = Config{}
+			err = json.Unmarshal(data, &config)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("couldn't load moderation config: %w", err)
+		}
+	}
+	chain, err := config.Build()
+	if err != nil {
+		return nil, err
+	}
+	return &Pipeline{path: path, config: config, chain: chain}, nil
+}
+
+func (p *Pipeline) Moderate(body string) Decision {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return Moderate(p.chain, body)
+}
+
+// Filters returns a copy of the config of every filter, in chain order.
+func (p *Pipeline) Filters() []FilterConfig {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	filters := make([]FilterConfig, 0, len(p.config.Filters))
+	for _, filter := range p.config.Filters {
+		filters = append(filters, filter.clone())
+	}
+	return filters
+}
+
+func (p *Pipeline) Filter(name string) (FilterConfig, error) {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	i := p.config.index(name)
+	if i < 0 {
+		return FilterConfig{}, ErrFilterNotFound
+	}
+	return p.config.Filters[i].clone(), nil
+}
+
+// PutFilter replaces the filter with the same name, or adds it to the end
+// of the chain, and reports whether it was added.
+func (p *Pipeline) PutFilter(filter FilterConfig) (bool, error) {
+	var added bool
+	err := p.update(func(config *Config) error {
+		i := config.index(filter.Name)
+		added = i < 0
+		if added {
+			config.Filters = append(config.Filters, filter)
+		} else {
+			config.Filters[i] = filter
+		}
+		return nil
+	})
+	return added, err
+}
+
+func (p *Pipeline) DeleteFilter(name string) error {
+	return p.update(func(config *Config) error {
+		i := config.index(name)
+		if i < 0 {
+			return ErrFilterNotFound
+		}
+		config.Filters = append(config.Filters[:i], config.Filters[i+1:]...)
+		return nil
+	})
+}
+
+// AddWords adds words to a word list, skipping words already on it.
+func (p *Pipeline) AddWords(name string, words []string) (FilterConfig, error) {
+	return p.updateWords(name, func(filter *FilterConfig) {
+		existing := make(map[string]bool, len(filter.Words))
+		for _, word := range filter.Words {
+			existing[normalizeWord(word)] = true
+		}
+		for _, word := range words {
+			if normalized := normalizeWord(word); normalized != "" && !existing[normalized] {
+				existing[normalized] = true
+				filter.Words = append(filter.Words, word)
+			}
+		}
+	})
+}
+
+// RemoveWords removes words from a word list, including words that fold to
+// the same thing.
+func (p *Pipeline) RemoveWords(name string, words []string) (FilterConfig, error) {
+	return p.updateWords(name, func(filter *FilterConfig) {
+		removed := make(map[string]bool, len(words))
+		for _, word := range words {
+			removed[normalizeWord(word)] = true
+		}
+		kept := make([]string, 0, len(filter.Words))
+		for _, word := range filter.Words {
+			if !removed[normalizeWord(word)] {
+				kept = append(kept, word)
+			}
+		}
+		filter.Words = kept
+	})
+}
+
+func (p *Pipeline) updateWords(name string, change func(filter *FilterConfig)) (FilterConfig, error) {
+	var updated FilterConfig
+	err := p.update(func(config *Config) error {
+		i := config.index(name)
+		if i < 0 {
+			return ErrFilterNotFound
+		}
+		if config.Filters[i].Type != FilterWords {
+			return fmt.Errorf("%w: %q is not a word list", ErrInvalidFilter, name)
+		}
+		change(&config.Filters[i])
+		updated = config.Filters[i].clone()
+		return nil
+	})
+	return updated, err
+}
+
+// update applies change to a copy of the config and only swaps it in once
+// it builds and, if the pipeline has a file, has been saved.
+func (p *Pipeline) update(change func(config *Config) error) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	config := Config{Filters: make([]FilterConfig, 0, len(p.config.Filters))}
+	for _, filter := range p.config.Filters {
+		config.Filters = append(config.Filters, filter.clone())
+	}
+	if err := change(&config); err != nil {
+		return err
+	}
+	chain, err := config.Build()
+	if err != nil {
+		return err
+	}
+	if p.path != "" {
+		data, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(p.path, data, 0600); err != nil {
+			return err
+		}
+	}
+	p.config = config
+	p.chain = chain
+	return nil
+}
+
+func (c Config) index(name string) int {
+	for i, filter := range c.Filters {
+		if filter.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f FilterConfig) clone() FilterConfig {
+	f.Words = append([]string(nil), f.Words...)
+	f.Patterns = append([]string(nil), f.Patterns...)
+	return f
+}
@@ -0,0 +1,153 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Text is content prepared for filters. Folded is a lowercased copy in
+// which compatibility characters, accents and look-alike letters are folded
+// to plain Latin ones, so filters match what readers see rather than exact
+// code points. Full-width "ｋｅｒｆｕｆｆｌｅ" and Cyrillic "kеrfuffle" both
+// fold to "kerfuffle".
+type Text struct {
+	Original string
+	Folded   string
+	// starts and ends hold the byte range of Original that produced each
+	// byte of Folded.
+	starts []int
+	ends   []int
+}
+
+func NewText(original string) *Text {
+	text := &Text{Original: original}
+	var folded strings.Builder
+	for i := 0; i < len(original); {
+		r, size := utf8.DecodeRuneInString(original[i:])
+		f := foldRune(r)
+		for j := 0; j < len(f); j++ {
+			text.starts = append(text.starts, i)
+			text.ends = append(text.ends, i+size)
+		}
+		folded.WriteString(f)
+		i += size
+	}
+	text.Folded = folded.String()
+	return text
+}
+
+// foldRune applies NFKD, which maps compatibility characters like NFKC does
+// but also splits accents off their letters, then drops the accents and
+// invisible formatting characters, lowercases and folds confusables.
+func foldRune(r rune) string {
+	var folded strings.Builder
+	for _, d := range norm.NFKD.String(string(r)) {
+		if unicode.Is(unicode.Mn, d) || unicode.Is(unicode.Cf, d) {
+			continue
+		}
+		d = unicode.ToLower(d)
+		if plain, ok := confusables[d]; ok {
+			d = plain
+		}
+		folded.WriteRune(d)
+	}
+	return folded.String()
+}
+
+// confusables maps letters from other scripts that look like Latin letters
+// to the letter they imitate.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i',
+	'ј': 'j', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v',
+	'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// Latin look-alikes without a decomposition
+	'ı': 'i', 'ł': 'l', 'ø': 'o', 'đ': 'd', 'ħ': 'h', 'ŧ': 't', 'ɡ': 'g',
+}
+
+// leetspeak maps digits and symbols used in place of letters. It is only
+// applied when matching words, since folding it into Folded would turn
+// numbers and prices into letters for every filter.
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b',
+	'9': 'g', '@': 'a', '$': 's', '!': 'i', '|': 'l',
+}
+
+// Span maps a byte range of Folded back to the range of Original it came
+// from.
+func (t *Text) Span(start, end int) (int, int) {
+	if start >= end {
+		return 0, 0
+	}
+	return t.starts[start], t.ends[end-1]
+}
+
+// Word is a run of letters and digits in Folded, along with any leetspeak
+// symbols inside or around it. Start and End are byte offsets in Folded.
+type Word struct {
+	Text  string
+	Start int
+	End   int
+}
+
+func isWordRune(r rune) bool {
+	_, leet := leetspeak[r]
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || leet
+}
+
+func isLetterOrDigit(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Words splits Folded on whitespace and punctuation.
+func (t *Text) Words() []Word {
+	words := make([]Word, 0)
+	start := -1
+	for i, r := range t.Folded + " " {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, Word{Text: t.Folded[start:i], Start: start, End: i})
+			start = -1
+		}
+	}
+	return words
+}
+
+// variants returns the ways a word may be read: with its leetspeak symbols
+// as letters, and without the symbols at its edges, so that "$harbert"
+// and "kerfuffle!" both match.
+func (w Word) variants() []Word {
+	variants := []Word{{Text: unleet(w.Text), Start: w.Start, End: w.End}}
+	trimmed := strings.TrimFunc(w.Text, func(r rune) bool { return !isLetterOrDigit(r) })
+	if trimmed != "" && trimmed != w.Text {
+		start := w.Start + strings.Index(w.Text, trimmed)
+		variants = append(variants, Word{Text: unleet(trimmed), Start: start, End: start + len(trimmed)})
+	}
+	return variants
+}
+
+func unleet(s string) string {
+	return strings.Map(func(r rune) rune {
+		if letter, ok := leetspeak[r]; ok {
+			return letter
+		}
+		return r
+	}, s)
+}
+
+// normalizeWord folds a word from a word list the same way words in a text
+// are folded before they are compared.
+func normalizeWord(word string) string {
+	return unleet(NewText(strings.TrimSpace(word)).Folded)
+}
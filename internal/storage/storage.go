@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var ErrNotExist = errors.New("blob does not exist")
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStore stores opaque files under flat keys. Keys are chosen by the
+// caller and must not contain path separators.
+type BlobStore interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadSeekCloser, error)
+	Delete(key string) error
+}
+
+// LocalStore is a BlobStore backed by a directory on the local filesystem.
+type LocalStore struct {
+	dir string
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || key == "." || key == ".." || filepath.Base(key) != key {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+// Put writes the blob to a temporary file first and renames it into place,
+// so readers never see a partially written blob.
+func (s *LocalStore) Put(key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStore) Open(key string) (io.ReadSeekCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotExist
+	}
+	return file, err
+}
+
+func (s *LocalStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorePath(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatalf("Couldn't create store: %v", err)
+	}
+	cases := []struct {
+		key   string
+		valid bool
+	}{
+		{key: "abc123.png", valid: true},
+		{key: ".hidden", valid: true},
+		{key: "", valid: false},
+		{key: ".", valid: false},
+		{key: "..", valid: false},
+		{key: "../escape.png", valid: false},
+		{key: "nested/file.png", valid: false},
+		{key: "/etc/passwd", valid: false},
+		{key: "a/../../escape.png", valid: false},
+	}
+	for _, c := range cases {
+		t.Run(c.key, func(t *testing.T) {
+			path, err := store.path(c.key)
+			if !c.valid {
+				if !errors.Is(err, ErrInvalidKey) {
+					t.Errorf("Expected %q to be rejected, got %q, %v", c.key, path, err)
+				}
+				return
+			}
+			if err != nil || filepath.Dir(path) != dir {
+				t.Errorf("Expected %q to stay in the store, got %q, %v", c.key, path, err)
+			}
+		})
+	}
+
+	for _, key := range []string{"..", "../escape.png"} {
+		if err := store.Put(key, strings.NewReader("data")); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Expected Put(%q) to be rejected, got %v", key, err)
+		}
+		if _, err := store.Open(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Expected Open(%q) to be rejected, got %v", key, err)
+		}
+		if err := store.Delete(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Expected Delete(%q) to be rejected, got %v", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.png")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written outside the store, got %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatalf("Couldn't create store: %v", err)
+	}
+	if err := store.Put("blob", strings.NewReader("hello")); err != nil {
+		t.Fatalf("Couldn't put blob: %v", err)
+	}
+	blob, err := store.Open("blob")
+	if err != nil {
+		t.Fatalf("Couldn't open blob: %v", err)
+	}
+	data, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil || string(data) != "hello" {
+		t.Errorf("Expected the stored contents, got %q, %v", data, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected no temporary files to be left behind, got %v, %v", entries, err)
+	}
+
+	if err := store.Delete("blob"); err != nil {
+		t.Fatalf("Couldn't delete blob: %v", err)
+	}
+	if _, err := store.Open("blob"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Expected a deleted blob to return ErrNotExist, got %v", err)
+	}
+	if err := store.Delete("blob"); err != nil {
+		t.Errorf("Expected deleting a missing blob to succeed, got %v", err)
+	}
+}
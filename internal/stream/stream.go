@@ -0,0 +1,147 @@
+package stream
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrSlowConsumer = errors.New("subscriber fell too far behind")
+var ErrClosed = errors.New("hub closed")
+
+// Event is something that happened, numbered in publish order so clients
+// can resume after the last event they saw.
+type Event struct {
+	ID   uint64
+	Type string
+	Data interface{}
+	Time time.Time
+}
+
+// Hub fans events out to subscribers and keeps the most recent ones in a
+// bounded buffer for replay.
+type Hub struct {
+	mux              sync.Mutex
+	lastID           uint64
+	buffer           []Event
+	head             int
+	subscriberBuffer int
+	subscribers      map[*Subscription]struct{}
+	closed           bool
+}
+
+// Subscription receives every event published after it was created. Events
+// is closed when the subscription ends, after which Err tells why.
+type Subscription struct {
+	events chan Event
+	err    error
+}
+
+// NewHub creates a hub that remembers bufferSize events and queues up to
+// subscriberBuffer events per subscriber before dropping it.
+func NewHub(bufferSize, subscriberBuffer int) *Hub {
+	return &Hub{
+		// Starting from the clock keeps IDs from a previous run below the
+		// IDs of this one, so stale Last-Event-IDs are never mistaken for
+		// recent ones.
+		lastID:           uint64(time.Now().UnixMicro()),
+		buffer:           make([]Event, 0, bufferSize),
+		subscriberBuffer: subscriberBuffer,
+		subscribers:      make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish records an event and delivers it to every subscriber without
+// blocking. Subscribers whose queue is full are dropped with
+// ErrSlowConsumer.
+func (h *Hub) Publish(eventType string, data interface{}) Event {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	h.lastID++
+	event := Event{ID: h.lastID, Type: eventType, Data: data, Time: time.Now().UTC()}
+	if h.closed {
+		return event
+	}
+	if len(h.buffer) < cap(h.buffer) {
+		h.buffer = append(h.buffer, event)
+	} else if cap(h.buffer) > 0 {
+		h.buffer[h.head] = event
+		h.head = (h.head + 1) % cap(h.buffer)
+	}
+
+	for sub := range h.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			h.end(sub, ErrSlowConsumer)
+		}
+	}
+	return event
+}
+
+// Subscribe starts a subscription along with the buffered events published
+// after lastEventID. complete is false when some of those events are no
+// longer buffered, so the caller should tell the client to reload.
+func (h *Hub) Subscribe(lastEventID uint64) (sub *Subscription, replay []Event, complete bool, err error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if h.closed {
+		return nil, nil, false, ErrClosed
+	}
+	complete = true
+	if lastEventID != 0 {
+		oldest := h.lastID + 1
+		for i := 0; i < len(h.buffer); i++ {
+			event := h.buffer[(h.head+i)%len(h.buffer)]
+			if i == 0 {
+				oldest = event.ID
+			}
+			if event.ID > lastEventID {
+				replay = append(replay, event)
+			}
+		}
+		complete = lastEventID+1 >= oldest && lastEventID <= h.lastID
+	}
+
+	sub = &Subscription{events: make(chan Event, h.subscriberBuffer)}
+	h.subscribers[sub] = struct{}{}
+	return sub, replay, complete, nil
+}
+
+// Unsubscribe ends a subscription. It is safe to call more than once.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		h.end(sub, nil)
+	}
+}
+
+// Close ends every subscription with ErrClosed and stops accepting new ones.
+func (h *Hub) Close() {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		h.end(sub, ErrClosed)
+	}
+}
+
+// end removes sub and closes its channel. Callers must hold h.mux.
+func (h *Hub) end(sub *Subscription, err error) {
+	delete(h.subscribers, sub)
+	sub.err = err
+	close(sub.events)
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err reports why the subscription ended. It must only be called after
+// Events has been closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
@@ -0,0 +1,75 @@
+package stream
+
+import "testing"
+
+func TestSubscribeReplay(t *testing.T) {
+	hub := NewHub(3, 10)
+	first := hub.Publish("test", 1)
+	for i := 2; i <= 5; i++ {
+		hub.Publish("test", i)
+	}
+
+	cases := []struct {
+		name        string
+		lastEventID uint64
+		replay      []interface{}
+		complete    bool
+	}{
+		{name: "new subscriber", lastEventID: 0, replay: nil, complete: true},
+		{name: "buffered", lastEventID: first.ID + 2, replay: []interface{}{4, 5}, complete: true},
+		{name: "up to date", lastEventID: first.ID + 4, replay: nil, complete: true},
+		{name: "evicted", lastEventID: first.ID, replay: []interface{}{3, 4, 5}, complete: false},
+		{name: "from the future", lastEventID: first.ID + 10, replay: nil, complete: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sub, replay, complete, err := hub.Subscribe(c.lastEventID)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			defer hub.Unsubscribe(sub)
+			if complete != c.complete {
+				t.Errorf("Expected complete %v, got %v", c.complete, complete)
+			}
+			if len(replay) != len(c.replay) {
+				t.Fatalf("Expected %d replayed events, got %d", len(c.replay), len(replay))
+			}
+			for i, event := range replay {
+				if event.Data != c.replay[i] {
+					t.Errorf("Expected event %v, got %v", c.replay[i], event.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestSlowConsumerIsDropped(t *testing.T) {
+	hub := NewHub(10, 2)
+	slow, _, _, _ := hub.Subscribe(0)
+	fast, _, _, _ := hub.Subscribe(0)
+
+	received := 0
+	for i := 0; i < 5; i++ {
+		hub.Publish("test", i)
+		<-fast.Events()
+		received++
+	}
+	count := 0
+	for range slow.Events() {
+		count++
+	}
+	if count != 2 || slow.Err() != ErrSlowConsumer {
+		t.Errorf("Expected the slow consumer to get 2 events and ErrSlowConsumer, got %d and %v", count, slow.Err())
+	}
+
+	hub.Close()
+	if _, ok := <-fast.Events(); ok || fast.Err() != ErrClosed {
+		t.Errorf("Expected the hub to close the fast subscriber, got %v", fast.Err())
+	}
+	if received != 5 {
+		t.Errorf("Expected 5 events for the fast subscriber, got %d", received)
+	}
+	if _, _, _, err := hub.Subscribe(0); err != ErrClosed {
+		t.Errorf("Expected ErrClosed subscribing to a closed hub, got %v", err)
+	}
+}
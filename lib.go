@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type contextKey string
@@ -16,34 +21,15 @@ func (c contextKey) String() string {
 }
 
 var (
-	contextKeyDB = contextKey("database")
+	contextKeyDB     = contextKey("database")
+	contextKeyUserID = contextKey("user id")
 )
 
-func getProfaneWords() []string {
-	return []string{"kerfuffle", "sharbert", "fornax"}
-}
-
-func cleanBody(body string) string {
-	profaneWords := getProfaneWords()
-	words := strings.Split(body, " ")
-	cleanedBody := make([]string, 0)
-	for _, word := range words {
-		if contains(profaneWords, strings.ToLower(word)) {
-			cleanedBody = append(cleanedBody, "****")
-		} else {
-			cleanedBody = append(cleanedBody, word)
-		}
-	}
-	return strings.Join(cleanedBody, " ")
-}
-
-func contains(arr []string, search string) bool {
-	for _, v := range arr {
-		if v == search {
-			return true
-		}
-	}
-	return false
+// userIDFromContext returns the authenticated user's ID, or 0 when the
+// request is anonymous.
+func userIDFromContext(ctx context.Context) int {
+	userID, _ := ctx.Value(contextKeyUserID).(int)
+	return userID
 }
 
 func respondWithError(w http.ResponseWriter, statusCode int, msg string) {
@@ -70,6 +56,33 @@ func respondWithJson(w http.ResponseWriter, statusCode int, payload interface{})
 	w.Write(data)
 }
 
+type pageResponse struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// respondWithPage writes one page of a paginated listing.
+func respondWithPage(w http.ResponseWriter, r *http.Request, items interface{}, nextCursor string) {
+	setNextLink(w, r, nextCursor)
+	respondWithJson(w, http.StatusOK, pageResponse{
+		Data:       items,
+		NextCursor: nextCursor,
+	})
+}
+
+// setNextLink advertises the URL of the next page in a Link header when
+// there are more results.
+func setNextLink(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+	next := *r.URL
+	query := next.Query()
+	query.Set("cursor", nextCursor)
+	next.RawQuery = query.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+}
+
 func decodeJsonBody[T any](body io.ReadCloser, resp T) (T, error) {
 	decoder := json.NewDecoder(body)
 	err := decoder.Decode(&resp)
@@ -78,3 +91,54 @@ func decodeJsonBody[T any](body io.ReadCloser, resp T) (T, error) {
 	}
 	return resp, nil
 }
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+func getEnvSet(key, fallback string) map[string]bool {
+	value := os.Getenv(key)
+	if value == "" {
+		value = fallback
+	}
+	set := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			set[item] = true
+		}
+	}
+	return set
+}
+
+// parseTimeParam reads an optional RFC 3339 time from the query string,
+// returning the zero time when the parameter is absent.
+func parseTimeParam(r *http.Request, key string) (time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseLimitParam reads the optional page size from the query string,
+// returning 0 to let the database pick its default.
+func parseLimitParam(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, errors.New("invalid limit")
+	}
+	return limit, nil
+}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"internal/audit"
 	"internal/database"
+	"internal/moderation"
+	"internal/storage"
+	"internal/stream"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
@@ -14,24 +24,86 @@ import (
 type apiConfig struct {
 	fileserverHits int
 	database       *database.DB
+	blobStore      storage.BlobStore
+	hub            *stream.Hub
+	webSockets     sync.WaitGroup
+	moderator      *moderation.Pipeline
+	auditLog       *audit.Log
 	jwtSecret      string
 	polkaApiKey    string
+	// streamOrigins are the origins other than the server's own host that
+	// may open stream WebSockets.
+	streamOrigins map[string]bool
+
+	chirpEditWindow time.Duration
+	chirpEditPlans  map[string]bool
+
+	deletionPolicy     database.DeletionPolicy
+	deletionCoolingOff time.Duration
 }
 
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	godotenv.Load()
 
-	const filepathRoot = "."
+	const filepathRoot = "./public"
 	const port = ":8080"
 	const databasePath = "./database.json"
 	jwtSecret := os.Getenv("JST_SECRET")
 	polkaApiKey := os.Getenv("POLKA_API_KEY")
+	streamOrigins := getEnvSet("STREAM_ALLOWED_ORIGINS", "")
+
+	chirpEditWindow, err := getEnvDuration("CHIRP_EDIT_WINDOW", time.Hour)
+	if err != nil {
+		log.Fatal(err)
+	}
+	chirpEditPlans := getEnvSet("CHIRP_EDIT_PLANS", planRed)
+	deletionPolicy := database.DeletionPolicy(os.Getenv("ACCOUNT_DELETION_POLICY"))
+	if deletionPolicy == "" {
+		deletionPolicy = database.DeletionAnonymize
+	}
+	if !deletionPolicy.Valid() {
+		log.Fatalf("invalid ACCOUNT_DELETION_POLICY %q, expected anonymize or delete", deletionPolicy)
+	}
+	deletionCoolingOff, err := getEnvDuration("ACCOUNT_DELETION_COOLING_OFF", 30*24*time.Hour)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	db, err := database.NewDB(databasePath)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "./media"
+	}
+	blobStore, err := storage.NewLocalStore(mediaDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	moderationConfig := os.Getenv("MODERATION_CONFIG")
+	if moderationConfig == "" {
+		moderationConfig = "./moderation.json"
+	}
+	moderator, err := moderation.NewPipeline(moderationConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	auditPath := os.Getenv("AUDIT_LOG")
+	if auditPath == "" {
+		auditPath = "./audit.jsonl"
+	}
+	auditLog, err := audit.Open(auditPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer auditLog.Close()
+
 	dbg := flag.Bool("debug", false, "Enable debug mode")
 	flag.Parse()
 	if dbg != nil && *dbg {
@@ -41,48 +113,227 @@ func main() {
 			log.Fatal(err)
 		}
 	}
+	if flag.Arg(0) == "create-admin" {
+		err := createAdmin(db, auditLog, flag.Args()[1:], os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	apiCfg := apiConfig{
 		fileserverHits: 0,
 		database:       db,
+		blobStore:      blobStore,
+		hub:            stream.NewHub(streamBufferSize, streamSubscriberBuffer),
+		moderator:      moderator,
+		auditLog:       auditLog,
 		jwtSecret:      jwtSecret,
 		polkaApiKey:    polkaApiKey,
+		streamOrigins:  streamOrigins,
+
+		chirpEditWindow: chirpEditWindow,
+		chirpEditPlans:  chirpEditPlans,
+
+		deletionPolicy:     deletionPolicy,
+		deletionCoolingOff: deletionCoolingOff,
+	}
+
+	server := http.Server{
+		Addr:    port,
+		Handler: apiCfg.router(filepathRoot),
+	}
+	// Ending the streams lets their handlers return, since Shutdown doesn't
+	// interrupt active requests. It doesn't wait for hijacked WebSocket
+	// connections either, so those are waited for separately.
+	server.RegisterOnShutdown(apiCfg.hub.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go apiCfg.runAccountPurge(ctx)
+	go apiCfg.runScheduler(ctx)
+	go func() {
+		log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Fatal(err)
 	}
+	apiCfg.webSockets.Wait()
+}
 
+// router wires up every route. It is kept apart from main so tests can
+// serve the same routes.
+func (cfg *apiConfig) router(filepathRoot string) http.Handler {
 	r := chi.NewRouter()
 
-	fsHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot))))
+	fsHandler := cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot))))
 	r.Handle("/app", fsHandler)
 	r.Handle("/app/*", fsHandler)
 
 	apiRouter := chi.NewRouter()
+	r.Get("/media/{key}", cfg.serveMediaHandler)
+
 	apiRouter.Get("/healthz", healthCheck)
-	apiRouter.Get("/reset", apiCfg.resetHandler)
+	apiRouter.With(cfg.middlewareRequirePermission(permissionReset)).Get("/reset", cfg.resetHandler)
+
+	optionalAuth := apiRouter.With(cfg.middlewareOptionalAuth)
+	requireAuth := apiRouter.With(cfg.middlewareRequireAuth)
+
+	requireAuth.Post("/media", cfg.uploadMediaHandler)
+
+	requireAuth.Post("/chirps", cfg.createChirpsHandler)
+	requireAuth.Get("/chirps/scheduled", cfg.getScheduledChirpsHandler)
+	requireAuth.Get("/chirps/scheduled/{scheduledID}", cfg.getScheduledChirpHandler)
+	requireAuth.Put("/chirps/scheduled/{scheduledID}", cfg.updateScheduledChirpHandler)
+	requireAuth.Delete("/chirps/scheduled/{scheduledID}", cfg.cancelScheduledChirpHandler)
+	optionalAuth.Get("/chirps", cfg.getChirpsHandler)
+	optionalAuth.Get("/chirps/{chirpID}", cfg.getSingleChirpHandler)
+	requireAuth.Put("/chirps/{chirpID}", cfg.updateChirpHandler)
+	requireAuth.Delete("/chirps/{chirpID}", cfg.deleteSingleChirpHandler)
+	optionalAuth.Get("/chirps/{chirpID}/revisions", cfg.getChirpRevisionsHandler)
+	optionalAuth.Get("/chirps/{chirpID}/thread", cfg.getChirpThreadHandler)
+
+	requireAuth.Post("/chirps/{chirpID}/like", cfg.addReactionHandler(database.ReactionLike))
+	requireAuth.Delete("/chirps/{chirpID}/like", cfg.removeReactionHandler(database.ReactionLike))
+	optionalAuth.Get("/chirps/{chirpID}/likes", cfg.getReactionsHandler(database.ReactionLike))
+	requireAuth.Post("/chirps/{chirpID}/rechirp", cfg.addReactionHandler(database.ReactionRechirp))
+	requireAuth.Delete("/chirps/{chirpID}/rechirp", cfg.removeReactionHandler(database.ReactionRechirp))
+	optionalAuth.Get("/chirps/{chirpID}/rechirps", cfg.getReactionsHandler(database.ReactionRechirp))
+	requireAuth.Post("/chirps/{chirpID}/votes", cfg.voteHandler)
+	requireAuth.Post("/chirps/{chirpID}/pin", cfg.pinChirpHandler)
+	requireAuth.Delete("/chirps/{chirpID}/pin", cfg.unpinChirpHandler)
+	requireAuth.Post("/chirps/{chirpID}/bookmark", cfg.addBookmarkHandler)
+	requireAuth.Delete("/chirps/{chirpID}/bookmark", cfg.removeBookmarkHandler)
+	requireAuth.Get("/bookmarks", cfg.getBookmarksHandler)
+
+	apiRouter.Get("/hashtags/trending", cfg.getTrendingHashtagsHandler)
+	optionalAuth.Get("/hashtags/{tag}", cfg.getHashtagChirpsHandler)
+	optionalAuth.Get("/search", cfg.searchHandler)
+
+	requireAuth.Post("/drafts", cfg.createDraftHandler)
+	requireAuth.Get("/drafts", cfg.getDraftsHandler)
+	requireAuth.Get("/drafts/{draftID}", cfg.getDraftHandler)
+	requireAuth.Put("/drafts/{draftID}", cfg.updateDraftHandler)
+	requireAuth.Delete("/drafts/{draftID}", cfg.deleteDraftHandler)
+	requireAuth.Post("/drafts/{draftID}/publish", cfg.publishDraftHandler)
+
+	apiRouter.Post("/users", cfg.createUserHandler)
+	requireAuth.Put("/users", cfg.updateUserHandler)
+	requireAuth.Patch("/users/me", cfg.updateProfileHandler)
+	requireAuth.Delete("/users/me", cfg.deleteUserHandler)
+	requireAuth.Get("/users/me/export", cfg.exportUserHandler)
+	requireAuth.Post("/users/me/avatar", cfg.uploadAvatarHandler)
+	requireAuth.Get("/users/me/blocks", cfg.getBlocksHandler)
+	requireAuth.Get("/users/me/mutes", cfg.getMutesHandler)
+	optionalAuth.Get("/users/{user}", cfg.getUserProfileHandler)
+	optionalAuth.Get("/users/{user}/chirps", cfg.getUserChirpsHandler)
+	requireAuth.Post("/users/{user}/follow", cfg.followHandler)
+	requireAuth.Delete("/users/{user}/follow", cfg.unfollowHandler)
+	optionalAuth.Get("/users/{user}/followers", cfg.getFollowersHandler)
+	optionalAuth.Get("/users/{user}/following", cfg.getFollowingHandler)
+	optionalAuth.Get("/users/{user}/lists", cfg.getUserListsHandler)
+	requireAuth.Post("/users/{user}/block", cfg.blockHandler)
+	requireAuth.Delete("/users/{user}/block", cfg.unblockHandler)
+	requireAuth.Post("/users/{user}/mute", cfg.muteHandler)
+	requireAuth.Delete("/users/{user}/mute", cfg.unmuteHandler)
 
-	apiRouter.Post("/chirps", apiCfg.createChirpsHandler)
-	apiRouter.Get("/chirps", apiCfg.getChirpsHandler)
-	apiRouter.Get("/chirps/{chirpID}", apiCfg.getSingleChirpHandler)
-	apiRouter.Delete("/chirps/{chirpID}", apiCfg.deleteSingleChirpHandler)
+	requireAuth.Get("/timeline/home", cfg.homeTimelineHandler)
 
-	apiRouter.Post("/users", apiCfg.createUserHandler)
-	apiRouter.Put("/users", apiCfg.updateUserHandler)
+	requireAuth.Post("/lists", cfg.createListHandler)
+	requireAuth.Get("/lists", cfg.getMyListsHandler)
+	optionalAuth.Get("/lists/{listID}", cfg.getListHandler)
+	requireAuth.Put("/lists/{listID}", cfg.updateListHandler)
+	requireAuth.Delete("/lists/{listID}", cfg.deleteListHandler)
+	optionalAuth.Get("/lists/{listID}/members", cfg.getListMembersHandler)
+	requireAuth.Put("/lists/{listID}/members/{user}", cfg.addListMemberHandler)
+	requireAuth.Delete("/lists/{listID}/members/{user}", cfg.removeListMemberHandler)
+	optionalAuth.Get("/lists/{listID}/timeline", cfg.getListTimelineHandler)
 
-	apiRouter.Post("/login", apiCfg.login)
-	apiRouter.Post("/refresh", apiCfg.refreshJWTHandler)
-	apiRouter.Post("/revoke", apiCfg.revokeJWTHandler)
+	requireAuth.Post("/stream/ticket", cfg.createStreamTicketHandler)
+	streamAuth := apiRouter.With(cfg.middlewareStreamTicket)
+	streamAuth.Get("/stream", cfg.streamHandler)
+	streamAuth.Get("/stream/ws", cfg.streamWebSocketHandler)
 
-	apiRouter.Post("/polka/webhooks", apiCfg.polkaWebhookHandler)
+	requireAuth.Get("/notifications", cfg.getNotificationsHandler)
+	requireAuth.Post("/notifications/read-all", cfg.markAllNotificationsReadHandler)
+	requireAuth.Post("/notifications/{notificationID}/read", cfg.markNotificationReadHandler)
+	requireAuth.Get("/notifications/preferences", cfg.getNotificationPreferencesHandler)
+	requireAuth.Put("/notifications/preferences", cfg.updateNotificationPreferencesHandler)
+
+	requireAuth.Post("/conversations", cfg.createConversationHandler)
+	requireAuth.Get("/conversations", cfg.getConversationsHandler)
+	requireAuth.Get("/conversations/{conversationID}", cfg.getConversationHandler)
+	requireAuth.Get("/conversations/{conversationID}/messages", cfg.getMessagesHandler)
+	requireAuth.Post("/conversations/{conversationID}/messages", cfg.sendMessageHandler)
+	requireAuth.Post("/conversations/{conversationID}/read", cfg.markConversationReadHandler)
+
+	requireAuth.Post("/reports", cfg.createReportHandler)
+	requireAuth.Get("/reports/{reportID}", cfg.getReportHandler)
+
+	apiRouter.Post("/login", cfg.login)
+	apiRouter.Post("/refresh", cfg.refreshJWTHandler)
+	apiRouter.Post("/revoke", cfg.revokeJWTHandler)
+
+	apiRouter.Post("/polka/webhooks", cfg.polkaWebhookHandler)
 	r.Mount("/api", apiRouter)
 
-	adminRouter := chi.NewRouter()
-	adminRouter.Get("/metrics", apiCfg.metricsHandler)
-	r.Mount("/admin", adminRouter)
+	r.Mount("/admin", cfg.adminRouter())
 
-	corsMux := middlewareCors(r)
-	server := http.Server{
-		Addr:    port,
-		Handler: corsMux,
+	return middlewareCors(r)
+}
+
+// adminRouter wires up the routes under /admin. Each needs a permission,
+// see rolePermissions.
+func (cfg *apiConfig) adminRouter() chi.Router {
+	adminRouter := chi.NewRouter()
+	requirePermission := func(p permission) chi.Router {
+		return adminRouter.With(cfg.middlewareRequirePermission(p))
 	}
-	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(server.ListenAndServe())
+	requirePermission(permissionViewMetrics).Get("/metrics", cfg.metricsHandler)
+
+	manageFilters := requirePermission(permissionManageFilters)
+	manageFilters.Get("/moderation/filters", cfg.getModerationFiltersHandler)
+	manageFilters.Get("/moderation/filters/{name}", cfg.getModerationFilterHandler)
+	manageFilters.Put("/moderation/filters/{name}", cfg.putModerationFilterHandler)
+	manageFilters.Delete("/moderation/filters/{name}", cfg.deleteModerationFilterHandler)
+	manageFilters.Post("/moderation/filters/{name}/words", cfg.addModerationWordsHandler)
+	manageFilters.Delete("/moderation/filters/{name}/words/{word}", cfg.removeModerationWordHandler)
+	manageFilters.Post("/moderation/preview", cfg.previewModerationHandler)
+
+	reviewHeld := requirePermission(permissionReviewHeld)
+	reviewHeld.Get("/moderation/held", cfg.getHeldChirpsHandler)
+	reviewHeld.Post("/moderation/held/{chirpID}/approve", cfg.approveHeldChirpHandler)
+	reviewHeld.Post("/moderation/held/{chirpID}/reject", cfg.rejectHeldChirpHandler)
+
+	manageReports := requirePermission(permissionManageReports)
+	manageReports.Get("/reports", cfg.getReportQueueHandler)
+	manageReports.Get("/reports/{reportID}", cfg.getQueuedReportHandler)
+	manageReports.Post("/reports/{reportID}/claim", cfg.claimReportHandler)
+	manageReports.Post("/reports/{reportID}/resolve", cfg.resolveReportHandler)
+	requirePermission(permissionViewModeration).Get("/moderation/log", cfg.getModerationLogHandler)
+
+	manageStatus := requirePermission(permissionManageStatus)
+	manageStatus.Get("/users/{user}/status", cfg.getUserStatusHandler)
+	manageStatus.Put("/users/{user}/status", cfg.updateUserStatusHandler)
+
+	viewAudit := requirePermission(permissionViewAudit)
+	viewAudit.Get("/audit", cfg.getAuditLogHandler)
+	viewAudit.Get("/audit/verify", cfg.verifyAuditLogHandler)
+
+	manageRoles := requirePermission(permissionManageRoles)
+	manageRoles.Get("/users/{user}/roles", cfg.getRolesHandler)
+	manageRoles.Put("/users/{user}/roles/{role}", cfg.grantRoleHandler)
+	manageRoles.Delete("/users/{user}/roles/{role}", cfg.revokeRoleHandler)
+
+	return adminRouter
 }
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"internal/auth"
+	"internal/database"
 	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 func middlewareCors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, PATCH, DELETE")
 		w.Header().Set("Access-Control-Allow-Headers", "*")
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -23,3 +31,144 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// accountRestrictedError is returned for users who may not sign in because
+// they are suspended or banned.
+type accountRestrictedError struct {
+	user database.User
+}
+
+func (e accountRestrictedError) Error() string {
+	if e.user.StatusAt(time.Now()) == database.UserBanned {
+		return "Your account is banned"
+	}
+	return fmt.Sprintf("Your account is suspended until %s", e.user.SuspendedUntil.Format(time.RFC3339))
+}
+
+// checkAccountStatus returns an accountRestrictedError if the user is
+// suspended or banned.
+func checkAccountStatus(user database.User) error {
+	if user.Restricted(time.Now()) {
+		return accountRestrictedError{user: user}
+	}
+	return nil
+}
+
+// checkTokenRevoked returns an error if the user revoked their tokens after
+// token was issued or is pending deletion.
+func checkTokenRevoked(user database.User, token *jwt.Token) error {
+	issuedAt, err := token.Claims.GetIssuedAt()
+	if err != nil {
+		return err
+	}
+	if issuedAt == nil || !user.TokenValid(issuedAt.Time) {
+		return errors.New("token revoked")
+	}
+	if user.PendingDeletion() {
+		return database.ErrDeletionPending
+	}
+	return nil
+}
+
+// authenticate resolves the access token in the Authorization header to its
+// user. Refresh tokens and revoked tokens are rejected, and so are
+// suspended and banned users, even while their tokens are unexpired.
+func (cfg *apiConfig) authenticate(r *http.Request) (database.User, error) {
+	user, _, err := cfg.authenticateToken(r.Header.Get("Authorization"), "chirpy-access")
+	return user, err
+}
+
+// authenticateToken is authenticate for a token from issuer. It also
+// returns the parsed token.
+func (cfg *apiConfig) authenticateToken(authHeader, issuer string) (database.User, *jwt.Token, error) {
+	token, err := cfg.parseToken(authHeader, issuer)
+	if err != nil {
+		return database.User{}, nil, err
+	}
+	userID, err := auth.GetUserFromTokenClaims(token)
+	if err != nil {
+		return database.User{}, nil, err
+	}
+	user, err := cfg.database.GetUser(userID)
+	if err != nil {
+		return database.User{}, nil, err
+	}
+	err = checkTokenRevoked(user, token)
+	if err != nil {
+		return database.User{}, nil, err
+	}
+	return user, token, checkAccountStatus(user)
+}
+
+// parseToken validates the token in the Authorization header and checks
+// that it was issued by issuer.
+func (cfg *apiConfig) parseToken(authHeader, issuer string) (*jwt.Token, error) {
+	token, err := auth.ValidateJWTToken(authHeader, cfg.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+	tokenIssuer, err := token.Claims.GetIssuer()
+	if err != nil {
+		return nil, err
+	}
+	if tokenIssuer != issuer {
+		return nil, fmt.Errorf("not a %s token", issuer)
+	}
+	return token, nil
+}
+
+// respondWithAuthError explains why authenticate failed: restricted
+// accounts get a 403 saying so, and everything else a 401.
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	var restricted accountRestrictedError
+	if errors.As(err, &restricted) {
+		respondWithError(w, http.StatusForbidden, restricted.Error())
+		return
+	}
+	respondWithError(w, http.StatusUnauthorized, "Invalid JWT token")
+}
+
+// middlewareRequireAuth rejects requests without a valid access token and
+// stores the caller's ID in the request context.
+func (cfg *apiConfig) middlewareRequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := cfg.authenticate(r)
+		if err != nil {
+			respondWithAuthError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyUserID, user.ID)))
+	})
+}
+
+// middlewareStreamTicket authenticates stream requests with a ticket query
+// parameter, for clients such as EventSource and browser WebSockets that
+// can't set headers. Requests without one fall back to the Authorization
+// header and may be anonymous.
+func (cfg *apiConfig) middlewareStreamTicket(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ticket := r.URL.Query().Get("ticket")
+		if ticket == "" || r.Header.Get("Authorization") != "" {
+			cfg.middlewareOptionalAuth(next).ServeHTTP(w, r)
+			return
+		}
+		user, _, err := cfg.authenticateToken("Bearer "+ticket, "chirpy-stream")
+		if err != nil {
+			respondWithAuthError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyUserID, user.ID)))
+	})
+}
+
+// middlewareOptionalAuth lets anonymous requests through, but still rejects
+// requests that send an invalid token.
+func (cfg *apiConfig) middlewareOptionalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cfg.middlewareRequireAuth(next).ServeHTTP(w, r)
+	})
+}
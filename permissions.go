@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"internal/database"
+	"net/http"
+)
+
+// permission is something a role allows. Handlers check permissions rather
+// than roles, so what each role may do is decided here in one place.
+type permission string
+
+const (
+	permissionViewMetrics    permission = "metrics:view"
+	permissionReset          permission = "reset"
+	permissionManageFilters  permission = "moderation:filters"
+	permissionReviewHeld     permission = "moderation:held"
+	permissionManageReports  permission = "reports:manage"
+	permissionViewModeration permission = "moderation:log"
+	permissionManageStatus   permission = "users:status"
+	permissionManageRoles    permission = "roles:manage"
+	permissionViewAudit      permission = "audit:view"
+)
+
+var rolePermissions = map[database.Role][]permission{
+	database.RoleAdmin: {
+		permissionViewMetrics,
+		permissionReset,
+		permissionManageFilters,
+		permissionReviewHeld,
+		permissionManageReports,
+		permissionViewModeration,
+		permissionManageStatus,
+		permissionManageRoles,
+		permissionViewAudit,
+	},
+	database.RoleModerator: {
+		permissionReviewHeld,
+		permissionManageReports,
+		permissionViewModeration,
+		permissionManageStatus,
+	},
+}
+
+// can reports whether any of the user's roles grants p.
+func can(user database.User, p permission) bool {
+	for _, role := range user.Roles {
+		for _, granted := range rolePermissions[role] {
+			if granted == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func roleNames(roles []database.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, string(role))
+	}
+	return names
+}
+
+// middlewareRequirePermission rejects requests unless the caller's access
+// token belongs to a user whose roles grant p, and stores the caller's ID in
+// the request context. Roles are read from the database rather than the
+// token, so revoking a role takes effect immediately.
+func (cfg *apiConfig) middlewareRequirePermission(p permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := cfg.authenticate(r)
+			if err != nil {
+				respondWithAuthError(w, err)
+				return
+			}
+			if !can(user, p) {
+				respondWithError(w, http.StatusForbidden, "You don't have permission to do that")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyUserID, user.ID)))
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"internal/database"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// routePermissions is the permission every admin route needs, keyed by
+// method and route pattern.
+var routePermissions = map[string]permission{
+	"GET /api/reset":                                       permissionReset,
+	"GET /admin/metrics":                                   permissionViewMetrics,
+	"GET /admin/moderation/filters":                        permissionManageFilters,
+	"GET /admin/moderation/filters/{name}":                 permissionManageFilters,
+	"PUT /admin/moderation/filters/{name}":                 permissionManageFilters,
+	"DELETE /admin/moderation/filters/{name}":              permissionManageFilters,
+	"POST /admin/moderation/filters/{name}/words":          permissionManageFilters,
+	"DELETE /admin/moderation/filters/{name}/words/{word}": permissionManageFilters,
+	"POST /admin/moderation/preview":                       permissionManageFilters,
+	"GET /admin/moderation/held":                           permissionReviewHeld,
+	"POST /admin/moderation/held/{chirpID}/approve":        permissionReviewHeld,
+	"POST /admin/moderation/held/{chirpID}/reject":         permissionReviewHeld,
+	"GET /admin/reports":                                   permissionManageReports,
+	"GET /admin/reports/{reportID}":                        permissionManageReports,
+	"POST /admin/reports/{reportID}/claim":                 permissionManageReports,
+	"POST /admin/reports/{reportID}/resolve":               permissionManageReports,
+	"GET /admin/moderation/log":                            permissionViewModeration,
+	"GET /admin/users/{user}/status":                       permissionManageStatus,
+	"PUT /admin/users/{user}/status":                       permissionManageStatus,
+	"GET /admin/users/{user}/roles":                        permissionManageRoles,
+	"PUT /admin/users/{user}/roles/{role}":                 permissionManageRoles,
+	"DELETE /admin/users/{user}/roles/{role}":              permissionManageRoles,
+	"GET /admin/audit":                                     permissionViewAudit,
+	"GET /admin/audit/verify":                              permissionViewAudit,
+}
+
+func TestEveryAdminRouteNeedsAPermission(t *testing.T) {
+	cfg := newTestConfig(t)
+	err := chi.Walk(cfg.adminRouter(), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if _, ok := routePermissions[method+" /admin"+route]; !ok {
+			return fmt.Errorf("%s /admin%s has no expected permission", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRoutePermissions(t *testing.T) {
+	cfg := newTestConfig(t)
+	tokens := map[string]string{
+		"user":      newTestUser(t, cfg, "user@example.com"),
+		"moderator": newTestUser(t, cfg, "moderator@example.com", database.RoleModerator),
+		"admin":     newTestUser(t, cfg, "admin@example.com", database.RoleAdmin),
+	}
+	// Role changes go to a user of their own, so granting a role can't
+	// change what the callers may do.
+	newTestUser(t, cfg, "target@example.com")
+	// Admins may use every route, moderators only those for working the
+	// moderation queues, and other users none.
+	moderatorAllowed := map[permission]bool{
+		permissionReviewHeld:     true,
+		permissionManageReports:  true,
+		permissionViewModeration: true,
+		permissionManageStatus:   true,
+	}
+	params := strings.NewReplacer(
+		"{name}", "profanity",
+		"{word}", "kerfuffle",
+		"{chirpID}", "1",
+		"{reportID}", "1",
+		"{user}", "4",
+		"{role}", "moderator",
+	)
+	server := newTestServer(t, cfg)
+
+	for route, p := range routePermissions {
+		method, pattern, _ := strings.Cut(route, " ")
+		path := params.Replace(pattern)
+		for _, caller := range []string{"anonymous", "user", "moderator", "admin"} {
+			t.Run(route+" as "+caller, func(t *testing.T) {
+				req, err := http.NewRequest(method, server.URL+path, strings.NewReader("{}"))
+				if err != nil {
+					t.Fatalf("Couldn't create request: %v", err)
+				}
+				if token, ok := tokens[caller]; ok {
+					req.Header.Set("Authorization", "Bearer "+token)
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("Couldn't send request: %v", err)
+				}
+				resp.Body.Close()
+
+				switch {
+				case caller == "anonymous":
+					if resp.StatusCode != http.StatusUnauthorized {
+						t.Errorf("Expected 401, got %d", resp.StatusCode)
+					}
+				case caller == "admin" || caller == "moderator" && moderatorAllowed[p]:
+					if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+						t.Errorf("Expected to be allowed, got %d", resp.StatusCode)
+					}
+				default:
+					if resp.StatusCode != http.StatusForbidden {
+						t.Errorf("Expected 403, got %d", resp.StatusCode)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestAdminsAndModeratorsGetTheirPermissions(t *testing.T) {
+	moderator := database.User{Roles: []database.Role{database.RoleModerator}}
+	if !can(moderator, permissionManageReports) {
+		t.Error("Expected moderators to manage reports")
+	}
+	if can(moderator, permissionManageRoles) || can(moderator, permissionReset) {
+		t.Error("Expected moderators not to manage roles or reset")
+	}
+	if can(database.User{}, permissionViewMetrics) {
+		t.Error("Expected users without roles to have no permissions")
+	}
+	admin := database.User{Roles: []database.Role{database.RoleAdmin}}
+	for _, p := range rolePermissions[database.RoleModerator] {
+		if !can(admin, p) {
+			t.Errorf("Expected admins to have every moderator permission, missing %s", p)
+		}
+	}
+}